@@ -0,0 +1,174 @@
+// loadgen は JSON Lines で定義したリクエストをデモアプリケーションに送信し、
+// ステータスとレイテンシの集計を表示する。
+//
+//	go run ./cmd/loadgen -requests ../loadgen/requests.jsonl -target http://localhost:8080 -concurrency 4 -rate 20 -duration 30s
+//
+// -scenario を指定すると、-scenarios の YAML で定義したシナリオを実行し、期待するメトリクスの変化と実測値を比較する。
+// いずれかの期待値を満たさなかった場合は終了コード 1 で終了する。
+//
+//	go run ./cmd/loadgen -scenario memory-leak
+//
+// OTLP_ENDPOINT を指定すると、リクエストごとのクライアントスパンを service.name=loadgen としてエクスポートする。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/loadgen"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func main() {
+	requestsPath := flag.String("requests", "../loadgen/requests.jsonl", "JSON Lines file of request definitions")
+	target := flag.String("target", "http://localhost:8080", "base URL to send requests to")
+	concurrency := flag.Int("concurrency", 1, "number of concurrent requests")
+	rate := flag.Float64("rate", 10, "requests per second across all workers (0 = unlimited, at most 1e9)")
+	duration := flag.Duration("duration", 10*time.Second, "how long to send requests (0 = until -n is reached)")
+	count := flag.Int("n", 0, "total number of requests (0 = until -duration elapses)")
+	scenariosPath := flag.String("scenarios", "../loadgen/scenarios.yaml", "YAML file of scenario definitions")
+	scenario := flag.String("scenario", "", "name of the scenario to run instead of replaying -requests")
+	list := flag.Bool("list", false, "list the scenarios in -scenarios and exit")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *list {
+		scenarios, err := readScenarios(*scenariosPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, sc := range scenarios {
+			fmt.Printf("%-20s %s\n", sc.Name, sc.Description)
+		}
+		return
+	}
+
+	tp, shutdown, err := newTracerProvider(ctx)
+	if err != nil {
+		log.Fatalf("failed to create tracer provider: %v", err)
+	}
+	defer shutdown()
+
+	runner, err := loadgen.NewRunner(loadgen.Options{
+		BaseURL:        *target,
+		Concurrency:    *concurrency,
+		Rate:           *rate,
+		Duration:       *duration,
+		Count:          *count,
+		TracerProvider: tp,
+		Propagator:     propagation.TraceContext{},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *scenario != "" {
+		if !runScenario(ctx, runner, *scenariosPath, *scenario) {
+			shutdown()
+			os.Exit(1)
+		}
+		return
+	}
+
+	f, err := os.Open(*requestsPath)
+	if err != nil {
+		log.Fatalf("failed to open requests: %v", err)
+	}
+	requests, err := loadgen.ReadRequests(f)
+	_ = f.Close()
+	if err != nil {
+		log.Fatalf("failed to read %s: %v", *requestsPath, err)
+	}
+
+	fmt.Printf("sending %d request definitions to %s\n\n", len(requests), *target)
+	summary, err := runner.Run(ctx, requests)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := summary.Write(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readScenarios(path string) ([]loadgen.Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open scenarios: %w", err)
+	}
+	defer f.Close()
+	scenarios, err := loadgen.ReadScenarios(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return scenarios, nil
+}
+
+// runScenario は name のシナリオを実行してレポートを表示し、すべての期待値を満たしたかを返す
+func runScenario(ctx context.Context, runner *loadgen.Runner, path, name string) bool {
+	scenarios, err := readScenarios(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, sc := range scenarios {
+		if sc.Name != name {
+			continue
+		}
+		report, err := runner.RunScenario(ctx, sc)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := report.Write(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return report.OK()
+	}
+	log.Fatalf("scenario %q not found in %s", name, path)
+	return false
+}
+
+// newTracerProvider は OTLP_ENDPOINT が指定されている場合にクライアントスパンをエクスポートする TracerProvider を返す
+func newTracerProvider(ctx context.Context) (trace.TracerProvider, func(), error) {
+	endpoint := os.Getenv("OTLP_ENDPOINT")
+	if endpoint == "" {
+		return noop.NewTracerProvider(), func() {}, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx,
+		otlptracegrpc.WithEndpoint(endpoint),
+		otlptracegrpc.WithInsecure(),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(loadgen.ScopeName)),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	shutdown := func() {
+		// 中断された場合もスパンを送信しきるため、新しいコンテキストで終了する
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			log.Printf("failed to shutdown tracer provider: %v", err)
+		}
+	}
+	return tp, shutdown, nil
+}
@@ -0,0 +1,54 @@
+// nginxconf はアプリケーションのルート定義から nginx/conf.d/app.conf を生成する。
+//
+//	go run ./cmd/nginxconf -o ../nginx/conf.d/app.conf
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/health"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/nginxconf"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/server"
+	"github.com/go-chi/chi/v5"
+)
+
+func main() {
+	out := flag.String("o", "", "output file (default: stdout)")
+	flag.Parse()
+
+	conf, err := generate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "" {
+		if _, err := os.Stdout.Write(conf); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := os.WriteFile(*out, conf, 0o644); err != nil {
+		log.Fatalf("failed to write %s: %v", *out, err)
+	}
+}
+
+// generate はアプリケーションのルーターから nginx 設定を生成する。
+// プローブはアプリケーションと同様に既定でトレースしない。テレメトリは不要なため、グローバルの no-op プロバイダーのまま Server を作成する
+func generate() ([]byte, error) {
+	srv, err := server.NewServer(server.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server: %w", err)
+	}
+	routes, ok := srv.Routes().(chi.Routes)
+	if !ok {
+		return nil, fmt.Errorf("server routes do not implement chi.Routes")
+	}
+	var buf bytes.Buffer
+	if err := nginxconf.Generate(&buf, routes, health.Paths()...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+const confPath = "../../../nginx/conf.d/app.conf"
+
+// TestConfUpToDate はコミットされた nginx 設定がルート定義と一致することを確認する
+func TestConfUpToDate(t *testing.T) {
+	want, err := generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(confPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is stale; run `make nginx-conf` to regenerate it", confPath)
+	}
+}
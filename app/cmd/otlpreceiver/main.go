@@ -0,0 +1,48 @@
+// otlpreceiver は組み込みの OTLP レシーバーを単体で起動する。
+// docker compose でサイドカーとして起動し、nginx とアプリのエクスポート先にすると、
+// 外部の otel-tui なしでテレメトリを確認できる。
+//
+//	go run ./cmd/otlpreceiver -grpc :4317 -http :4318
+//	curl localhost:4318/api/traces
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/receiver"
+)
+
+func main() {
+	grpcAddr := flag.String("grpc", ":4317", "OTLP/gRPC listen address")
+	httpAddr := flag.String("http", ":4318", "OTLP/HTTP and query API listen address")
+	maxSpans := flag.Int("max-spans", receiver.DefaultMaxSpans, "maximum number of spans to keep")
+	maxLogs := flag.Int("max-logs", receiver.DefaultMaxLogs, "maximum number of log records to keep")
+	maxPoints := flag.Int("max-metric-points", receiver.DefaultMaxMetricPoints, "maximum number of metric data points to keep")
+	retention := flag.Duration("retention", receiver.DefaultRetention, "how long to keep received telemetry")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	rcv := receiver.New(receiver.NewStore(receiver.StoreOptions{
+		MaxSpans:        *maxSpans,
+		MaxLogs:         *maxLogs,
+		MaxMetricPoints: *maxPoints,
+		Retention:       *retention,
+	}))
+
+	go func() {
+		slog.InfoContext(ctx, "Starting OTLP HTTP receiver", "addr", *httpAddr)
+		log.Fatal(http.ListenAndServe(*httpAddr, rcv.Routes()))
+	}()
+	if err := rcv.ServeGRPC(ctx, *grpcAddr); err != nil {
+		log.Fatal(err)
+	}
+}
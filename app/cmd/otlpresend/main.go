@@ -0,0 +1,117 @@
+// otlpresend はファイルエクスポーター（FILE_EXPORT_DIR）が書き出した OTLP/JSON の JSON Lines を、
+// 書き込んだ順に OTLP/gRPC のエンドポイントへ送り直す。引数にはファイルかディレクトリを指定する。
+//
+//	go run ./cmd/otlpresend -endpoint localhost:4317 ./telemetry
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/fileexport"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/otlpjson"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+)
+
+// signalRequest はシグナルごとの OTLP/gRPC のメソッドとメッセージ
+type signalRequest struct {
+	method     string
+	newRequest func() proto.Message
+	newReply   func() proto.Message
+}
+
+var requests = map[string]signalRequest{
+	fileexport.SignalTraces: {
+		method:     fileexport.TraceMethod,
+		newRequest: func() proto.Message { return &coltracepb.ExportTraceServiceRequest{} },
+		newReply:   func() proto.Message { return &coltracepb.ExportTraceServiceResponse{} },
+	},
+	fileexport.SignalMetrics: {
+		method:     fileexport.MetricsMethod,
+		newRequest: func() proto.Message { return &colmetricspb.ExportMetricsServiceRequest{} },
+		newReply:   func() proto.Message { return &colmetricspb.ExportMetricsServiceResponse{} },
+	},
+	fileexport.SignalLogs: {
+		method:     fileexport.LogsMethod,
+		newRequest: func() proto.Message { return &collogspb.ExportLogsServiceRequest{} },
+		newReply:   func() proto.Message { return &collogspb.ExportLogsServiceResponse{} },
+	},
+}
+
+func main() {
+	endpoint := flag.String("endpoint", "localhost:4317", "OTLP/gRPC endpoint to send to")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout for each export request")
+	remove := flag.Bool("delete", false, "delete each file after all of its lines were sent")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] FILE_OR_DIR...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	files, err := fileexport.Files(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	cc, err := grpc.NewClient(*endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("failed to create gRPC client: %v", err)
+	}
+	defer cc.Close()
+
+	total := 0
+	for _, file := range files {
+		n, err := resend(ctx, cc, file, *timeout)
+		total += n
+		if err != nil {
+			// 途中から送り直すと重複するため、送信済みの行数を出力して止める
+			log.Fatalf("failed to resend %s after %d lines: %v", file, n, err)
+		}
+		slog.InfoContext(ctx, "Resent file", "file", file, "lines", n)
+		if *remove {
+			if err := os.Remove(file); err != nil {
+				log.Fatalf("failed to delete %s: %v", file, err)
+			}
+		}
+	}
+	slog.InfoContext(ctx, "Resend complete", "files", len(files), "lines", total, "endpoint", *endpoint)
+}
+
+// resend は file の各行を 1 回の Export として送信し、送信できた行数を返す
+func resend(ctx context.Context, cc *grpc.ClientConn, file string, timeout time.Duration) (int, error) {
+	sig, _ := fileexport.Signal(file)
+	sr := requests[sig]
+	n := 0
+	err := fileexport.ReadFile(file, func(line []byte) error {
+		req := sr.newRequest()
+		if err := otlpjson.Unmarshal(line, req); err != nil {
+			return fmt.Errorf("failed to decode line %d: %w", n+1, err)
+		}
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		if err := cc.Invoke(callCtx, sr.method, req, sr.newReply()); err != nil {
+			return fmt.Errorf("failed to export line %d: %w", n+1, err)
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,59 @@
+// routecheck は記録したトレース（OTLP/JSON）から、nginx のスパン名とアプリケーションの http.route が
+// 一致しないルートを報告する。不一致がある場合は終了コード 1 で終了する。
+//
+//	go run ./cmd/routecheck traces.jsonl
+//	cat traces.jsonl | go run ./cmd/routecheck -json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/routecheck"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print the result as JSON")
+	flag.Parse()
+
+	var readers []io.Reader
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("failed to open %s: %v", path, err)
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+	if len(readers) == 0 {
+		readers = append(readers, os.Stdin)
+	}
+
+	result, err := routecheck.Check(io.MultiReader(readers...))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		fmt.Printf("checked %d app spans with an nginx parent\n", result.Checked)
+		for _, m := range result.Mismatches {
+			fmt.Printf("MISMATCH %s: nginx span names %s (%d spans, e.g. trace %s)\n",
+				m.Want, strings.Join(m.NginxSpanNames, ", "), m.Count, m.TraceID)
+		}
+	}
+
+	if len(result.Mismatches) > 0 {
+		os.Exit(1)
+	}
+}
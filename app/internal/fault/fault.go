@@ -0,0 +1,338 @@
+// Package fault は chaos 実験用に、ルート単位で遅延・HTTP エラー・panic・接続断を
+// 確率的に注入する chi ミドルウェアを提供する。
+//
+// 注入ルールは管理 API（AdminRoutes）から実行時に差し替えられるほか、
+// HeadersEnabled が有効な場合はリクエストごとに X-Fault-* ヘッダーでも指定できる。
+// 注入した故障はアクティブなスパンに "fault.injected" イベントとして記録される。
+package fault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/response"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// リクエスト単位で故障を指定するためのヘッダー
+const (
+	HeaderDelay       = "X-Fault-Delay"       // 例: "500ms", "2s"
+	HeaderError       = "X-Fault-Error"       // 例: "503"
+	HeaderPanic       = "X-Fault-Panic"       // 例: "true"
+	HeaderAbort       = "X-Fault-Abort"       // 例: "true"
+	HeaderProbability = "X-Fault-Probability" // 上記ヘッダーの故障を発生させる確率（デフォルト 1）
+)
+
+// 故障の種類。スパンイベントの fault.type 属性にも利用する
+const (
+	TypeDelay = "delay"
+	TypeError = "error"
+	TypePanic = "panic"
+	TypeAbort = "abort"
+)
+
+// MaxDelay は注入する遅延の上限。ヘッダーで指定した遅延はこの値に切り詰め、ルールではこれを超える値を拒否する
+const MaxDelay = time.Minute
+
+// Rule は 1 つのルートに対する故障注入の設定
+type Rule struct {
+	// Route は chi のルートパターン（例: /users/{id}）。"*" または空文字は全ルートにマッチする
+	Route string `json:"route"`
+	// Method は HTTP メソッド。空文字は全メソッドにマッチする
+	Method string `json:"method,omitempty"`
+
+	DelayProbability float64 `json:"delay_probability,omitempty"`
+	DelayMS          int64   `json:"delay_ms,omitempty"`
+
+	ErrorProbability float64 `json:"error_probability,omitempty"`
+	ErrorStatus      int     `json:"error_status,omitempty"`
+
+	PanicProbability float64 `json:"panic_probability,omitempty"`
+	AbortProbability float64 `json:"abort_probability,omitempty"`
+}
+
+// Config は故障注入の全体設定
+type Config struct {
+	// HeadersEnabled が true の場合のみ X-Fault-* ヘッダーを解釈する
+	HeadersEnabled bool   `json:"headers_enabled"`
+	Rules          []Rule `json:"rules"`
+}
+
+// Validate は設定値の妥当性を検証する
+func (c Config) Validate() error {
+	for i, rule := range c.Rules {
+		for name, p := range map[string]float64{
+			"delay_probability": rule.DelayProbability,
+			"error_probability": rule.ErrorProbability,
+			"panic_probability": rule.PanicProbability,
+			"abort_probability": rule.AbortProbability,
+		} {
+			if p < 0 || p > 1 {
+				return fmt.Errorf("rules[%d].%s must be between 0 and 1: %v", i, name, p)
+			}
+		}
+		if rule.DelayMS < 0 || rule.DelayMS > MaxDelay.Milliseconds() {
+			return fmt.Errorf("rules[%d].delay_ms must be between 0 and %d: %d", i, MaxDelay.Milliseconds(), rule.DelayMS)
+		}
+		if rule.ErrorStatus != 0 && (rule.ErrorStatus < 400 || rule.ErrorStatus > 599) {
+			return fmt.Errorf("rules[%d].error_status must be 4xx or 5xx: %d", i, rule.ErrorStatus)
+		}
+	}
+	return nil
+}
+
+// Injector は故障注入の設定を保持し、ミドルウェアと管理 API を提供する
+type Injector struct {
+	mu     sync.RWMutex
+	config Config
+
+	// 確率判定に使う乱数。テストで差し替えられるようにしている
+	random func() float64
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// NewInjector は初期設定 cfg で Injector を作成する
+func NewInjector(cfg Config) (*Injector, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Injector{
+		config: cfg,
+		random: rand.Float64,
+		sleep:  sleep,
+	}, nil
+}
+
+// sleep は d だけ待つ。ctx が終了した場合（クライアントの切断など）は待たずにそのエラーを返す
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Config は現在の設定を返す
+func (in *Injector) Config() Config {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+
+	cfg := in.config
+	cfg.Rules = append([]Rule{}, in.config.Rules...)
+	return cfg
+}
+
+// SetConfig は設定をアトミックに差し替える
+func (in *Injector) SetConfig(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.config = cfg
+	return nil
+}
+
+// fault は 1 リクエストに対して注入する故障の内容
+type fault struct {
+	delay       time.Duration
+	errorStatus int
+	panic       bool
+	abort       bool
+	source      string // "rule" または "header"
+	route       string
+}
+
+// Middleware は故障注入を行う chi ミドルウェア。otelchi.Middleware より後に登録すること
+func (in *Injector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := in.decide(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		span := trace.SpanFromContext(r.Context())
+
+		if f.delay > 0 {
+			recordEvent(span, f, TypeDelay, attribute.Int64("fault.delay_ms", f.delay.Milliseconds()))
+			if err := in.sleep(r.Context(), f.delay); err != nil {
+				// クライアントが切断した場合は、後続の処理を行わずに終了する
+				return
+			}
+		}
+
+		switch {
+		case f.abort:
+			recordEvent(span, f, TypeAbort)
+			// http.ErrAbortHandler で panic すると net/http はレスポンスを返さずに接続を切断する
+			panic(http.ErrAbortHandler)
+		case f.panic:
+			recordEvent(span, f, TypePanic)
+			panic(fmt.Sprintf("fault injection: panic on %s %s", r.Method, f.route))
+		case f.errorStatus != 0:
+			recordEvent(span, f, TypeError, attribute.Int("fault.status_code", f.errorStatus))
+			response.WriteProblem(w, r, response.NewProblem(f.errorStatus,
+				"This error was injected by the fault injection middleware"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func recordEvent(span trace.Span, f fault, faultType string, attrs ...attribute.KeyValue) {
+	attrs = append(attrs,
+		attribute.String("fault.type", faultType),
+		attribute.String("fault.source", f.source),
+		attribute.String("fault.route", f.route),
+	)
+	span.AddEvent("fault.injected", trace.WithAttributes(attrs...))
+}
+
+// decide はリクエストに対して注入する故障を決定する。ヘッダー指定はルール設定より優先する
+func (in *Injector) decide(r *http.Request) (fault, bool) {
+	in.mu.RLock()
+	cfg := in.config
+	in.mu.RUnlock()
+
+	route := routePattern(r)
+
+	if cfg.HeadersEnabled {
+		if f, ok := in.fromHeaders(r, route); ok {
+			return f, true
+		}
+	}
+
+	for _, rule := range cfg.Rules {
+		if !rule.matches(r.Method, route) {
+			continue
+		}
+		f := fault{source: "rule", route: route}
+		if in.hit(rule.DelayProbability) {
+			f.delay = time.Duration(rule.DelayMS) * time.Millisecond
+		}
+		if in.hit(rule.ErrorProbability) {
+			f.errorStatus = rule.ErrorStatus
+			if f.errorStatus == 0 {
+				f.errorStatus = http.StatusInternalServerError
+			}
+		}
+		f.panic = in.hit(rule.PanicProbability)
+		f.abort = in.hit(rule.AbortProbability)
+		if f.delay > 0 || f.errorStatus != 0 || f.panic || f.abort {
+			return f, true
+		}
+	}
+	return fault{}, false
+}
+
+func (in *Injector) fromHeaders(r *http.Request, route string) (fault, bool) {
+	h := r.Header
+	if h.Get(HeaderDelay) == "" && h.Get(HeaderError) == "" && h.Get(HeaderPanic) == "" && h.Get(HeaderAbort) == "" {
+		return fault{}, false
+	}
+
+	probability := 1.0
+	if v := h.Get(HeaderProbability); v != "" {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil || p < 0 || p > 1 {
+			return fault{}, false
+		}
+		probability = p
+	}
+	if !in.hit(probability) {
+		return fault{}, false
+	}
+
+	f := fault{source: "header", route: route}
+	if v := h.Get(HeaderDelay); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			f.delay = min(d, MaxDelay)
+		}
+	}
+	if v := h.Get(HeaderError); v != "" {
+		if status, err := strconv.Atoi(v); err == nil && status >= 400 && status <= 599 {
+			f.errorStatus = status
+		}
+	}
+	f.panic, _ = strconv.ParseBool(h.Get(HeaderPanic))
+	f.abort, _ = strconv.ParseBool(h.Get(HeaderAbort))
+
+	return f, f.delay > 0 || f.errorStatus != 0 || f.panic || f.abort
+}
+
+func (in *Injector) hit(probability float64) bool {
+	return probability > 0 && in.random() < probability
+}
+
+func (rule Rule) matches(method, route string) bool {
+	if rule.Method != "" && !strings.EqualFold(rule.Method, method) {
+		return false
+	}
+	return rule.Route == "" || rule.Route == "*" || rule.Route == route
+}
+
+// routePattern はルーティング前のミドルウェアからでも chi のルートパターンを取得する
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return r.URL.Path
+	}
+	if pattern := rctx.RoutePattern(); pattern != "" {
+		return pattern
+	}
+	if rctx.Routes != nil {
+		if pattern := rctx.Routes.Find(chi.NewRouteContext(), r.Method, r.URL.Path); pattern != "" {
+			return pattern
+		}
+	}
+	return r.URL.Path
+}
+
+// AdminRoutes は故障注入設定を参照・変更する管理 API を返す
+//
+//	GET    /  現在の設定を返す
+//	PUT    /  設定を置き換える
+//	DELETE /  全ルールを削除し、ヘッダー指定を無効にする
+func (in *Injector) AdminRoutes() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/", in.getConfig)
+	r.Put("/", in.putConfig)
+	r.Delete("/", in.deleteConfig)
+	return r
+}
+
+func (in *Injector) getConfig(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, r, http.StatusOK, in.Config())
+}
+
+func (in *Injector) putConfig(w http.ResponseWriter, r *http.Request) {
+	var cfg Config
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		response.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := in.SetConfig(cfg); err != nil {
+		response.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	response.JSON(w, r, http.StatusOK, in.Config())
+}
+
+func (in *Injector) deleteConfig(w http.ResponseWriter, r *http.Request) {
+	_ = in.SetConfig(Config{})
+	response.JSON(w, r, http.StatusOK, in.Config())
+}
@@ -0,0 +1,329 @@
+package fault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// testRouter は in の Middleware を otelchi の代わりにスパンを開始するミドルウェアの後に登録したルーターを返す
+type testRouter struct {
+	http.Handler
+	spans  *tracetest.SpanRecorder
+	sleeps []time.Duration
+}
+
+func newTestRouter(t *testing.T, cfg Config, random float64) (*Injector, *testRouter) {
+	t.Helper()
+	in, err := NewInjector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &testRouter{spans: tracetest.NewSpanRecorder()}
+	in.random = func() float64 { return random }
+	in.sleep = func(_ context.Context, d time.Duration) error {
+		tr.sleeps = append(tr.sleeps, d)
+		return nil
+	}
+
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(tr.spans)).Tracer("test")
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracer.Start(r.Context(), r.URL.Path)
+			defer span.End()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Use(in.Middleware)
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	r.Post("/items/add", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	tr.Handler = r
+	return in, tr
+}
+
+func (tr *testRouter) do(method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	tr.ServeHTTP(rec, req)
+	return rec
+}
+
+// faultEvents は記録された fault.injected イベントの属性を返す
+func (tr *testRouter) faultEvents() []map[attribute.Key]attribute.Value {
+	var events []map[attribute.Key]attribute.Value
+	for _, span := range tr.spans.Ended() {
+		for _, ev := range span.Events() {
+			if ev.Name != "fault.injected" {
+				continue
+			}
+			attrs := make(map[attribute.Key]attribute.Value)
+			for _, kv := range ev.Attributes {
+				attrs[kv.Key] = kv.Value
+			}
+			events = append(events, attrs)
+		}
+	}
+	return events
+}
+
+func TestMiddleware_Rules(t *testing.T) {
+	tests := []struct {
+		name       string
+		rule       Rule
+		method     string
+		path       string
+		random     float64
+		wantStatus int
+		wantSleep  time.Duration
+		wantType   string
+	}{
+		{
+			name:       "error with default status",
+			rule:       Rule{Route: "/users/{id}", ErrorProbability: 1},
+			method:     http.MethodGet,
+			path:       "/users/1",
+			wantStatus: http.StatusInternalServerError,
+			wantType:   TypeError,
+		},
+		{
+			name:       "error with status",
+			rule:       Rule{Route: "*", ErrorProbability: 1, ErrorStatus: 503},
+			method:     http.MethodGet,
+			path:       "/users/1",
+			wantStatus: http.StatusServiceUnavailable,
+			wantType:   TypeError,
+		},
+		{
+			name:       "delay",
+			rule:       Rule{Route: "/users/{id}", DelayProbability: 1, DelayMS: 250},
+			method:     http.MethodGet,
+			path:       "/users/1",
+			wantStatus: http.StatusOK,
+			wantSleep:  250 * time.Millisecond,
+			wantType:   TypeDelay,
+		},
+		{
+			name:       "other route does not match",
+			rule:       Rule{Route: "/items/add", ErrorProbability: 1},
+			method:     http.MethodGet,
+			path:       "/users/1",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "other method does not match",
+			rule:       Rule{Route: "/users/{id}", Method: http.MethodPost, ErrorProbability: 1},
+			method:     http.MethodGet,
+			path:       "/users/1",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "method matches case-insensitively",
+			rule:       Rule{Route: "/items/add", Method: "post", ErrorProbability: 1},
+			method:     http.MethodPost,
+			path:       "/items/add",
+			wantStatus: http.StatusInternalServerError,
+			wantType:   TypeError,
+		},
+		{
+			name:       "probability not hit",
+			rule:       Rule{Route: "*", ErrorProbability: 0.5},
+			method:     http.MethodGet,
+			path:       "/users/1",
+			random:     0.5,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "probability hit",
+			rule:       Rule{Route: "*", ErrorProbability: 0.5},
+			method:     http.MethodGet,
+			path:       "/users/1",
+			random:     0.49,
+			wantStatus: http.StatusInternalServerError,
+			wantType:   TypeError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, tr := newTestRouter(t, Config{Rules: []Rule{tt.rule}}, tt.random)
+			rec := tr.do(tt.method, tt.path, nil)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantSleep != 0 && (len(tr.sleeps) != 1 || tr.sleeps[0] != tt.wantSleep) {
+				t.Errorf("sleeps = %v, want [%v]", tr.sleeps, tt.wantSleep)
+			}
+			events := tr.faultEvents()
+			if tt.wantType == "" {
+				if len(events) != 0 {
+					t.Errorf("fault events = %v, want none", events)
+				}
+				return
+			}
+			if len(events) != 1 {
+				t.Fatalf("fault events = %v, want 1", events)
+			}
+			ev := events[0]
+			if ev["fault.type"].AsString() != tt.wantType || ev["fault.source"].AsString() != "rule" {
+				t.Errorf("event = %v, want type %s from rule", ev, tt.wantType)
+			}
+			if got := ev["fault.route"].AsString(); got != tt.rule.Route && tt.rule.Route != "*" {
+				t.Errorf("fault.route = %q, want %q", got, tt.rule.Route)
+			}
+		})
+	}
+}
+
+func TestMiddleware_DelayCanceled(t *testing.T) {
+	in, err := NewInjector(Config{HeadersEnabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	called := false
+	handler := in.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	// クライアントが切断すると遅延を打ち切り、後続のハンドラーを呼び出さない
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil).WithContext(ctx)
+	req.Header.Set(HeaderDelay, "30s")
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("delay took %v after cancel, want it to stop early", elapsed)
+	}
+	if called {
+		t.Error("next handler was called after the client went away")
+	}
+}
+
+func TestMiddleware_Headers(t *testing.T) {
+	header := func(kv ...string) http.Header {
+		h := http.Header{}
+		for i := 0; i < len(kv); i += 2 {
+			h.Set(kv[i], kv[i+1])
+		}
+		return h
+	}
+
+	t.Run("ignored when disabled", func(t *testing.T) {
+		_, tr := newTestRouter(t, Config{}, 0)
+		if rec := tr.do(http.MethodGet, "/users/1", header(HeaderError, "503")); rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want 200", rec.Code)
+		}
+	})
+
+	tests := []struct {
+		name       string
+		header     http.Header
+		random     float64
+		wantStatus int
+		wantSleep  time.Duration
+	}{
+		{"error", header(HeaderError, "503"), 0, http.StatusServiceUnavailable, 0},
+		{"delay and error", header(HeaderDelay, "2s", HeaderError, "429"), 0, http.StatusTooManyRequests, 2 * time.Second},
+		{"invalid status is ignored", header(HeaderError, "200"), 0, http.StatusOK, 0},
+		{"invalid delay is ignored", header(HeaderDelay, "soon"), 0, http.StatusOK, 0},
+		{"delay is capped", header(HeaderDelay, "1000h"), 0, http.StatusOK, MaxDelay},
+		{"probability hit", header(HeaderError, "500", HeaderProbability, "0.3"), 0.2, http.StatusInternalServerError, 0},
+		{"probability not hit", header(HeaderError, "500", HeaderProbability, "0.3"), 0.3, http.StatusOK, 0},
+		{"invalid probability", header(HeaderError, "500", HeaderProbability, "2"), 0, http.StatusOK, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// ヘッダーの指定はルールより優先する
+			_, tr := newTestRouter(t, Config{HeadersEnabled: true}, tt.random)
+			rec := tr.do(http.MethodGet, "/users/1", tt.header)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantSleep != 0 && (len(tr.sleeps) != 1 || tr.sleeps[0] != tt.wantSleep) {
+				t.Errorf("sleeps = %v, want [%v]", tr.sleeps, tt.wantSleep)
+			}
+			for _, ev := range tr.faultEvents() {
+				if ev["fault.source"].AsString() != "header" || ev["fault.route"].AsString() != "/users/{id}" {
+					t.Errorf("event = %v, want source header on /users/{id}", ev)
+				}
+			}
+		})
+	}
+
+	t.Run("headers take precedence over rules", func(t *testing.T) {
+		_, tr := newTestRouter(t, Config{HeadersEnabled: true, Rules: []Rule{{Route: "*", ErrorProbability: 1, ErrorStatus: 500}}}, 0)
+		if rec := tr.do(http.MethodGet, "/users/1", header(HeaderError, "418")); rec.Code != http.StatusTeapot {
+			t.Errorf("status = %d, want 418", rec.Code)
+		}
+	})
+}
+
+func TestMiddleware_Abort(t *testing.T) {
+	_, tr := newTestRouter(t, Config{Rules: []Rule{{Route: "*", AbortProbability: 1}}}, 0)
+	defer func() {
+		if got := recover(); got != http.ErrAbortHandler {
+			t.Errorf("recover() = %v, want http.ErrAbortHandler", got)
+		}
+		events := tr.faultEvents()
+		if len(events) != 1 || events[0]["fault.type"].AsString() != TypeAbort {
+			t.Errorf("fault events = %v, want abort", events)
+		}
+	}()
+	tr.do(http.MethodGet, "/users/1", nil)
+}
+
+func TestAdminRoutes(t *testing.T) {
+	in, _ := newTestRouter(t, Config{}, 0)
+	admin := in.AdminRoutes()
+	do := func(method, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest(method, "/", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do(http.MethodPut, `{"headers_enabled":true,"rules":[{"route":"/users/{id}","error_probability":0.5,"error_status":503}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d: %s", rec.Code, rec.Body)
+	}
+	var cfg Config
+	if err := json.Unmarshal(do(http.MethodGet, "").Body.Bytes(), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.HeadersEnabled || len(cfg.Rules) != 1 || cfg.Rules[0].ErrorStatus != 503 {
+		t.Errorf("config = %+v, want the PUT config", cfg)
+	}
+
+	// 不正な設定は拒否し、現在の設定を変更しない
+	for _, body := range []string{
+		`{"rules":[{"route":"*","error_probability":1.5}]}`,
+		`{"rules":[{"route":"*","delay_ms":-1}]}`,
+		`{"rules":[{"route":"*","delay_ms":3600000}]}`,
+		`{"rules":[{"route":"*","error_status":200}]}`,
+		`{"rules":`,
+	} {
+		if rec := do(http.MethodPut, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s status = %d, want 400", body, rec.Code)
+		}
+	}
+	if got := in.Config(); len(got.Rules) != 1 {
+		t.Errorf("config after invalid PUT = %+v, want unchanged", got)
+	}
+
+	if rec := do(http.MethodDelete, ""); rec.Code != http.StatusOK {
+		t.Fatalf("DELETE status = %d", rec.Code)
+	}
+	if got := in.Config(); got.HeadersEnabled || len(got.Rules) != 0 {
+		t.Errorf("config after DELETE = %+v, want empty", got)
+	}
+}
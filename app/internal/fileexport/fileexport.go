@@ -0,0 +1,165 @@
+// Package fileexport はトレース・メトリクス・ログを OTLP/JSON の JSON Lines としてファイルに書き出す。
+// ネットワークに接続できない環境で記録し、後から otlpresend コマンドで OTLP のエンドポイントに送り直せる。
+//
+// SDK のデータを OTLP の protobuf に変換する処理は公開されていないため、OTLP/gRPC エクスポーターが
+// 送信しようとしたリクエストをインターセプターで横取りしてファイルに書き込む。gRPC の接続は確立しない。
+package fileexport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/otlpjson"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// 既定値
+const (
+	DefaultMaxBytes = 100 << 20
+	DefaultMaxAge   = time.Hour
+)
+
+// シグナルごとのファイル名の接頭辞
+const (
+	SignalTraces  = "traces"
+	SignalMetrics = "metrics"
+	SignalLogs    = "logs"
+)
+
+// シグナルごとの OTLP/gRPC の Export メソッド。生成されたサービス定義から組み立てる
+var (
+	TraceMethod   = exportMethod(&coltracepb.TraceService_ServiceDesc)
+	MetricsMethod = exportMethod(&colmetricspb.MetricsService_ServiceDesc)
+	LogsMethod    = exportMethod(&collogspb.LogsService_ServiceDesc)
+)
+
+// exportMethod は desc の唯一のメソッド（Export）の完全なメソッド名を返す
+func exportMethod(desc *grpc.ServiceDesc) string {
+	return "/" + desc.ServiceName + "/" + desc.Methods[0].MethodName
+}
+
+// endpoint はエクスポーターに渡す接続先。インターセプターが送信前に横取りするため接続しない
+const endpoint = "passthrough:///otlp-file"
+
+// Options はファイルの出力先とローテーションの設定
+type Options struct {
+	// Dir は出力先のディレクトリ。存在しない場合は作成する
+	Dir string
+	// MaxBytes はファイルを切り替える圧縮前のサイズ。0 の場合は DefaultMaxBytes
+	MaxBytes int64
+	// MaxAge はファイルを切り替える経過時間。0 の場合は DefaultMaxAge
+	MaxAge time.Duration
+	// Gzip を true にすると gzip で圧縮して .jsonl.gz に書き込む
+	Gzip bool
+}
+
+// Exporter はシグナルごとのファイルに書き込むエクスポーターを作成する
+type Exporter struct {
+	writers map[string]*Writer
+}
+
+// New は opts.Dir に書き込む Exporter を作成する
+func New(opts Options) (*Exporter, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("export directory is required")
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = DefaultMaxAge
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	return &Exporter{writers: map[string]*Writer{
+		TraceMethod:   newWriter(opts.Dir, SignalTraces, opts),
+		MetricsMethod: newWriter(opts.Dir, SignalMetrics, opts),
+		LogsMethod:    newWriter(opts.Dir, SignalLogs, opts),
+	}}, nil
+}
+
+// SpanExporter は traces-*.jsonl に書き込む SpanExporter を返す
+func (e *Exporter) SpanExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	exp, err := otlptracegrpc.New(ctx,
+		otlptracegrpc.WithEndpoint(endpoint),
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithDialOption(e.dialOption()),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file trace exporter: %w", err)
+	}
+	return exp, nil
+}
+
+// MetricExporter は metrics-*.jsonl に書き込む Exporter を返す
+func (e *Exporter) MetricExporter(ctx context.Context) (sdkmetric.Exporter, error) {
+	exp, err := otlpmetricgrpc.New(ctx,
+		otlpmetricgrpc.WithEndpoint(endpoint),
+		otlpmetricgrpc.WithInsecure(),
+		otlpmetricgrpc.WithDialOption(e.dialOption()),
+		otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig{Enabled: false}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file metric exporter: %w", err)
+	}
+	return exp, nil
+}
+
+// LogExporter は logs-*.jsonl に書き込む Exporter を返す
+func (e *Exporter) LogExporter(ctx context.Context) (sdklog.Exporter, error) {
+	exp, err := otlploggrpc.New(ctx,
+		otlploggrpc.WithEndpoint(endpoint),
+		otlploggrpc.WithInsecure(),
+		otlploggrpc.WithDialOption(e.dialOption()),
+		otlploggrpc.WithRetry(otlploggrpc.RetryConfig{Enabled: false}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file log exporter: %w", err)
+	}
+	return exp, nil
+}
+
+// Close は書き込み中のファイルを閉じる。プロバイダーのシャットダウン後に呼び出す
+func (e *Exporter) Close() error {
+	var errs []error
+	for _, w := range e.writers {
+		errs = append(errs, w.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (e *Exporter) dialOption() grpc.DialOption {
+	return grpc.WithUnaryInterceptor(e.intercept)
+}
+
+// intercept は OTLP の Export を送信せずに、リクエストを 1 行の OTLP/JSON としてファイルに書き込む
+func (e *Exporter) intercept(_ context.Context, method string, req, _ any, _ *grpc.ClientConn, _ grpc.UnaryInvoker, _ ...grpc.CallOption) error {
+	w, ok := e.writers[method]
+	if !ok {
+		return fmt.Errorf("unsupported OTLP method %q", method)
+	}
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return fmt.Errorf("unexpected request type %T", req)
+	}
+	line, err := otlpjson.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", w.signal, err)
+	}
+	return w.WriteLine(line)
+}
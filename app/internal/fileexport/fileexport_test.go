@@ -0,0 +1,119 @@
+package fileexport
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/otlpjson"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+)
+
+func TestExporter_WritesOTLPJSONLines(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	e, err := New(Options{Dir: dir, Gzip: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spanExp, err := e.SpanExporter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExp))
+	_, span := tp.Tracer("test").Start(ctx, "first")
+	span.End()
+	_, span2 := tp.Tracer("test").Start(ctx, "second")
+	span2.End()
+	if err := tp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Files([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || !strings.HasPrefix(filepath.Base(files[0]), "traces-") || !strings.HasSuffix(files[0], ".jsonl.gz") {
+		t.Fatalf("files = %v, want one traces-*.jsonl.gz", files)
+	}
+
+	// WithSyncer のため 1 スパンが 1 行になる
+	spans := []sdktrace.ReadOnlySpan{span.(sdktrace.ReadOnlySpan), span2.(sdktrace.ReadOnlySpan)}
+	var names []string
+	err = ReadFile(files[0], func(line []byte) error {
+		want := spans[len(names)].SpanContext()
+		// OTLP/JSON では ID を 16 進数で書く
+		if !strings.Contains(string(line), `"traceId":"`+want.TraceID().String()+`"`) {
+			t.Errorf("line does not contain hex trace ID %s: %s", want.TraceID(), line)
+		}
+		var req coltracepb.ExportTraceServiceRequest
+		if err := otlpjson.Unmarshal(line, &req); err != nil {
+			return err
+		}
+		s := req.GetResourceSpans()[0].GetScopeSpans()[0].GetSpans()[0]
+		if got := hex.EncodeToString(s.GetSpanId()); got != want.SpanID().String() {
+			t.Errorf("span ID = %s, want %s", got, want.SpanID())
+		}
+		names = append(names, s.GetName())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "first,second" {
+		t.Errorf("spans = %v, want [first second]", names)
+	}
+}
+
+func TestWriter_Rotates(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := newWriter(dir, SignalLogs, Options{MaxBytes: 10, MaxAge: time.Minute})
+	w.now = func() time.Time { return now }
+
+	write := func(line string) {
+		t.Helper()
+		if err := w.WriteLine([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("aaaa") // 5 バイト
+	now = now.Add(time.Second)
+	write("bbbb") // 10 バイトまでは同じファイル
+	now = now.Add(time.Second)
+	write("cccc") // サイズの上限で切り替える
+	now = now.Add(time.Minute)
+	write("dddd") // 経過時間の上限で切り替える
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Files([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"aaaa\nbbbb\n", "cccc\n", "dddd\n"}
+	if len(files) != len(want) {
+		t.Fatalf("files = %v, want %d files", files, len(want))
+	}
+	for i, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want[i] {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), data, want[i])
+		}
+		if signal, _ := Signal(file); signal != SignalLogs {
+			t.Errorf("Signal(%s) = %q, want logs", file, signal)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package fileexport
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxLineSize は読み込む 1 行の上限。1 行には 1 回のエクスポート（バッチ）が入る
+const maxLineSize = 64 << 20
+
+// Signal は path のファイル名からシグナルを返す。エクスポートしたファイルでない場合は ok が false
+func Signal(path string) (signal string, ok bool) {
+	name := filepath.Base(path)
+	if !strings.HasSuffix(name, ext) && !strings.HasSuffix(name, extGzip) {
+		return "", false
+	}
+	for _, signal := range []string{SignalTraces, SignalMetrics, SignalLogs} {
+		if strings.HasPrefix(name, signal+"-") {
+			return signal, true
+		}
+	}
+	return "", false
+}
+
+// Files は paths に含まれるファイルと、ディレクトリ直下のエクスポートしたファイルを書き込んだ時刻の順に返す
+func Files(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			if _, ok := Signal(path); !ok {
+				return nil, fmt.Errorf("%s is not an exported OTLP JSON lines file", path)
+			}
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		for _, entry := range entries {
+			if _, ok := Signal(entry.Name()); ok && !entry.IsDir() {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	// ファイル名の時刻で並べる
+	sort.SliceStable(files, func(i, j int) bool { return fileTime(files[i]) < fileTime(files[j]) })
+	return files, nil
+}
+
+func fileTime(path string) string {
+	name := filepath.Base(path)
+	_, after, _ := strings.Cut(name, "-")
+	return after
+}
+
+// ReadFile は path の各行を fn に渡す。.gz のファイルは展開して読む。
+// 書き込み中に終了して末尾が欠けた gzip は、読めた行までを渡して終わる
+func ReadFile(path string, fn func(line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package fileexport
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ファイル名の時刻の書式。名前順がそのまま時刻順になる
+const fileTimeFormat = "20060102T150405.000000000Z"
+
+// 拡張子
+const (
+	ext     = ".jsonl"
+	extGzip = ".jsonl.gz"
+)
+
+// Writer は <signal>-<時刻>.jsonl[.gz] に 1 行ずつ書き込み、サイズか経過時間が上限に達したら新しいファイルに切り替える
+type Writer struct {
+	dir    string
+	signal string
+	opts   Options
+	now    func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	gz     *gzip.Writer
+	w      io.Writer
+	size   int64
+	opened time.Time
+}
+
+func newWriter(dir, signal string, opts Options) *Writer {
+	return &Writer{dir: dir, signal: signal, opts: opts, now: time.Now}
+}
+
+// WriteLine は line と改行を書き込む。gzip の場合も 1 行ごとに書き出すため、途中で終了しても書き込んだ行は読める
+func (w *Writer) WriteLine(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file != nil && w.shouldRotate(int64(len(line))+1) {
+		if err := w.closeLocked(); err != nil {
+			return err
+		}
+	}
+	if w.file == nil {
+		if err := w.openLocked(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write %s: %w", w.file.Name(), err)
+	}
+	if w.gz != nil {
+		if err := w.gz.Flush(); err != nil {
+			return fmt.Errorf("failed to flush %s: %w", w.file.Name(), err)
+		}
+	}
+	w.size += int64(len(line)) + 1
+	return nil
+}
+
+// shouldRotate は n バイト書き込むと上限を超える場合に true を返す。サイズは圧縮前で数える
+func (w *Writer) shouldRotate(n int64) bool {
+	if w.size > 0 && w.size+n > w.opts.MaxBytes {
+		return true
+	}
+	return w.now().Sub(w.opened) >= w.opts.MaxAge
+}
+
+func (w *Writer) openLocked() error {
+	now := w.now()
+	name := w.signal + "-" + now.UTC().Format(fileTimeFormat) + ext
+	if w.opts.Gzip {
+		name = w.signal + "-" + now.UTC().Format(fileTimeFormat) + extGzip
+	}
+	f, err := os.OpenFile(filepath.Join(w.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	w.file, w.w, w.size, w.opened = f, f, 0, now
+	if w.opts.Gzip {
+		w.gz = gzip.NewWriter(f)
+		w.w = w.gz
+	}
+	return nil
+}
+
+func (w *Writer) closeLocked() error {
+	if w.file == nil {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		err = w.gz.Close()
+	}
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file, w.gz, w.w = nil, nil, nil
+	if err != nil {
+		return fmt.Errorf("failed to close export file: %w", err)
+	}
+	return nil
+}
+
+// Close は書き込み中のファイルを閉じる。その後に書き込むと新しいファイルを作成する
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closeLocked()
+}
@@ -0,0 +1,293 @@
+// Package health は liveness / readiness / startup プローブを提供する。
+//
+// プローブごとにチェッカーを登録し、各チェックの結果をタイムアウト付きで実行してキャッシュする。
+// プローブは失敗したチェックを含めて JSON で返し、1 つでも失敗していれば 503 を返す。
+// AddInfo で登録したチェックは readiness / startup の結果に詳細として含めるだけで、失敗しても 503 にしない。
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/response"
+)
+
+// チェックとプローブの状態
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// プローブのパス
+const (
+	LivePath    = "/livez"
+	ReadyPath   = "/readyz"
+	StartupPath = "/startupz"
+)
+
+// 既定のタイムアウトとキャッシュ期間
+const (
+	DefaultTimeout  = 2 * time.Second
+	DefaultCacheTTL = 5 * time.Second
+)
+
+// Checker は依存先の状態を確認する。正常でなければエラーを返す
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc は関数を Checker として使うためのアダプター
+type CheckerFunc func(ctx context.Context) error
+
+// Check は f(ctx) を呼び出す
+func (f CheckerFunc) Check(ctx context.Context) error { return f(ctx) }
+
+// Options はチェックの実行設定。0 の場合は既定値を使う
+type Options struct {
+	// Timeout は 1 回のチェックの制限時間
+	Timeout time.Duration
+	// CacheTTL の間は前回の結果を返し、チェッカーを呼び出さない
+	CacheTTL time.Duration
+}
+
+// Result は 1 つのチェックの結果
+type Result struct {
+	Status    string        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration_ns"`
+	CheckedAt time.Time     `json:"checked_at"`
+	Cached    bool          `json:"cached"`
+}
+
+// Report はプローブの結果
+type Report struct {
+	Status string            `json:"status"`
+	Reason string            `json:"reason,omitempty"`
+	Checks map[string]Result `json:"checks,omitempty"`
+	// Info は Status に影響しないチェックの結果
+	Info map[string]Result `json:"info,omitempty"`
+}
+
+// Health はプローブごとのチェッカーと、起動・停止の状態を保持する
+type Health struct {
+	opts Options
+	now  func() time.Time
+
+	mu        sync.RWMutex
+	liveness  []*check
+	readiness []*check
+	startup   []*check
+	info      []*check
+	started   bool
+	stopping  bool
+}
+
+type check struct {
+	name    string
+	checker Checker
+
+	mu   sync.Mutex
+	last Result
+}
+
+// New は opts でチェックを実行する Health を作成する
+func New(opts Options) *Health {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = DefaultCacheTTL
+	}
+	return &Health{opts: opts, now: time.Now}
+}
+
+// AddLiveness はプロセスを再起動すべき状態を検出するチェッカーを登録する
+func (h *Health) AddLiveness(name string, c Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.liveness = append(h.liveness, &check{name: name, checker: c})
+}
+
+// AddReadiness はリクエストを受け付けられない状態を検出するチェッカーを登録する
+func (h *Health) AddReadiness(name string, c Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness = append(h.readiness, &check{name: name, checker: c})
+}
+
+// AddStartup は起動の完了を判定するチェッカーを登録する
+func (h *Health) AddStartup(name string, c Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.startup = append(h.startup, &check{name: name, checker: c})
+}
+
+// AddInfo は readiness と startup の結果に詳細として含めるチェッカーを登録する。
+// 失敗してもプローブは失敗しない。停止していても処理を続けられる依存先の確認に使う
+func (h *Health) AddInfo(name string, c Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.info = append(h.info, &check{name: name, checker: c})
+}
+
+// MarkStarted は初期化の完了を記録する。以降、startup のチェッカーがすべて成功すると起動済みになる
+func (h *Health) MarkStarted() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.started = true
+}
+
+// Shutdown はグレースフルシャットダウンの開始を記録する。以降、readiness は失敗を返す
+func (h *Health) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopping = true
+}
+
+// Live は liveness のチェック結果を返す
+func (h *Health) Live(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := h.liveness
+	h.mu.RUnlock()
+	return h.run(ctx, checks)
+}
+
+// Ready は readiness のチェック結果を返す。起動前とシャットダウン中は失敗する
+func (h *Health) Ready(ctx context.Context) Report {
+	h.mu.RLock()
+	checks, info, started, stopping := h.readiness, h.info, h.started, h.stopping
+	h.mu.RUnlock()
+	switch {
+	case stopping:
+		return Report{Status: StatusFail, Reason: "shutting down"}
+	case !started:
+		return Report{Status: StatusFail, Reason: "starting"}
+	}
+	report := h.run(ctx, checks)
+	report.Info = h.results(ctx, info)
+	return report
+}
+
+// Startup は startup のチェック結果を返す。MarkStarted の前は失敗する
+func (h *Health) Startup(ctx context.Context) Report {
+	h.mu.RLock()
+	checks, info, started := h.startup, h.info, h.started
+	h.mu.RUnlock()
+	if !started {
+		return Report{Status: StatusFail, Reason: "starting"}
+	}
+	report := h.run(ctx, checks)
+	report.Info = h.results(ctx, info)
+	return report
+}
+
+// run はチェックを実行し、1 つでも失敗していれば失敗にする
+func (h *Health) run(ctx context.Context, checks []*check) Report {
+	report := Report{Status: StatusOK, Checks: h.results(ctx, checks)}
+	for _, r := range report.Checks {
+		if r.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+// results はチェックを並行に実行し、名前ごとの結果を返す。チェックがなければ nil を返す
+func (h *Health) results(ctx context.Context, checks []*check) map[string]Result {
+	if len(checks) == 0 {
+		return nil
+	}
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = h.result(ctx, c)
+		}()
+	}
+	wg.Wait()
+	byName := make(map[string]Result, len(checks))
+	for i, c := range checks {
+		byName[c.name] = results[i]
+	}
+	return byName
+}
+
+// result は CacheTTL 以内の結果があればそれを返し、なければタイムアウト付きでチェッカーを呼び出す。
+// チェッカーは呼び出し元のキャンセルから切り離して実行し、呼び出し元がキャンセルした場合の結果はキャッシュしない
+func (h *Health) result(ctx context.Context, c *check) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.last.CheckedAt.IsZero() && h.now().Sub(c.last.CheckedAt) < h.opts.CacheTTL {
+		cached := c.last
+		cached.Cached = true
+		return cached
+	}
+
+	// 呼び出し元が先に戻っても、チェッカーはタイムアウトまで実行を続ける
+	checkCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), h.opts.Timeout)
+	start := h.now()
+	// チェッカーが ctx を無視しても、タイムアウトで結果を返す
+	done := make(chan error, 1)
+	go func() {
+		defer cancel()
+		done <- c.checker.Check(checkCtx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-checkCtx.Done():
+		err = fmt.Errorf("timed out after %s", h.opts.Timeout)
+	case <-ctx.Done():
+		return Result{Status: StatusFail, Error: ctx.Err().Error(), Duration: h.now().Sub(start), CheckedAt: h.now()}
+	}
+
+	c.last = Result{Status: StatusOK, Duration: h.now().Sub(start), CheckedAt: h.now()}
+	if err != nil {
+		c.last.Status = StatusFail
+		c.last.Error = err.Error()
+	}
+	return c.last
+}
+
+// Livez は liveness の結果を返すハンドラー
+func (h *Health) Livez(w http.ResponseWriter, r *http.Request) { h.write(w, r, h.Live(r.Context())) }
+
+// Readyz は readiness の結果を返すハンドラー
+func (h *Health) Readyz(w http.ResponseWriter, r *http.Request) { h.write(w, r, h.Ready(r.Context())) }
+
+// Startupz は startup の結果を返すハンドラー
+func (h *Health) Startupz(w http.ResponseWriter, r *http.Request) {
+	h.write(w, r, h.Startup(r.Context()))
+}
+
+// write は report を返す。失敗している場合は 503 にする
+func (h *Health) write(w http.ResponseWriter, r *http.Request, report Report) {
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	response.JSON(w, r, status, report)
+}
+
+// Paths はプローブのパスを返す
+func Paths() []string {
+	return []string{LivePath, ReadyPath, StartupPath}
+}
+
+// DialChecker は addr に TCP で接続できるかを確認する。OTLP エクスポーターの送信先の確認に使う
+func DialChecker(addr string) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to connect to %s: %w", addr, err)
+		}
+		return conn.Close()
+	})
+}
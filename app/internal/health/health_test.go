@@ -0,0 +1,127 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealth_Probes(t *testing.T) {
+	h := New(Options{})
+	var failing bool
+	h.AddReadiness("exporter", CheckerFunc(func(context.Context) error {
+		if failing {
+			return errors.New("connection refused")
+		}
+		return nil
+	}))
+	ctx := context.Background()
+
+	if got := h.Startup(ctx); got.Status != StatusFail || got.Reason != "starting" {
+		t.Errorf("startup before MarkStarted = %+v", got)
+	}
+	if got := h.Ready(ctx); got.Status != StatusFail {
+		t.Errorf("ready before MarkStarted = %+v", got)
+	}
+	if got := h.Live(ctx); got.Status != StatusOK {
+		t.Errorf("live = %+v", got)
+	}
+
+	h.MarkStarted()
+	if got := h.Ready(ctx); got.Status != StatusOK || got.Checks["exporter"].Status != StatusOK {
+		t.Errorf("ready = %+v", got)
+	}
+
+	// キャッシュ期間を過ぎると再チェックする
+	now := time.Now()
+	h.now = func() time.Time { return now }
+	failing = true
+	if got := h.Ready(ctx); got.Status != StatusOK || !got.Checks["exporter"].Cached {
+		t.Errorf("ready within cache TTL = %+v, want cached ok", got)
+	}
+	now = now.Add(DefaultCacheTTL)
+	if got := h.Ready(ctx); got.Status != StatusFail || got.Checks["exporter"].Error != "connection refused" {
+		t.Errorf("ready after cache TTL = %+v, want fail", got)
+	}
+
+	h.Shutdown()
+	if got := h.Ready(ctx); got.Status != StatusFail || got.Reason != "shutting down" {
+		t.Errorf("ready during shutdown = %+v", got)
+	}
+	if got := h.Live(ctx); got.Status != StatusOK {
+		t.Errorf("live during shutdown = %+v, want ok", got)
+	}
+}
+
+func TestHealth_Info(t *testing.T) {
+	h := New(Options{})
+	h.AddInfo("exporter", CheckerFunc(func(context.Context) error {
+		return errors.New("connection refused")
+	}))
+	h.MarkStarted()
+
+	// 詳細のチェックが失敗しても readiness と startup は失敗しない
+	rec := httptest.NewRecorder()
+	h.Readyz(rec, httptest.NewRequest(http.MethodGet, ReadyPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"info":{"exporter":{"status":"fail","error":"connection refused"`) {
+		t.Errorf("body = %s, want exporter info", rec.Body.String())
+	}
+	if got := h.Startup(context.Background()); got.Status != StatusOK || got.Info["exporter"].Status != StatusFail {
+		t.Errorf("startup = %+v, want ok with failed exporter info", got)
+	}
+	if got := h.Live(context.Background()); got.Info != nil {
+		t.Errorf("live info = %+v, want none", got.Info)
+	}
+}
+
+func TestHealth_Timeout(t *testing.T) {
+	h := New(Options{Timeout: 10 * time.Millisecond})
+	block := make(chan struct{})
+	defer close(block)
+	// ctx を無視するチェッカーでもタイムアウトで失敗する
+	h.AddLiveness("stuck", CheckerFunc(func(context.Context) error {
+		<-block
+		return nil
+	}))
+
+	rec := httptest.NewRecorder()
+	h.Livez(rec, httptest.NewRequest(http.MethodGet, LivePath, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "timed out") {
+		t.Errorf("body = %s, want timeout detail", rec.Body.String())
+	}
+}
+
+func TestHealth_CallerCanceled(t *testing.T) {
+	h := New(Options{})
+	block := make(chan struct{})
+	checked := make(chan error, 2)
+	h.AddLiveness("exporter", CheckerFunc(func(ctx context.Context) error {
+		<-block
+		checked <- ctx.Err()
+		return ctx.Err()
+	}))
+
+	// 呼び出し元のキャンセルはチェッカーに伝えず、キャンセルされた結果もキャッシュしない
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := h.Live(ctx); got.Status != StatusFail || got.Checks["exporter"].Error != context.Canceled.Error() {
+		t.Errorf("live with canceled caller = %+v, want canceled failure", got)
+	}
+	close(block)
+	if err := <-checked; err != nil {
+		t.Errorf("checker ctx error = %v, want nil", err)
+	}
+	if got := h.Live(context.Background()); got.Status != StatusOK || got.Checks["exporter"].Cached {
+		t.Errorf("live after canceled caller = %+v, want fresh ok", got)
+	}
+}
@@ -0,0 +1,130 @@
+package loadgen
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestReadRequests(t *testing.T) {
+	requests, err := ReadRequests(strings.NewReader(`
+# comment
+{"path": "/hello"}
+{"name": "add", "method": "post", "path": "/items/add", "weight": 3}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("requests = %d, want 2", len(requests))
+	}
+	if got := requests[0]; got.Name != "GET /hello" || got.Method != http.MethodGet || got.Weight != 1 {
+		t.Errorf("requests[0] = %+v", got)
+	}
+	if got := requests[1]; got.Method != http.MethodPost || got.Weight != 3 {
+		t.Errorf("requests[1] = %+v", got)
+	}
+
+	for _, in := range []string{``, `{"path": "hello"}`, `{"path": "/", "weight": -1}`, `{`} {
+		if _, err := ReadRequests(strings.NewReader(in)); err == nil {
+			t.Errorf("ReadRequests(%q) error = nil, want error", in)
+		}
+	}
+}
+
+func TestNewRunner_InvalidRate(t *testing.T) {
+	for _, rate := range []float64{-1, 2e9, math.NaN()} {
+		if _, err := NewRunner(Options{BaseURL: "http://localhost", Rate: rate, Count: 1}); err == nil {
+			t.Errorf("NewRunner(Rate: %v) error = nil, want error", rate)
+		}
+	}
+}
+
+func hasAttr(s sdktrace.ReadOnlySpan, key, value string) bool {
+	for _, kv := range s.Attributes() {
+		if string(kv.Key) == key && kv.Value.AsString() == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRunner_Run(t *testing.T) {
+	var mu sync.Mutex
+	traceparents := map[string]bool{}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		traceparents[r.Header.Get("traceparent")] = true
+		mu.Unlock()
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer backend.Close()
+
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	runner, err := NewRunner(Options{
+		BaseURL:        backend.URL,
+		Concurrency:    4,
+		Count:          20,
+		TracerProvider: tp,
+		Propagator:     propagation.TraceContext{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := runner.Run(context.Background(), []Request{
+		{Name: "hello", Method: http.MethodGet, Path: "/hello", Weight: 1},
+		{Name: "error", Method: http.MethodGet, Path: "/error", Weight: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := summary.Total(); got != 20 {
+		t.Errorf("Total() = %d, want 20", got)
+	}
+	if st, ok := summary.Stats("error"); ok && st.StatusCodes[http.StatusInternalServerError] != st.Count {
+		t.Errorf("error stats = %+v, want all 500", st)
+	}
+
+	// リクエストごとにクライアントスパンが作成され、そのトレースコンテキストが伝搬される
+	ended := spans.Ended()
+	if len(ended) != 20 {
+		t.Fatalf("spans = %d, want 20", len(ended))
+	}
+	for _, s := range ended {
+		if s.SpanKind() != trace.SpanKindClient {
+			t.Errorf("span %s kind = %v, want client", s.Name(), s.SpanKind())
+		}
+		if s.Name() != http.MethodGet {
+			t.Errorf("span name = %q, want %q", s.Name(), http.MethodGet)
+		}
+		if !hasAttr(s, "loadgen.request.name", "hello") && !hasAttr(s, "loadgen.request.name", "error") {
+			t.Errorf("span %s has no loadgen.request.name attribute", s.Name())
+		}
+		want := "00-" + s.SpanContext().TraceID().String() + "-" + s.SpanContext().SpanID().String() + "-01"
+		if !traceparents[want] {
+			t.Errorf("traceparent %s was not sent", want)
+		}
+	}
+
+	var out strings.Builder
+	if err := summary.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "20 requests") {
+		t.Errorf("summary = %s", out.String())
+	}
+}
@@ -0,0 +1,260 @@
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName は負荷生成のクライアントスパンの計装スコープ名
+const ScopeName = "loadgen"
+
+// MaxRate は指定できる 1 秒あたりの送信数の上限。送信間隔が 1ns を下回らないようにする
+const MaxRate = float64(time.Second)
+
+// Options は負荷生成の設定。未指定の項目はグローバルな実装を利用する
+type Options struct {
+	// BaseURL は送信先（例: http://localhost:8080）
+	BaseURL string
+	// Concurrency は同時に送信するリクエスト数。0 の場合は 1
+	Concurrency int
+	// Rate は全体の 1 秒あたりの送信数。0 の場合は制限しない。MaxRate 以下であること
+	Rate float64
+	// Duration と Count のうち、先に到達した方で終了する。少なくとも一方を指定すること
+	Duration time.Duration
+	Count    int
+
+	Client         *http.Client
+	TracerProvider trace.TracerProvider
+	Propagator     propagation.TextMapPropagator
+}
+
+// Runner は Options に従ってリクエストを送信する
+type Runner struct {
+	opts       Options
+	baseURL    *url.URL
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// NewRunner は opts から Runner を作成する
+func NewRunner(opts Options) (*Runner, error) {
+	baseURL, err := url.Parse(strings.TrimSuffix(opts.BaseURL, "/"))
+	if err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", opts.BaseURL)
+	}
+	if err := validateRate(opts.Rate); err != nil {
+		return nil, err
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	if opts.TracerProvider == nil {
+		opts.TracerProvider = otel.GetTracerProvider()
+	}
+	if opts.Propagator == nil {
+		opts.Propagator = otel.GetTextMapPropagator()
+	}
+	return &Runner{
+		opts:       opts,
+		baseURL:    baseURL,
+		tracer:     opts.TracerProvider.Tracer(ScopeName),
+		propagator: opts.Propagator,
+	}, nil
+}
+
+// validateRate は rate が 0 以上 MaxRate 以下であることを検証する
+func validateRate(rate float64) error {
+	if !(rate >= 0 && rate <= MaxRate) {
+		return fmt.Errorf("invalid rate %v: want 0 to %v requests per second", rate, MaxRate)
+	}
+	return nil
+}
+
+// Result は 1 リクエストの結果
+type Result struct {
+	Name       string
+	StatusCode int
+	Latency    time.Duration
+	Err        error
+}
+
+// Do は req を 1 回送信する。クライアントスパンを作成し、トレースコンテキストをヘッダーに伝搬する。
+// スパン名は HTTP クライアントのセマンティック規約に従いメソッドのみとし、定義名は loadgen.request.name 属性に記録する
+func (r *Runner) Do(ctx context.Context, req Request) Result {
+	target := r.baseURL.String() + req.Path
+	ctx, span := r.tracer.Start(ctx, req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(target),
+			semconv.ServerAddress(r.baseURL.Hostname()),
+			attribute.String("loadgen.request.name", req.Name),
+		),
+	)
+	defer span.End()
+	if port, err := strconv.Atoi(r.baseURL.Port()); err == nil {
+		span.SetAttributes(semconv.ServerPort(port))
+	}
+
+	result := Result{Name: req.Name}
+	start := time.Now()
+
+	var body io.Reader
+	if req.Body != "" {
+		body = strings.NewReader(req.Body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, target, body)
+	if err != nil {
+		return r.fail(span, result, start, fmt.Errorf("failed to create request: %w", err))
+	}
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	r.propagator.Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	resp, err := r.opts.Client.Do(httpReq)
+	if err != nil {
+		return r.fail(span, result, start, err)
+	}
+	// コネクションを再利用するためボディを読み切る
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	result.Latency = time.Since(start)
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetAttributes(semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)))
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return result
+}
+
+func (r *Runner) fail(span trace.Span, result Result, start time.Time, err error) Result {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.SetAttributes(semconv.ErrorTypeKey.String(fmt.Sprintf("%T", err)))
+	result.Err = err
+	result.Latency = time.Since(start)
+	return result
+}
+
+// Run は requests から重みに従ってリクエストを選び、Options の条件に到達するまで送信する
+func (r *Runner) Run(ctx context.Context, requests []Request) (*Summary, error) {
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("no requests defined")
+	}
+	summary := NewSummary()
+	if err := r.run(ctx, load{
+		concurrency: r.opts.Concurrency,
+		rate:        r.opts.Rate,
+		duration:    r.opts.Duration,
+		count:       r.opts.Count,
+	}, requests, summary); err != nil {
+		return nil, err
+	}
+	summary.Finish()
+	return summary, nil
+}
+
+// load は 1 回の送信の並列数・レート・終了条件
+type load struct {
+	concurrency int
+	rate        float64
+	duration    time.Duration
+	count       int
+}
+
+// run は load の条件に到達するまで requests を送信し、結果を summary に加える
+func (r *Runner) run(ctx context.Context, l load, requests []Request, summary *Summary) error {
+	if l.duration <= 0 && l.count <= 0 {
+		return fmt.Errorf("either duration or count is required")
+	}
+	if err := validateRate(l.rate); err != nil {
+		return err
+	}
+	if l.concurrency <= 0 {
+		l.concurrency = 1
+	}
+	if l.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.duration)
+		defer cancel()
+	}
+
+	pick := weightedPicker(requests)
+	jobs := make(chan Request)
+	go func() {
+		defer close(jobs)
+		var tick <-chan time.Time
+		if l.rate > 0 {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / l.rate))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for sent := 0; l.count <= 0 || sent < l.count; sent++ {
+			if tick != nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-tick:
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- pick():
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < l.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range jobs {
+				// 終了時刻を過ぎても送信中のリクエストは完了させる
+				summary.Add(r.Do(context.WithoutCancel(ctx), req))
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// weightedPicker は Weight に比例してリクエストを選ぶ関数を返す
+func weightedPicker(requests []Request) func() Request {
+	total := 0
+	for _, req := range requests {
+		total += req.Weight
+	}
+	return func() Request {
+		n := rand.Intn(total)
+		for _, req := range requests {
+			if n < req.Weight {
+				return req
+			}
+			n -= req.Weight
+		}
+		return requests[len(requests)-1]
+	}
+}
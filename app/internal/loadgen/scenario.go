@@ -0,0 +1,326 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 期待するメトリクスの変化
+const (
+	ChangeIncrease  = "increase"
+	ChangeDecrease  = "decrease"
+	ChangeUnchanged = "unchanged"
+)
+
+// Scenario は複数のフェーズで構成される名前付きの負荷パターン
+type Scenario struct {
+	Name        string        `yaml:"name"`
+	Description string        `yaml:"description"`
+	Phases      []Phase       `yaml:"phases"`
+	Expect      []Expectation `yaml:"expect"`
+}
+
+// Phase は一定の並列数・レートでリクエストを送信する区間
+type Phase struct {
+	Name string `yaml:"name"`
+	// Duration と Count のうち、先に到達した方で次のフェーズに進む
+	Duration    time.Duration `yaml:"duration"`
+	Count       int           `yaml:"count"`
+	Rate        float64       `yaml:"rate"`
+	Concurrency int           `yaml:"concurrency"`
+	Requests    []Request     `yaml:"requests"`
+}
+
+// Expectation はシナリオの実行で期待するメトリクスの変化。
+// Probe を指定した場合は実行前後にアプリケーションの JSON API を取得して Field の値を比較し、
+// Status を指定した場合はそのステータスクラス（"5xx" など）のレスポンスの割合を MinRatio と比較する
+type Expectation struct {
+	// Metric はレポートに表示するメトリクス名（例: memory.heap）
+	Metric string `yaml:"metric"`
+
+	Probe  string `yaml:"probe"`
+	Field  string `yaml:"field"`
+	Change string `yaml:"change"`
+
+	Status   string  `yaml:"status"`
+	MinRatio float64 `yaml:"min_ratio"`
+}
+
+// ReadScenarios は YAML のシナリオ定義を読み込む
+//
+//	scenarios:
+//	  - name: memory-leak
+//	    phases:
+//	      - duration: 20s
+//	        rate: 2
+//	        requests:
+//	          - {method: POST, path: /memory/allocate}
+//	    expect:
+//	      - {metric: memory.heap, probe: /metrics/heap, field: heap_bytes, change: increase}
+func ReadScenarios(r io.Reader) ([]Scenario, error) {
+	var file struct {
+		Scenarios []Scenario `yaml:"scenarios"`
+	}
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse scenarios: %w", err)
+	}
+	names := make(map[string]bool, len(file.Scenarios))
+	for i := range file.Scenarios {
+		sc := &file.Scenarios[i]
+		if sc.Name == "" {
+			return nil, fmt.Errorf("scenario %d: name is required", i)
+		}
+		if names[sc.Name] {
+			return nil, fmt.Errorf("scenario %q: duplicate name", sc.Name)
+		}
+		names[sc.Name] = true
+		if err := sc.validate(); err != nil {
+			return nil, fmt.Errorf("scenario %q: %w", sc.Name, err)
+		}
+	}
+	return file.Scenarios, nil
+}
+
+func (sc *Scenario) validate() error {
+	if len(sc.Phases) == 0 {
+		return fmt.Errorf("at least one phase is required")
+	}
+	for i := range sc.Phases {
+		p := &sc.Phases[i]
+		if p.Name == "" {
+			p.Name = strconv.Itoa(i + 1)
+		}
+		if p.Duration <= 0 && p.Count <= 0 {
+			return fmt.Errorf("phase %s: duration or count is required", p.Name)
+		}
+		if err := validateRate(p.Rate); err != nil {
+			return fmt.Errorf("phase %s: %w", p.Name, err)
+		}
+		if len(p.Requests) == 0 {
+			return fmt.Errorf("phase %s: at least one request is required", p.Name)
+		}
+		for j := range p.Requests {
+			if err := p.Requests[j].normalize(); err != nil {
+				return fmt.Errorf("phase %s: %w", p.Name, err)
+			}
+		}
+	}
+	for _, e := range sc.Expect {
+		switch {
+		case e.Probe != "":
+			if e.Field == "" {
+				return fmt.Errorf("expectation %s: field is required with probe", e.Metric)
+			}
+			switch e.Change {
+			case ChangeIncrease, ChangeDecrease, ChangeUnchanged:
+			default:
+				return fmt.Errorf("expectation %s: unknown change %q", e.Metric, e.Change)
+			}
+		case e.Status != "":
+			if _, _, err := parseStatusClass(e.Status); err != nil {
+				return fmt.Errorf("expectation %s: %w", e.Metric, err)
+			}
+		default:
+			return fmt.Errorf("expectation %s: probe or status is required", e.Metric)
+		}
+	}
+	return nil
+}
+
+// Check は期待値と実測値の比較結果
+type Check struct {
+	Metric   string
+	Expected string
+	Observed string
+	OK       bool
+}
+
+// ScenarioReport はシナリオの実行結果
+type ScenarioReport struct {
+	Scenario string
+	Summary  *Summary
+	Checks   []Check
+}
+
+// OK はすべての期待値を満たした場合に true を返す
+func (r *ScenarioReport) OK() bool {
+	for _, c := range r.Checks {
+		if !c.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// RunScenario は sc のフェーズを順に実行し、実行前後のメトリクスを期待値と比較する
+func (r *Runner) RunScenario(ctx context.Context, sc Scenario) (*ScenarioReport, error) {
+	before := make([]float64, len(sc.Expect))
+	for i, e := range sc.Expect {
+		if e.Probe == "" {
+			continue
+		}
+		v, err := r.probe(ctx, e.Probe, e.Field)
+		if err != nil {
+			return nil, err
+		}
+		before[i] = v
+	}
+
+	summary := NewSummary()
+	for _, p := range sc.Phases {
+		if err := r.run(ctx, load{
+			concurrency: p.Concurrency,
+			rate:        p.Rate,
+			duration:    p.Duration,
+			count:       p.Count,
+		}, p.Requests, summary); err != nil {
+			return nil, fmt.Errorf("phase %s: %w", p.Name, err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	summary.Finish()
+
+	report := &ScenarioReport{Scenario: sc.Name, Summary: summary}
+	for i, e := range sc.Expect {
+		var check Check
+		if e.Probe != "" {
+			// 中断された場合も、送信した分の変化を報告する
+			after, err := r.probe(context.WithoutCancel(ctx), e.Probe, e.Field)
+			if err != nil {
+				return nil, err
+			}
+			check = checkChange(e, before[i], after)
+		} else {
+			check = checkStatus(e, summary)
+		}
+		report.Checks = append(report.Checks, check)
+	}
+	return report, nil
+}
+
+func checkChange(e Expectation, before, after float64) Check {
+	delta := after - before
+	check := Check{
+		Metric:   e.Metric,
+		Expected: e.Change,
+		Observed: fmt.Sprintf("%s → %s (%+g)", formatFloat(before), formatFloat(after), delta),
+	}
+	switch e.Change {
+	case ChangeIncrease:
+		check.OK = delta > 0
+	case ChangeDecrease:
+		check.OK = delta < 0
+	case ChangeUnchanged:
+		check.OK = delta == 0
+	}
+	return check
+}
+
+func checkStatus(e Expectation, summary *Summary) Check {
+	low, high, _ := parseStatusClass(e.Status)
+	total, matched := 0, 0
+	summary.mu.Lock()
+	for _, st := range summary.stats {
+		total += st.Count
+		for code, n := range st.StatusCodes {
+			if code >= low && code <= high {
+				matched += n
+			}
+		}
+	}
+	summary.mu.Unlock()
+
+	ratio := 0.0
+	if total > 0 {
+		ratio = float64(matched) / float64(total)
+	}
+	return Check{
+		Metric:   e.Metric,
+		Expected: fmt.Sprintf("%s ratio >= %.2f", e.Status, e.MinRatio),
+		Observed: fmt.Sprintf("%.2f (%d/%d)", ratio, matched, total),
+		OK:       total > 0 && ratio >= e.MinRatio,
+	}
+}
+
+// parseStatusClass は "5xx" または "503" をステータスコードの範囲に変換する
+func parseStatusClass(s string) (int, int, error) {
+	if len(s) == 3 && strings.HasSuffix(s, "xx") && s[0] >= '1' && s[0] <= '5' {
+		low := int(s[0]-'0') * 100
+		return low, low + 99, nil
+	}
+	code, err := strconv.Atoi(s)
+	if err != nil || code < 100 || code > 599 {
+		return 0, 0, fmt.Errorf("invalid status %q: want a code or class such as 5xx", s)
+	}
+	return code, code, nil
+}
+
+// probe は path の JSON レスポンスから数値の field を取得する
+func (r *Runner) probe(ctx context.Context, path, field string) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL.String()+path, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create probe request: %w", err)
+	}
+	resp, err := r.opts.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to probe %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to probe %s: status %d", path, resp.StatusCode)
+	}
+	var body map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("failed to decode probe response from %s: %w", path, err)
+	}
+	v, ok := body[field].(float64)
+	if !ok {
+		return 0, fmt.Errorf("probe %s: field %q is not a number", path, field)
+	}
+	return v, nil
+}
+
+// Write は集計結果と期待値の比較を w に書き込む
+func (r *ScenarioReport) Write(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "scenario: %s\n\n", r.Scenario); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := r.Summary.Write(w); err != nil {
+		return err
+	}
+	if len(r.Checks) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METRIC\tEXPECTED\tOBSERVED\tRESULT")
+	for _, c := range r.Checks {
+		result := "PASS"
+		if !c.OK {
+			result = "FAIL"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Metric, c.Expected, c.Observed, result)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
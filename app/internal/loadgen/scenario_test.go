@@ -0,0 +1,108 @@
+package loadgen
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/server"
+)
+
+func TestReadScenarios_Committed(t *testing.T) {
+	f, err := os.Open("../../../loadgen/scenarios.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	scenarios, err := ReadScenarios(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, sc := range scenarios {
+		names = append(names, sc.Name)
+	}
+	want := "steady,spike,memory-leak,connection-storm,error-burst"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("scenarios = %s, want %s", got, want)
+	}
+}
+
+func TestReadScenarios_Invalid(t *testing.T) {
+	for _, in := range []string{
+		`scenarios: [{name: a}]`,
+		`scenarios: [{name: a, phases: [{requests: [{path: /}]}]}]`,
+		`scenarios: [{name: a, phases: [{count: 1, requests: [{path: /}]}], expect: [{metric: m, probe: /x, field: f, change: up}]}]`,
+		`scenarios: [{name: a, phases: [{count: 1, requests: [{path: /}]}], expect: [{metric: m, status: 6xx}]}]`,
+		`scenarios: [{name: a, unknown: true}]`,
+		`scenarios: [{name: a, phases: [{count: 1, rate: -1, requests: [{path: /}]}]}]`,
+		`scenarios: [{name: a, phases: [{count: 1, rate: 2e9, requests: [{path: /}]}]}]`,
+	} {
+		if _, err := ReadScenarios(strings.NewReader(in)); err == nil {
+			t.Errorf("ReadScenarios(%s) error = nil, want error", in)
+		}
+	}
+}
+
+func TestRunner_RunScenario(t *testing.T) {
+	srv, err := server.NewServer(server.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := httptest.NewServer(srv.Routes())
+	defer backend.Close()
+
+	scenarios, err := ReadScenarios(strings.NewReader(`
+scenarios:
+  - name: storm
+    phases:
+      - count: 10
+        concurrency: 2
+        requests:
+          - {method: POST, path: /connection/open}
+      - count: 5
+        requests:
+          - {path: /error}
+    expect:
+      - {metric: active.connections, probe: /metrics/connections, field: active_connections, change: increase}
+      - {metric: http 5xx, status: 5xx, min_ratio: 0.5}
+      - {metric: http 4xx, status: 4xx, min_ratio: 0.1}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner, err := NewRunner(Options{BaseURL: backend.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := runner.RunScenario(context.Background(), scenarios[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := report.Summary.Total(); got != 15 {
+		t.Errorf("Total() = %d, want 15", got)
+	}
+	var results []bool
+	for _, c := range report.Checks {
+		results = append(results, c.OK)
+	}
+	// 5xx は 5/15 のため min_ratio 0.5 を満たさず、4xx は発生しない
+	if len(results) != 3 || !results[0] || results[1] || results[2] {
+		t.Errorf("checks = %+v, want [PASS FAIL FAIL]", report.Checks)
+	}
+	if report.OK() {
+		t.Error("OK() = true, want false")
+	}
+
+	var out strings.Builder
+	if err := report.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "active.connections") || !strings.Contains(out.String(), "FAIL") {
+		t.Errorf("report = %s", out.String())
+	}
+}
@@ -0,0 +1,135 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Summary はリクエスト名ごとのステータスとレイテンシを集計する
+type Summary struct {
+	mu      sync.Mutex
+	start   time.Time
+	elapsed time.Duration
+	stats   map[string]*Stats
+}
+
+// Stats は 1 つのリクエスト名の集計結果
+type Stats struct {
+	Count int
+	// Errors はレスポンスを受け取れなかった数（接続エラー、タイムアウトなど）
+	Errors      int
+	StatusCodes map[int]int
+	latencies   []time.Duration
+}
+
+// NewSummary は計測を開始した Summary を作成する
+func NewSummary() *Summary {
+	return &Summary{start: time.Now(), stats: make(map[string]*Stats)}
+}
+
+// Add は result を集計に加える
+func (s *Summary) Add(result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.stats[result.Name]
+	if !ok {
+		st = &Stats{StatusCodes: make(map[int]int)}
+		s.stats[result.Name] = st
+	}
+	st.Count++
+	if result.Err != nil {
+		st.Errors++
+	} else {
+		st.StatusCodes[result.StatusCode]++
+	}
+	st.latencies = append(st.latencies, result.Latency)
+}
+
+// Finish は計測を終了する
+func (s *Summary) Finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.elapsed = time.Since(s.start)
+}
+
+// Stats は name の集計結果を返す
+func (s *Summary) Stats(name string) (Stats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.stats[name]
+	if !ok {
+		return Stats{}, false
+	}
+	return *st, true
+}
+
+// Total は全リクエストの送信数を返す
+func (s *Summary) Total() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := 0
+	for _, st := range s.stats {
+		total += st.Count
+	}
+	return total
+}
+
+// Percentile はレイテンシの p パーセンタイル（0〜100）を返す
+func (st Stats) Percentile(p float64) time.Duration {
+	if len(st.latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), st.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(float64(len(sorted)-1) * p / 100)
+	return sorted[i]
+}
+
+// Write は集計結果を表形式で w に書き込む
+func (s *Summary) Write(w io.Writer) error {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.stats))
+	for name := range s.stats {
+		names = append(names, name)
+	}
+	elapsed := s.elapsed
+	s.mu.Unlock()
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "NAME\tCOUNT\tERRORS\t2xx\t3xx\t4xx\t5xx\tP50\tP90\tP99\tMAX\t")
+	for _, name := range names {
+		st, _ := s.Stats(name)
+		var classes [6]int
+		for code, n := range st.StatusCodes {
+			if code/100 < len(classes) {
+				classes[code/100] += n
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\t%s\t%s\t\n",
+			name, st.Count, st.Errors, classes[2], classes[3], classes[4], classes[5],
+			round(st.Percentile(50)), round(st.Percentile(90)), round(st.Percentile(99)), round(st.Percentile(100)))
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+
+	total := s.Total()
+	rps := 0.0
+	if elapsed > 0 {
+		rps = float64(total) / elapsed.Seconds()
+	}
+	if _, err := fmt.Fprintf(w, "\n%d requests in %s (%.1f req/s)\n", total, round(elapsed), rps); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	return nil
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(100 * time.Microsecond)
+}
@@ -0,0 +1,163 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TeeHandler はレコードを複数のハンドラーに書き込む slog.Handler
+type TeeHandler struct {
+	handlers []slog.Handler
+}
+
+// NewTeeHandler は handlers すべてに書き込む TeeHandler を作成する
+func NewTeeHandler(handlers ...slog.Handler) *TeeHandler {
+	return &TeeHandler{handlers: handlers}
+}
+
+// Enabled はいずれかのハンドラーが level を出力する場合に true を返す
+func (h *TeeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle は level を出力するハンドラーにのみレコードを渡す。
+// 一部のハンドラーが失敗しても残りのハンドラーには書き込む
+func (h *TeeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, handler := range h.handlers {
+		if !handler.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := handler.Handle(ctx, r.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *TeeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return &TeeHandler{handlers: handlers}
+}
+
+func (h *TeeHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return &TeeHandler{handlers: handlers}
+}
+
+// LevelHandler は最小レベル未満のレコードを破棄する slog.Handler
+type LevelHandler struct {
+	level   slog.Leveler
+	handler slog.Handler
+}
+
+// NewLevelHandler は level 以上のレコードのみを handler に渡す LevelHandler を作成する。
+// level が nil の場合は slog.LevelInfo とする
+func NewLevelHandler(level slog.Leveler, handler slog.Handler) *LevelHandler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &LevelHandler{level: level, handler: handler}
+}
+
+func (h *LevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.handler.Enabled(ctx, level)
+}
+
+func (h *LevelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *LevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LevelHandler{level: h.level, handler: h.handler.WithAttrs(attrs)}
+}
+
+func (h *LevelHandler) WithGroup(name string) slog.Handler {
+	return &LevelHandler{level: h.level, handler: h.handler.WithGroup(name)}
+}
+
+// DynamicLevelHandler は Levels のレベルに従ってレコードを破棄する slog.Handler。
+// ComponentKey 属性を持つロガーにはコンポーネント単位のレベルが適用される
+type DynamicLevelHandler struct {
+	levels    *Levels
+	component string
+	handler   slog.Handler
+}
+
+// NewDynamicLevelHandler は levels のレベル以上のレコードのみを handler に渡す DynamicLevelHandler を作成する
+func NewDynamicLevelHandler(levels *Levels, handler slog.Handler) *DynamicLevelHandler {
+	return &DynamicLevelHandler{levels: levels, handler: handler}
+}
+
+func (h *DynamicLevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	// 監査ログはレベルに関わらず出力する
+	if h.component != AuditComponent && level < h.levels.Level(h.component) {
+		return false
+	}
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *DynamicLevelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *DynamicLevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	component := h.component
+	for _, attr := range attrs {
+		if attr.Key == ComponentKey {
+			component = attr.Value.String()
+		}
+	}
+	return &DynamicLevelHandler{levels: h.levels, component: component, handler: h.handler.WithAttrs(attrs)}
+}
+
+func (h *DynamicLevelHandler) WithGroup(name string) slog.Handler {
+	return &DynamicLevelHandler{levels: h.levels, component: h.component, handler: h.handler.WithGroup(name)}
+}
+
+// TraceHandler は ctx にスパンがある場合、trace_id と span_id 属性をレコードに追加する slog.Handler。
+// otelslog ブリッジはレコード自体にトレースコンテキストを持たせるため、コンソール出力にのみ利用する
+type TraceHandler struct {
+	handler slog.Handler
+}
+
+// NewTraceHandler は handler にトレースコンテキストの属性を追加する TraceHandler を作成する
+func NewTraceHandler(handler slog.Handler) *TraceHandler {
+	return &TraceHandler{handler: handler}
+}
+
+func (h *TraceHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *TraceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *TraceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &TraceHandler{handler: h.handler.WithAttrs(attrs)}
+}
+
+func (h *TraceHandler) WithGroup(name string) slog.Handler {
+	return &TraceHandler{handler: h.handler.WithGroup(name)}
+}
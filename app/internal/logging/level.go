@@ -0,0 +1,257 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/response"
+	"github.com/go-chi/chi/v5"
+)
+
+// ComponentKey はロガーのコンポーネント名を表す属性キー。
+// logger.With(ComponentKey, "metrics") のように指定すると、コンポーネント単位のレベルが適用される
+const ComponentKey = "component"
+
+// AuditComponent は監査ログのコンポーネント名。レベルに関わらず常に出力される
+const AuditComponent = "audit"
+
+// Levels は実行時に変更できるログレベルを保持する。
+// 全体のレベルと、コンポーネント単位で上書きするレベルを持つ
+type Levels struct {
+	base   slog.Level
+	global *slog.LevelVar
+
+	mu         sync.RWMutex
+	components map[string]*slog.LevelVar
+
+	// Toggle で切り替える前の全体のレベル。toggled が true の間だけ有効
+	toggled bool
+	saved   slog.Level
+
+	// レベル変更時の監査ログの出力先。SetLogger で設定する
+	logger *slog.Logger
+}
+
+// NewLevels は全体のレベルを level とする Levels を作成する
+func NewLevels(level slog.Level) *Levels {
+	global := &slog.LevelVar{}
+	global.Set(level)
+	return &Levels{
+		base:       level,
+		global:     global,
+		components: make(map[string]*slog.LevelVar),
+	}
+}
+
+// ParseComponentLevels は "metrics=debug,simulator=warn" 形式の文字列を解析する
+func ParseComponentLevels(s string) (map[string]slog.Level, error) {
+	levels := make(map[string]slog.Level)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		component, value, ok := strings.Cut(pair, "=")
+		component = strings.TrimSpace(component)
+		if !ok || component == "" {
+			return nil, fmt.Errorf("invalid component level %q: want component=level", pair)
+		}
+		level, err := ParseLevel(value, slog.LevelInfo)
+		if err != nil {
+			return nil, err
+		}
+		levels[component] = level
+	}
+	return levels, nil
+}
+
+// SetLogger はレベル変更時の監査ログを出力するロガーを設定する
+func (l *Levels) SetLogger(logger *slog.Logger) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logger = logger.With(ComponentKey, AuditComponent)
+}
+
+// Level は component に適用されるレベルを返す。上書きがない場合は全体のレベルを返す
+func (l *Levels) Level(component string) slog.Level {
+	if component != "" {
+		l.mu.RLock()
+		v, ok := l.components[component]
+		l.mu.RUnlock()
+		if ok {
+			return v.Level()
+		}
+	}
+	return l.global.Level()
+}
+
+// SetLevel は全体のレベルを変更する。source は変更元（"admin", "signal" など）で監査ログに記録される。
+// Toggle で切り替え中の場合は切り替えを解除し、次の Toggle はこのレベルから切り替える
+func (l *Levels) SetLevel(ctx context.Context, level slog.Level, source string) {
+	l.mu.Lock()
+	l.toggled = false
+	old := l.global.Level()
+	l.global.Set(level)
+	l.mu.Unlock()
+
+	l.audit(ctx, "", old.String(), level.String(), source)
+}
+
+// ResetLevel は全体のレベルを起動時のレベルに戻す
+func (l *Levels) ResetLevel(ctx context.Context, source string) {
+	l.SetLevel(ctx, l.base, source)
+}
+
+// SetComponentLevel は component のレベルを上書きする
+func (l *Levels) SetComponentLevel(ctx context.Context, component string, level slog.Level, source string) {
+	l.mu.Lock()
+	v, ok := l.components[component]
+	old := l.global.Level().String()
+	if ok {
+		old = v.Level().String()
+	} else {
+		v = &slog.LevelVar{}
+		l.components[component] = v
+	}
+	v.Set(level)
+	l.mu.Unlock()
+
+	l.audit(ctx, component, old, level.String(), source)
+}
+
+// ResetComponentLevel は component の上書きを削除し、全体のレベルに戻す
+func (l *Levels) ResetComponentLevel(ctx context.Context, component string, source string) {
+	l.mu.Lock()
+	v, ok := l.components[component]
+	delete(l.components, component)
+	l.mu.Unlock()
+
+	if ok {
+		l.audit(ctx, component, v.Level().String(), l.global.Level().String(), source)
+	}
+}
+
+// Toggle は全体のレベルを Debug に切り替え、次の呼び出しで切り替える前のレベルに戻す。
+// 既に Debug 以下の場合は Info に切り替える。SIGUSR1 のハンドラーから呼び出す
+func (l *Levels) Toggle(ctx context.Context, source string) slog.Level {
+	l.mu.Lock()
+	old := l.global.Level()
+	next := l.saved
+	if !l.toggled {
+		l.saved = old
+		next = slog.LevelDebug
+		if old <= slog.LevelDebug {
+			next = slog.LevelInfo
+		}
+	}
+	l.toggled = !l.toggled
+	l.global.Set(next)
+	l.mu.Unlock()
+
+	l.audit(ctx, "", old.String(), next.String(), source)
+	return next
+}
+
+// Snapshot は現在のレベルを返す
+func (l *Levels) Snapshot() LevelsSnapshot {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	snapshot := LevelsSnapshot{
+		Level:      l.global.Level().String(),
+		Components: make(map[string]string, len(l.components)),
+	}
+	for component, v := range l.components {
+		snapshot.Components[component] = v.Level().String()
+	}
+	return snapshot
+}
+
+// LevelsSnapshot は管理 API で返すログレベル
+type LevelsSnapshot struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+func (l *Levels) audit(ctx context.Context, component, oldLevel, newLevel, source string) {
+	l.mu.RLock()
+	logger := l.logger
+	l.mu.RUnlock()
+	if logger == nil {
+		return
+	}
+	target := component
+	if target == "" {
+		target = "*"
+	}
+	logger.InfoContext(ctx, "Log level changed",
+		"log.component", target,
+		"log.level.old", oldLevel,
+		"log.level.new", newLevel,
+		"source", source,
+	)
+}
+
+// AdminRoutes はログレベルを参照・変更する管理 API を返す
+//
+//	GET    /              現在のレベルを返す
+//	PUT    /              全体のレベルを変更する（{"level":"debug"}）
+//	PUT    /{component}   コンポーネントのレベルを上書きする
+//	DELETE /{component}   コンポーネントの上書きを削除する
+func (l *Levels) AdminRoutes() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/", l.getLevels)
+	r.Put("/", l.putLevel)
+	r.Put("/{component}", l.putComponentLevel)
+	r.Delete("/{component}", l.deleteComponentLevel)
+	return r
+}
+
+type levelRequest struct {
+	Level string `json:"level"`
+}
+
+func decodeLevel(r *http.Request) (slog.Level, error) {
+	var req levelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return 0, err
+	}
+	if req.Level == "" {
+		return 0, fmt.Errorf("level is required")
+	}
+	return ParseLevel(req.Level, slog.LevelInfo)
+}
+
+func (l *Levels) getLevels(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, r, http.StatusOK, l.Snapshot())
+}
+
+func (l *Levels) putLevel(w http.ResponseWriter, r *http.Request) {
+	level, err := decodeLevel(r)
+	if err != nil {
+		response.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	l.SetLevel(r.Context(), level, "admin")
+	response.JSON(w, r, http.StatusOK, l.Snapshot())
+}
+
+func (l *Levels) putComponentLevel(w http.ResponseWriter, r *http.Request) {
+	level, err := decodeLevel(r)
+	if err != nil {
+		response.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	l.SetComponentLevel(r.Context(), chi.URLParam(r, "component"), level, "admin")
+	response.JSON(w, r, http.StatusOK, l.Snapshot())
+}
+
+func (l *Levels) deleteComponentLevel(w http.ResponseWriter, r *http.Request) {
+	l.ResetComponentLevel(r.Context(), chi.URLParam(r, "component"), "admin")
+	response.JSON(w, r, http.StatusOK, l.Snapshot())
+}
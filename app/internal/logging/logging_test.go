@@ -0,0 +1,243 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// memoryExporter はエクスポートされたログレコードをメモリに保持する sdklog.Exporter
+type memoryExporter struct {
+	records []sdklog.Record
+}
+
+func (e *memoryExporter) Export(_ context.Context, records []sdklog.Record) error {
+	for _, r := range records {
+		e.records = append(e.records, r.Clone())
+	}
+	return nil
+}
+
+func (e *memoryExporter) Shutdown(context.Context) error   { return nil }
+func (e *memoryExporter) ForceFlush(context.Context) error { return nil }
+
+func TestNew(t *testing.T) {
+	exporter := &memoryExporter{}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exporter)))
+	var console bytes.Buffer
+
+	logger, err := New(Options{
+		Name:           "test",
+		LoggerProvider: provider,
+		OTLPLevel:      slog.LevelWarn,
+		Console:        &console,
+		ConsoleFormat:  FormatJSON,
+		ConsoleLevel:   slog.LevelDebug,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x02},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	logger.DebugContext(ctx, "debug message")
+	logger.WarnContext(ctx, "warn message", "key", "value")
+
+	// コンソールには Debug 以上が出力され、トレース ID が付与される
+	lines := strings.Split(strings.TrimSpace(console.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("console lines = %d, want 2: %s", len(lines), console.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["msg"] != "warn message" || entry["key"] != "value" {
+		t.Errorf("console entry = %v", entry)
+	}
+	if entry["trace_id"] != sc.TraceID().String() || entry["span_id"] != sc.SpanID().String() {
+		t.Errorf("console entry trace_id/span_id = %v/%v, want %s/%s",
+			entry["trace_id"], entry["span_id"], sc.TraceID(), sc.SpanID())
+	}
+
+	// OTLP には Warn 以上のみ出力される
+	var bodies []string
+	for _, r := range exporter.records {
+		bodies = append(bodies, r.Body().AsString())
+		if r.Severity() != log.SeverityWarn {
+			t.Errorf("otlp record severity = %v, want WARN", r.Severity())
+		}
+		if r.TraceID() != sc.TraceID() {
+			t.Errorf("otlp record trace id = %s, want %s", r.TraceID(), sc.TraceID())
+		}
+	}
+	if len(bodies) != 1 || bodies[0] != "warn message" {
+		t.Errorf("otlp records = %q, want [\"warn message\"]", bodies)
+	}
+}
+
+func TestNew_UnknownFormat(t *testing.T) {
+	if _, err := New(Options{Console: &bytes.Buffer{}, ConsoleFormat: "xml"}); err == nil {
+		t.Error("New() error = nil, want error for unknown format")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"", slog.LevelInfo, false},
+		{"debug", slog.LevelDebug, false},
+		{"WARN", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", slog.LevelInfo, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.in, slog.LevelInfo)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v, err=%t", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	var console bytes.Buffer
+	levels := NewLevels(slog.LevelInfo)
+	logger, err := New(Options{Console: &console, ConsoleFormat: FormatJSON, ConsoleLevel: slog.LevelDebug, Levels: levels})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := logger.With(ComponentKey, "metrics")
+	ctx := context.Background()
+
+	metrics.DebugContext(ctx, "dropped")
+	levels.SetComponentLevel(ctx, "metrics", slog.LevelDebug, "test")
+	metrics.DebugContext(ctx, "metrics debug")
+	logger.DebugContext(ctx, "still dropped")
+	// 全体のレベルを Error にしても監査ログは出力される
+	levels.SetLevel(ctx, slog.LevelError, "test")
+	logger.InfoContext(ctx, "dropped after level change")
+
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(console.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, entry["msg"].(string))
+	}
+	want := []string{"Log level changed", "metrics debug", "Log level changed"}
+	if strings.Join(messages, ",") != strings.Join(want, ",") {
+		t.Errorf("messages = %q, want %q", messages, want)
+	}
+
+	if got := levels.Toggle(ctx, "test"); got != slog.LevelDebug {
+		t.Errorf("Toggle() = %v, want DEBUG", got)
+	}
+	// 切り替える前のレベルに戻る
+	if got := levels.Toggle(ctx, "test"); got != slog.LevelError {
+		t.Errorf("Toggle() = %v, want ERROR", got)
+	}
+}
+
+func TestLevels_Toggle(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name  string
+		base  slog.Level
+		set   *slog.Level
+		wantA slog.Level
+		wantB slog.Level
+	}{
+		{name: "info", base: slog.LevelInfo, wantA: slog.LevelDebug, wantB: slog.LevelInfo},
+		{name: "debug", base: slog.LevelDebug, wantA: slog.LevelInfo, wantB: slog.LevelDebug},
+		{name: "changed before toggle", base: slog.LevelInfo, set: ptr(slog.LevelWarn), wantA: slog.LevelDebug, wantB: slog.LevelWarn},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			levels := NewLevels(tt.base)
+			if tt.set != nil {
+				levels.SetLevel(ctx, *tt.set, "admin")
+			}
+			if got := levels.Toggle(ctx, "signal"); got != tt.wantA {
+				t.Errorf("first Toggle() = %v, want %v", got, tt.wantA)
+			}
+			if got := levels.Toggle(ctx, "signal"); got != tt.wantB {
+				t.Errorf("second Toggle() = %v, want %v", got, tt.wantB)
+			}
+			if got := levels.Level(""); got != tt.wantB {
+				t.Errorf("Level() = %v, want %v", got, tt.wantB)
+			}
+		})
+	}
+
+	// 切り替え中に変更された場合は、次の Toggle で変更後のレベルから切り替える
+	levels := NewLevels(slog.LevelInfo)
+	levels.Toggle(ctx, "signal")
+	levels.SetLevel(ctx, slog.LevelError, "admin")
+	if got := levels.Toggle(ctx, "signal"); got != slog.LevelDebug {
+		t.Errorf("Toggle() after SetLevel = %v, want DEBUG", got)
+	}
+	if got := levels.Toggle(ctx, "signal"); got != slog.LevelError {
+		t.Errorf("Toggle() = %v, want ERROR", got)
+	}
+}
+
+func TestParseComponentLevels(t *testing.T) {
+	got, err := ParseComponentLevels(" metrics = debug , simulator=warn,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["metrics"] != slog.LevelDebug || got["simulator"] != slog.LevelWarn {
+		t.Errorf("ParseComponentLevels() = %v", got)
+	}
+
+	for _, s := range []string{"metrics", "=debug", " =debug", "metrics=loud"} {
+		if _, err := ParseComponentLevels(s); err == nil {
+			t.Errorf("ParseComponentLevels(%q) error = nil, want error", s)
+		}
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestLevels_AdminRoutes(t *testing.T) {
+	levels := NewLevels(slog.LevelInfo)
+	routes := levels.AdminRoutes()
+
+	for _, tt := range []struct {
+		method, path, body string
+		wantStatus         int
+	}{
+		{http.MethodPut, "/", `{"level":"warn"}`, http.StatusOK},
+		{http.MethodPut, "/metrics", `{"level":"debug"}`, http.StatusOK},
+		{http.MethodPut, "/", `{"level":"loud"}`, http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %s %s: status = %d, want %d", tt.method, tt.path, tt.body, rec.Code, tt.wantStatus)
+		}
+	}
+
+	got := levels.Snapshot()
+	if got.Level != "WARN" || got.Components["metrics"] != "DEBUG" {
+		t.Errorf("Snapshot() = %+v, want WARN with metrics=DEBUG", got)
+	}
+}
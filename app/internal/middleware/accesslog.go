@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/log"
+)
+
+// AccessLogEventName はアクセスログレコードのイベント名
+const AccessLogEventName = "http.server.access"
+
+// AccessLogOptions はアクセスログミドルウェアの設定
+type AccessLogOptions struct {
+	// LoggerProvider はアクセスログの出力先。slog を経由せず OTel の Logs API で直接出力する
+	LoggerProvider log.LoggerProvider
+	// SampleRate は 2xx / 3xx のリクエストを記録する割合（0〜1）。4xx / 5xx は常に記録する。
+	// nil の場合はすべて記録し、0 の場合は 2xx / 3xx を記録しない
+	SampleRate *float64
+	// ExcludePaths に一致するパスは記録しない（例: /healthz）
+	ExcludePaths []string
+}
+
+// AccessLog はリクエストごとに 1 件、セマンティック規約の属性を持つログレコードを出力するミドルウェアを返す。
+// サーバースパンと相関付けるため otelchi.Middleware より後に登録すること
+func AccessLog(opts AccessLogOptions) func(http.Handler) http.Handler {
+	logger := opts.LoggerProvider.Logger("go-app/accesslog")
+	sampleRate := 1.0
+	if opts.SampleRate != nil {
+		sampleRate = *opts.SampleRate
+	}
+	excluded := make(map[string]bool, len(opts.ExcludePaths))
+	for _, path := range opts.ExcludePaths {
+		excluded[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if excluded[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			// 接続断（http.ErrAbortHandler）で panic した場合も記録するため defer で出力する
+			defer func() {
+				rec := recover()
+				aborted := rec != nil
+				status := ww.Status()
+				if status == 0 && !aborted {
+					// ヘッダーを書き込まずに終了した場合は net/http が 200 を返す
+					status = http.StatusOK
+				}
+				if aborted || status >= http.StatusBadRequest || rand.Float64() < sampleRate {
+					emitAccessLog(logger, r, ww, start, status, aborted)
+				}
+				if aborted {
+					panic(rec)
+				}
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+func emitAccessLog(logger log.Logger, r *http.Request, ww chimiddleware.WrapResponseWriter, start time.Time, status int, aborted bool) {
+	route := chi.RouteContext(r.Context()).RoutePattern()
+	if route == "" {
+		route = r.URL.Path
+	}
+	client := clientInfo(r)
+	severity := accessLogSeverity(status)
+	if aborted {
+		severity = log.SeverityError
+	}
+
+	var record log.Record
+	record.SetEventName(AccessLogEventName)
+	record.SetTimestamp(start)
+	record.SetSeverity(severity)
+	record.SetSeverityText(severity.String())
+	record.SetBody(log.StringValue(fmt.Sprintf("%s %s %d", r.Method, route, status)))
+	record.AddAttributes(
+		log.String("http.request.method", r.Method),
+		log.String("http.route", route),
+		log.String("url.path", r.URL.Path),
+		log.String("url.scheme", client.Scheme),
+		log.Int("http.response.status_code", status),
+		log.Int("http.response.body.size", ww.BytesWritten()),
+		log.Float64("http.server.request.duration", time.Since(start).Seconds()),
+		log.String("client.address", client.Address),
+		log.String("user_agent.original", r.UserAgent()),
+		log.String("network.protocol.version", fmt.Sprintf("%d.%d", r.ProtoMajor, r.ProtoMinor)),
+	)
+	if aborted {
+		record.AddAttributes(log.String("error.type", "aborted"))
+	}
+	// r.Context() にはサーバースパンが含まれるため、レコードにトレース ID / スパン ID が付与される
+	logger.Emit(r.Context(), record)
+}
+
+func accessLogSeverity(status int) log.Severity {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return log.SeverityError
+	case status >= http.StatusBadRequest:
+		return log.SeverityWarn
+	default:
+		return log.SeverityInfo
+	}
+}
+
+// clientInfo は TrustedProxies が解決したクライアント情報を返す。
+// TrustedProxies が登録されていない場合は接続元のアドレスを使う
+func clientInfo(r *http.Request) ClientInfo {
+	if info, ok := ClientInfoFromContext(r.Context()); ok {
+		return info
+	}
+	return resolveClient(r, nil)
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ClientInfo は信頼できるプロキシのヘッダーから解決したクライアントの情報
+type ClientInfo struct {
+	// Address はクライアントの IP アドレス
+	Address string
+	// Scheme はクライアントが利用したスキーム（http / https）
+	Scheme string
+}
+
+type clientInfoKey struct{}
+
+// ClientInfoFromContext は TrustedProxies が ctx に保存した ClientInfo を返す
+func ClientInfoFromContext(ctx context.Context) (ClientInfo, bool) {
+	info, ok := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info, ok
+}
+
+// ParseTrustedProxies は "10.0.0.0/8,172.16.0.0/12" 形式の文字列を解析する。
+// プレフィックス長を省略した場合は単一のアドレスとして扱う
+func ParseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", v, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", v, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// TrustedProxies は接続元が trusted に含まれる場合のみ X-Forwarded-For / X-Real-IP / X-Forwarded-Proto を信頼し、
+// クライアントのアドレスとスキームを解決するミドルウェアを返す。
+//
+// 解決した ClientInfo はリクエストのコンテキストに保存し、サーバースパンに client.address と url.scheme を設定する。
+// スパンを参照するため otelchi.Middleware より後に登録すること。
+func TrustedProxies(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := resolveClient(r, trusted)
+			trace.SpanFromContext(r.Context()).SetAttributes(
+				semconv.ClientAddress(info.Address),
+				semconv.URLScheme(info.Scheme),
+			)
+			ctx := context.WithValue(r.Context(), clientInfoKey{}, info)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func resolveClient(r *http.Request, trusted []netip.Prefix) ClientInfo {
+	info := ClientInfo{Address: remoteHost(r), Scheme: "http"}
+	if r.TLS != nil {
+		info.Scheme = "https"
+	}
+	if !isTrusted(info.Address, trusted) {
+		return info
+	}
+
+	// X-Forwarded-For は右から辿り、信頼できるプロキシ以外の最初のアドレスをクライアントとする。
+	// 左側はクライアントが任意に書き換えられるため、先頭の値をそのまま使わない
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if _, err := netip.ParseAddr(hop); err != nil {
+				break
+			}
+			info.Address = hop
+			if !isTrusted(hop, trusted) {
+				break
+			}
+		}
+	} else if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		if _, err := netip.ParseAddr(ip); err == nil {
+			info.Address = ip
+		}
+	}
+
+	switch proto := strings.ToLower(strings.TrimSpace(r.Header.Get("X-Forwarded-Proto"))); proto {
+	case "http", "https":
+		info.Scheme = proto
+	}
+	return info
+}
+
+func isTrusted(address string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       ClientInfo
+	}{
+		{
+			name:       "untrusted peer ignores headers",
+			remoteAddr: "198.51.100.9:1234",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7", "X-Forwarded-Proto": "https"},
+			want:       ClientInfo{Address: "198.51.100.9", Scheme: "http"},
+		},
+		{
+			name:       "trusted peer uses X-Forwarded-For",
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7", "X-Forwarded-Proto": "https"},
+			want:       ClientInfo{Address: "203.0.113.7", Scheme: "https"},
+		},
+		{
+			name:       "spoofed leftmost hop is skipped",
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.1.1.1, 203.0.113.7, 10.0.0.5"},
+			want:       ClientInfo{Address: "203.0.113.7", Scheme: "http"},
+		},
+		{
+			name:       "trusted peer falls back to X-Real-IP",
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string]string{"X-Real-IP": "203.0.113.8"},
+			want:       ClientInfo{Address: "203.0.113.8", Scheme: "http"},
+		},
+		{
+			name:       "invalid proto is ignored",
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string]string{"X-Forwarded-Proto": "gopher"},
+			want:       ClientInfo{Address: "192.0.2.1", Scheme: "http"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ClientInfo
+			handler := TrustedProxies(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = ClientInfoFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("ClientInfo = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies_Invalid(t *testing.T) {
+	if _, err := ParseTrustedProxies("10.0.0.0/8,nginx"); err == nil {
+		t.Error("ParseTrustedProxies() error = nil, want error")
+	}
+}
@@ -0,0 +1,81 @@
+// Package middleware はアプリケーションの chi ルーターに登録する共通ミドルウェアを提供する。
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/response"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Recoverer はハンドラーの panic を 500 の JSON エラーに変換するミドルウェアを返す。
+//
+// panic はアクティブなスパンに exception イベント（exception.stacktrace 付き）として記録し、
+// スパンのステータスを Error に設定し、logger に Error のログを出力する。
+// スパンを取得するため otelchi.Middleware より後に登録すること。
+func Recoverer(meter metric.Meter, logger *slog.Logger) (func(http.Handler) http.Handler, error) {
+	panicCounter, err := meter.Int64Counter(
+		"http.server.panics",
+		metric.WithDescription("Number of panics recovered in HTTP handlers"),
+		metric.WithUnit("{panic}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create panic counter: %w", err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				// 接続の中断は net/http に処理させる
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				ctx := r.Context()
+				stack := string(debug.Stack())
+				err, ok := rec.(error)
+				if !ok {
+					err = fmt.Errorf("panic: %v", rec)
+				}
+
+				route := chi.RouteContext(ctx).RoutePattern()
+				if route == "" {
+					route = r.URL.Path
+				}
+
+				span := trace.SpanFromContext(ctx)
+				span.RecordError(err, trace.WithAttributes(semconv.ExceptionStacktrace(stack)))
+				span.SetStatus(codes.Error, err.Error())
+
+				panicCounter.Add(ctx, 1, metric.WithAttributes(
+					semconv.HTTPRoute(route),
+					semconv.HTTPRequestMethodKey.String(r.Method),
+				))
+
+				// ctx を渡すことで、otelslog ブリッジがログにトレース ID / スパン ID を付与する
+				logger.ErrorContext(ctx, "Recovered from panic",
+					"error", err.Error(),
+					"http.route", route,
+					"http.request.method", r.Method,
+					"exception.stacktrace", stack,
+				)
+
+				response.WriteProblem(w, r, response.NewProblem(http.StatusInternalServerError,
+					"The server recovered from an unexpected panic"))
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
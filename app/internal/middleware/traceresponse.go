@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/response"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// レスポンスに付与するトレースのヘッダー
+const (
+	// HeaderTraceResponse は W3C Trace Context Level 2 の traceresponse（version-traceid-spanid-flags）
+	HeaderTraceResponse = "traceresponse"
+	// HeaderTraceID はトレース ID のみを返すヘッダー
+	HeaderTraceID = "X-Trace-Id"
+)
+
+// DefaultTraceResponseHeaders は TraceResponseOptions.Headers が nil の場合に付与するヘッダー
+var DefaultTraceResponseHeaders = []string{HeaderTraceResponse, HeaderTraceID}
+
+// TraceResponseOptions はトレース ID をレスポンスで返す設定
+type TraceResponseOptions struct {
+	// Headers は付与するヘッダー（HeaderTraceResponse / HeaderTraceID）。
+	// nil の場合は DefaultTraceResponseHeaders、空の場合はヘッダーを付与しない
+	Headers []string
+	// ExcludePaths に一致するパスでは、ヘッダーもエラーボディの trace_id も返さない（例: 公開するルート）
+	ExcludePaths []string
+	// ViewerURL を指定すると、エラーボディに trace_url としてトレースビューアーのリンクを含める
+	// （例: http://localhost:8081/traces）
+	ViewerURL string
+}
+
+// TraceResponse はサーバースパンのトレース ID をレスポンスヘッダーと problem+json のエラーボディで返すミドルウェアを返す。
+// スパンを参照するため otelchi.Middleware より後に登録すること
+func TraceResponse(opts TraceResponseOptions) (func(http.Handler) http.Handler, error) {
+	headers := opts.Headers
+	if headers == nil {
+		headers = DefaultTraceResponseHeaders
+	}
+	for _, h := range headers {
+		if h != HeaderTraceResponse && h != HeaderTraceID {
+			return nil, fmt.Errorf("unknown trace response header %q: want %s or %s", h, HeaderTraceResponse, HeaderTraceID)
+		}
+	}
+	excluded := make(map[string]bool, len(opts.ExcludePaths))
+	for _, path := range opts.ExcludePaths {
+		excluded[path] = true
+	}
+	var traceURL func(string) string
+	if opts.ViewerURL != "" {
+		base := strings.TrimSuffix(opts.ViewerURL, "/")
+		traceURL = func(traceID string) string { return base + "/" + traceID }
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sc := trace.SpanContextFromContext(r.Context())
+			if excluded[r.URL.Path] || !sc.IsValid() {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// ハンドラーがレスポンスを書き込む前に設定する
+			for _, h := range headers {
+				switch h {
+				case HeaderTraceResponse:
+					w.Header().Set(HeaderTraceResponse, fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags()))
+				case HeaderTraceID:
+					w.Header().Set(HeaderTraceID, sc.TraceID().String())
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(response.WithTraceID(r.Context(), traceURL)))
+		})
+	}, nil
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/response"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceResponse(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x0a, 0xf7, 0x65, 0x19},
+		SpanID:     trace.SpanID{0xb7, 0xad},
+		TraceFlags: trace.FlagsSampled,
+	})
+	problem := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response.WriteProblem(w, r, response.NewProblem(http.StatusBadGateway, ""))
+	})
+
+	tests := []struct {
+		name     string
+		opts     TraceResponseOptions
+		wantID   string
+		wantBody bool
+	}{
+		{"default headers", TraceResponseOptions{}, sc.TraceID().String(), true},
+		{"headers disabled", TraceResponseOptions{Headers: []string{}}, "", true},
+		{"excluded path", TraceResponseOptions{ExcludePaths: []string{"/public"}}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw, err := TraceResponse(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/public", nil)
+			req = req.WithContext(trace.ContextWithSpanContext(req.Context(), sc))
+			rec := httptest.NewRecorder()
+			mw(problem).ServeHTTP(rec, req)
+
+			if got := rec.Header().Get(HeaderTraceID); got != tt.wantID {
+				t.Errorf("X-Trace-Id = %q, want %q", got, tt.wantID)
+			}
+			if got := strings.Contains(rec.Body.String(), `"trace_id":"`+sc.TraceID().String()+`"`); got != tt.wantBody {
+				t.Errorf("body %s contains trace_id = %v, want %v", rec.Body.String(), got, tt.wantBody)
+			}
+		})
+	}
+
+	if _, err := TraceResponse(TraceResponseOptions{Headers: []string{"X-Request-Id"}}); err == nil {
+		t.Error("unknown header: want error")
+	}
+}
@@ -0,0 +1,163 @@
+// Package nginxconf は chi ルーターのルート定義から nginx の location 設定を生成する。
+//
+// ルートごとに otel_span_name を正規化したルートパターンで設定するため、
+// nginx のスパン名とアプリケーションのスパン名（http.route）が一致する。
+// プローブのようにトレースしないパスは otel_trace off にする。
+package nginxconf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Location は nginx の location ブロック 1 件分
+type Location struct {
+	// Modifier は "="（完全一致）または "~"（正規表現）
+	Modifier string
+	// Path は location の引数
+	Path string
+	// Route は chi のルートパターン。otel_span_name に利用する
+	Route string
+	// Methods はルートに登録された HTTP メソッド（コメント用）
+	Methods []string
+	// Untraced が true の場合は nginx でトレースしない
+	Untraced bool
+}
+
+// Locations は routes から location の一覧を作成する。同じパターンの複数メソッドは 1 件にまとめる
+func Locations(routes chi.Routes) ([]Location, error) {
+	var locations []Location
+	index := make(map[string]int)
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if i, ok := index[route]; ok {
+			locations[i].Methods = append(locations[i].Methods, method)
+			return nil
+		}
+		loc, err := newLocation(route)
+		if err != nil {
+			return err
+		}
+		loc.Methods = []string{method}
+		index[route] = len(locations)
+		locations = append(locations, loc)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk routes: %w", err)
+	}
+	return locations, nil
+}
+
+// chi のパラメーター {name} または {name:regexp}。正規表現内の波括弧には対応しない
+var paramPattern = regexp.MustCompile(`\{([^}:]+)(?::([^}]+))?\}`)
+
+func newLocation(route string) (Location, error) {
+	if strings.Contains(route, "*") {
+		return Location{}, fmt.Errorf("wildcard route %q is not supported", route)
+	}
+	if !paramPattern.MatchString(route) {
+		return Location{Modifier: "=", Path: route, Route: route}, nil
+	}
+
+	// パラメーター以外の部分はエスケープし、パラメーターは 1 セグメント（または指定の正規表現）に置き換える
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, m := range paramPattern.FindAllStringSubmatchIndex(route, -1) {
+		b.WriteString(regexp.QuoteMeta(route[last:m[0]]))
+		if m[4] >= 0 {
+			b.WriteString("(?:" + route[m[4]:m[5]] + ")")
+		} else {
+			b.WriteString("[^/]+")
+		}
+		last = m[1]
+	}
+	b.WriteString(regexp.QuoteMeta(route[last:]))
+	b.WriteString("$")
+	return Location{Modifier: "~", Path: b.String(), Route: route}, nil
+}
+
+var confTemplate = template.Must(template.New("app.conf").Funcs(template.FuncMap{"join": strings.Join}).Parse(`# Code generated by go run ./cmd/nginxconf; DO NOT EDIT.
+# ルートを追加・変更した場合は make nginx-conf で再生成する
+
+upstream app_backend {
+  server app:8080;
+  keepalive 16;
+}
+
+server {
+  listen 80;
+  server_name _;
+
+  # (Optional but handy) add the raw path as an attribute for search/filtering
+  otel_span_attr http.target $uri;
+{{range .}}
+  # {{join .Methods ", "}} {{.Route}}
+  location {{.Modifier}} {{.Path}} {
+{{- if .Untraced}}
+    otel_trace off;
+{{- else}}
+    otel_trace on;
+    otel_trace_context propagate;
+    otel_span_name "$request_method {{.Route}}";
+{{- end}}
+
+    proxy_pass http://app_backend;
+    proxy_http_version 1.1;
+    proxy_set_header Host $host;
+    proxy_set_header X-Real-IP $remote_addr;
+    proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+    proxy_set_header X-Forwarded-Proto $scheme;
+    proxy_set_header Connection "";
+  }
+{{end}}
+  # Static assets (unchanged)
+  location /assets/ {
+    alias /usr/share/nginx/html/;
+    autoindex off;
+    expires 1h;
+  }
+
+  # Fallback: ルーターに存在しないパスは実パスで見せる
+  location / {
+    otel_trace on;
+    otel_trace_context propagate;
+    otel_span_name "$request_method $uri";
+
+    proxy_pass http://app_backend;
+    proxy_http_version 1.1;
+    proxy_set_header Host $host;
+    proxy_set_header X-Real-IP $remote_addr;
+    proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+    proxy_set_header X-Forwarded-Proto $scheme;
+    proxy_set_header Connection "";
+  }
+}
+`))
+
+// Generate は routes の nginx 設定を w に書き込む。untraced のルートはトレースしない
+func Generate(w io.Writer, routes chi.Routes, untraced ...string) error {
+	locations, err := Locations(routes)
+	if err != nil {
+		return err
+	}
+	for i := range locations {
+		locations[i].Untraced = slices.Contains(untraced, locations[i].Route)
+	}
+	var buf bytes.Buffer
+	if err := confTemplate.Execute(&buf, locations); err != nil {
+		return fmt.Errorf("failed to render nginx config: %w", err)
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write nginx config: %w", err)
+	}
+	return nil
+}
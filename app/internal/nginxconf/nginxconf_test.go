@@ -0,0 +1,54 @@
+package nginxconf
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestNewLocation(t *testing.T) {
+	tests := []struct {
+		route        string
+		wantModifier string
+		wantPath     string
+	}{
+		{"/hello", "=", "/hello"},
+		{"/users/{id}", "~", `^/users/[^/]+$`},
+		{"/files/{name}.json", "~", `^/files/[^/]+\.json$`},
+		{"/orders/{id:[0-9]+}", "~", `^/orders/(?:[0-9]+)$`},
+	}
+	for _, tt := range tests {
+		got, err := newLocation(tt.route)
+		if err != nil {
+			t.Errorf("newLocation(%q) error = %v", tt.route, err)
+			continue
+		}
+		if got.Modifier != tt.wantModifier || got.Path != tt.wantPath {
+			t.Errorf("newLocation(%q) = %s %s, want %s %s", tt.route, got.Modifier, got.Path, tt.wantModifier, tt.wantPath)
+		}
+	}
+
+	if _, err := newLocation("/static/*"); err == nil {
+		t.Error("newLocation(/static/*) error = nil, want error")
+	}
+}
+
+func TestGenerate_Untraced(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/hello", func(http.ResponseWriter, *http.Request) {})
+	r.Get("/livez", func(http.ResponseWriter, *http.Request) {})
+
+	var b strings.Builder
+	if err := Generate(&b, r, "/livez"); err != nil {
+		t.Fatal(err)
+	}
+	conf := b.String()
+	if !strings.Contains(conf, "location = /hello {\n    otel_trace on;") {
+		t.Errorf("conf does not trace /hello:\n%s", conf)
+	}
+	if !strings.Contains(conf, "location = /livez {\n    otel_trace off;\n\n    proxy_pass") {
+		t.Errorf("conf traces /livez:\n%s", conf)
+	}
+}
@@ -0,0 +1,89 @@
+// Package otlpjson は OTLP/JSON のエンコードとデコードを行う。
+//
+// OTLP/JSON は protobuf の JSON マッピングとほぼ同じだが、traceId / spanId / parentSpanId を
+// base64 ではなく 16 進数の文字列で表す。protojson はこの違いを扱わないため、前後で変換する。
+package otlpjson
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Marshal は msg を 1 行の OTLP/JSON にエンコードする
+func Marshal(msg proto.Message) ([]byte, error) {
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return convertIDs(data, func(id string) (string, bool) {
+		b, err := base64.StdEncoding.DecodeString(id)
+		if err != nil {
+			return "", false
+		}
+		return hex.EncodeToString(b), true
+	})
+}
+
+// Unmarshal は OTLP/JSON の data を msg にデコードする
+func Unmarshal(data []byte, msg proto.Message) error {
+	data, err := convertIDs(data, func(id string) (string, bool) {
+		b, err := hex.DecodeString(id)
+		if err != nil {
+			return "", false
+		}
+		return base64.StdEncoding.EncodeToString(b), true
+	})
+	if err != nil {
+		return err
+	}
+	return protojson.Unmarshal(data, msg)
+}
+
+// convertIDs は data に含まれる traceId / spanId / parentSpanId を convert で変換する。
+// 変換できない値はそのまま残す
+func convertIDs(data []byte, convert func(string) (string, bool)) ([]byte, error) {
+	// 数値の精度を保つため json.Number で読み込む
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	var walk func(any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for key, value := range v {
+				switch key {
+				case "traceId", "spanId", "parentSpanId":
+					if id, ok := value.(string); ok {
+						if converted, ok := convert(id); ok {
+							v[key] = converted
+						}
+					}
+				default:
+					walk(value)
+				}
+			}
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(v)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	// Encode が末尾に付ける改行を除く
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
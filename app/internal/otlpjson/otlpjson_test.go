@@ -0,0 +1,96 @@
+package otlpjson
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestMarshal_RoundTrip(t *testing.T) {
+	const (
+		traceID      = "0af7651916cd43dd8448eb211c80319c"
+		spanID       = "b7ad6b7169203331"
+		parentSpanID = "00f067aa0ba902b7"
+		linkTraceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+		linkSpanID   = "53995c3f42cd8ad8"
+	)
+	str := func(s string) *commonpb.AnyValue {
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+	}
+	req := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+				{Key: "service.name", Value: str("go-app")},
+			}},
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Scope: &commonpb.InstrumentationScope{Name: "go-app/server"},
+				Spans: []*tracepb.Span{{
+					TraceId:      mustDecodeHex(t, traceID),
+					SpanId:       mustDecodeHex(t, spanID),
+					ParentSpanId: mustDecodeHex(t, parentSpanID),
+					Name:         "GET /hello",
+					Kind:         tracepb.Span_SPAN_KIND_SERVER,
+					// 2^53 を超える値も精度を失わない
+					StartTimeUnixNano: 1760000000123456789,
+					EndTimeUnixNano:   1760000000223456789,
+					Attributes: []*commonpb.KeyValue{
+						{Key: "http.route", Value: str("/hello")},
+						// ID と同じ名前の属性キーは変換しない
+						{Key: "spanId", Value: str("not-an-id")},
+						{Key: "http.response.status_code", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 200}}},
+					},
+					Links: []*tracepb.Span_Link{{
+						TraceId: mustDecodeHex(t, linkTraceID),
+						SpanId:  mustDecodeHex(t, linkSpanID),
+					}},
+					Status: &tracepb.Status{Code: tracepb.Status_STATUS_CODE_OK},
+				}},
+			}},
+		}},
+	}
+
+	data, err := Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("\n")) {
+		t.Errorf("Marshal() = %s, want a single line", data)
+	}
+	for _, want := range []string{
+		`"traceId":"` + traceID + `"`,
+		`"spanId":"` + spanID + `"`,
+		`"parentSpanId":"` + parentSpanID + `"`,
+		`"traceId":"` + linkTraceID + `"`,
+		`"spanId":"` + linkSpanID + `"`,
+		`"startTimeUnixNano":"1760000000123456789"`,
+		`"key":"spanId"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Marshal() = %s, want %s", data, want)
+		}
+	}
+
+	got := &coltracepb.ExportTraceServiceRequest{}
+	if err := Unmarshal(data, got); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(got, req) {
+		t.Errorf("Unmarshal(Marshal(req)) = %v, want %v", got, req)
+	}
+}
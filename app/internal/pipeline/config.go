@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// BatchConfig はバッチプロセッサー（トレース・ログ）の設定
+type BatchConfig struct {
+	MaxQueueSize       int
+	MaxExportBatchSize int
+	ScheduleDelay      time.Duration
+	ExportTimeout      time.Duration
+}
+
+// ReaderConfig はメトリクスの PeriodicReader の設定
+type ReaderConfig struct {
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// Config はシグナルごとのパイプラインの設定
+type Config struct {
+	Spans   BatchConfig
+	Logs    BatchConfig
+	Metrics ReaderConfig
+}
+
+// DefaultConfig は OpenTelemetry の仕様の既定値。
+// ただしメトリクスの送信間隔はデモで変化をすぐ確認できるよう 3s にしている（仕様の既定値は 60s）
+func DefaultConfig() Config {
+	return Config{
+		Spans: BatchConfig{
+			MaxQueueSize:       2048,
+			MaxExportBatchSize: 512,
+			ScheduleDelay:      5 * time.Second,
+			ExportTimeout:      30 * time.Second,
+		},
+		Logs: BatchConfig{
+			MaxQueueSize:       2048,
+			MaxExportBatchSize: 512,
+			ScheduleDelay:      time.Second,
+			ExportTimeout:      30 * time.Second,
+		},
+		Metrics: ReaderConfig{
+			Interval: 3 * time.Second,
+			Timeout:  30 * time.Second,
+		},
+	}
+}
+
+// ConfigFromEnv は DefaultConfig を標準の環境変数で上書きした設定を返す。
+// 時間はミリ秒の整数で指定する（仕様どおり）
+//
+//	OTEL_BSP_SCHEDULE_DELAY, OTEL_BSP_EXPORT_TIMEOUT, OTEL_BSP_MAX_QUEUE_SIZE, OTEL_BSP_MAX_EXPORT_BATCH_SIZE
+//	OTEL_BLRP_SCHEDULE_DELAY, OTEL_BLRP_EXPORT_TIMEOUT, OTEL_BLRP_MAX_QUEUE_SIZE, OTEL_BLRP_MAX_EXPORT_BATCH_SIZE
+//	OTEL_METRIC_EXPORT_INTERVAL, OTEL_METRIC_EXPORT_TIMEOUT
+func ConfigFromEnv(getenv func(string) string) (Config, error) {
+	cfg := DefaultConfig()
+	p := envParser{getenv: getenv}
+	p.batch("OTEL_BSP", &cfg.Spans)
+	p.batch("OTEL_BLRP", &cfg.Logs)
+	p.millis("OTEL_METRIC_EXPORT_INTERVAL", &cfg.Metrics.Interval)
+	p.millis("OTEL_METRIC_EXPORT_TIMEOUT", &cfg.Metrics.Timeout)
+	if p.err != nil {
+		return Config{}, p.err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Validate は設定の組み合わせを検証する
+func (c Config) Validate() error {
+	for _, b := range []struct {
+		prefix string
+		cfg    BatchConfig
+	}{{"OTEL_BSP", c.Spans}, {"OTEL_BLRP", c.Logs}} {
+		if b.cfg.MaxExportBatchSize > b.cfg.MaxQueueSize {
+			return fmt.Errorf("%s_MAX_EXPORT_BATCH_SIZE (%d) must not exceed %s_MAX_QUEUE_SIZE (%d)",
+				b.prefix, b.cfg.MaxExportBatchSize, b.prefix, b.cfg.MaxQueueSize)
+		}
+	}
+	return nil
+}
+
+// envParser は最初のエラーを保持しながら環境変数を読み込む
+type envParser struct {
+	getenv func(string) string
+	err    error
+}
+
+func (p *envParser) batch(prefix string, cfg *BatchConfig) {
+	p.millis(prefix+"_SCHEDULE_DELAY", &cfg.ScheduleDelay)
+	p.millis(prefix+"_EXPORT_TIMEOUT", &cfg.ExportTimeout)
+	p.int(prefix+"_MAX_QUEUE_SIZE", &cfg.MaxQueueSize)
+	p.int(prefix+"_MAX_EXPORT_BATCH_SIZE", &cfg.MaxExportBatchSize)
+}
+
+func (p *envParser) int(key string, dst *int) {
+	v := p.getenv(key)
+	if v == "" || p.err != nil {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		p.err = fmt.Errorf("invalid %s: %q: want a positive integer", key, v)
+		return
+	}
+	*dst = n
+}
+
+func (p *envParser) millis(key string, dst *time.Duration) {
+	var ms int
+	p.int(key, &ms)
+	if ms > 0 {
+		*dst = time.Duration(ms) * time.Millisecond
+	}
+}
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(t *testing.T, cfg Config)
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg Config) {
+				if cfg != DefaultConfig() {
+					t.Errorf("cfg = %+v, want defaults", cfg)
+				}
+			},
+		},
+		{
+			name: "overrides per signal",
+			env: map[string]string{
+				"OTEL_BSP_SCHEDULE_DELAY":        "200",
+				"OTEL_BSP_MAX_QUEUE_SIZE":        "100",
+				"OTEL_BSP_MAX_EXPORT_BATCH_SIZE": "50",
+				"OTEL_BLRP_EXPORT_TIMEOUT":       "1500",
+				"OTEL_METRIC_EXPORT_INTERVAL":    "60000",
+				"OTEL_METRIC_EXPORT_TIMEOUT":     "10000",
+			},
+			check: func(t *testing.T, cfg Config) {
+				want := DefaultConfig()
+				want.Spans.ScheduleDelay = 200 * time.Millisecond
+				want.Spans.MaxQueueSize = 100
+				want.Spans.MaxExportBatchSize = 50
+				want.Logs.ExportTimeout = 1500 * time.Millisecond
+				want.Metrics = ReaderConfig{Interval: time.Minute, Timeout: 10 * time.Second}
+				if cfg != want {
+					t.Errorf("cfg = %+v, want %+v", cfg, want)
+				}
+			},
+		},
+		{
+			name:    "not a number",
+			env:     map[string]string{"OTEL_BLRP_SCHEDULE_DELAY": "1s"},
+			wantErr: "OTEL_BLRP_SCHEDULE_DELAY",
+		},
+		{
+			name:    "negative",
+			env:     map[string]string{"OTEL_METRIC_EXPORT_INTERVAL": "-1"},
+			wantErr: "OTEL_METRIC_EXPORT_INTERVAL",
+		},
+		{
+			name:    "batch larger than queue",
+			env:     map[string]string{"OTEL_BLRP_MAX_QUEUE_SIZE": "100"},
+			wantErr: "OTEL_BLRP_MAX_EXPORT_BATCH_SIZE (512) must not exceed OTEL_BLRP_MAX_QUEUE_SIZE (100)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ConfigFromEnv(func(key string) string { return tt.env[key] })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
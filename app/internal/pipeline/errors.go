@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultErrorInterval は同じ SDK エラーを出力する最小の間隔の既定値
+const DefaultErrorInterval = time.Minute
+
+// maxErrorEntries を超えて異なるメッセージが記録された場合、古いものから忘れる
+const maxErrorEntries = 100
+
+// Options は Monitor の設定
+type Options struct {
+	// Logger は SDK のエラーの出力先。nil の場合は出力時点の slog.Default()
+	Logger *slog.Logger
+	// ErrorInterval の間は同じメッセージのエラーを 1 回だけ出力する。0 の場合は DefaultErrorInterval
+	ErrorInterval time.Duration
+}
+
+// ErrorStatus は SDK のエラーの集計
+type ErrorStatus struct {
+	Total      int64     `json:"total"`
+	Suppressed int64     `json:"suppressed"`
+	LastError  string    `json:"last_error,omitempty"`
+	LastTime   time.Time `json:"last_time"`
+}
+
+// errorLimiter は同じメッセージのエラーを interval に 1 回だけ出力する
+type errorLimiter struct {
+	logger   *slog.Logger
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*errorEntry
+	stats   ErrorStatus
+}
+
+type errorEntry struct {
+	logged     time.Time
+	suppressed int64
+}
+
+func newErrorLimiter(opts Options) *errorLimiter {
+	if opts.ErrorInterval <= 0 {
+		opts.ErrorInterval = DefaultErrorInterval
+	}
+	return &errorLimiter{
+		logger:   opts.Logger,
+		interval: opts.ErrorInterval,
+		now:      time.Now,
+		entries:  make(map[string]*errorEntry),
+	}
+}
+
+// Handle は otel.ErrorHandler の実装。otel.SetErrorHandler に渡すと、
+// エクスポートの失敗などの SDK のエラーを slog に出力する
+func (m *Monitor) Handle(err error) {
+	m.errors.handle(err)
+}
+
+func (l *errorLimiter) handle(err error) {
+	if err == nil {
+		return
+	}
+	msg := err.Error()
+	now := l.now()
+
+	l.mu.Lock()
+	l.stats.Total++
+	l.stats.LastError = msg
+	l.stats.LastTime = now
+	entry, ok := l.entries[msg]
+	if ok && now.Sub(entry.logged) < l.interval {
+		entry.suppressed++
+		l.stats.Suppressed++
+		l.mu.Unlock()
+		return
+	}
+	suppressed := int64(0)
+	if ok {
+		suppressed = entry.suppressed
+	} else {
+		l.evict(now)
+		entry = &errorEntry{}
+		l.entries[msg] = entry
+	}
+	entry.logged = now
+	entry.suppressed = 0
+	l.mu.Unlock()
+
+	logger := l.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	// ログのエクスポートに失敗している場合、このログも送信できないが、コンソールには出力される
+	logger.Error("OpenTelemetry SDK error", "error", msg, "suppressed", suppressed)
+}
+
+// evict は保持するメッセージが上限に達した場合、interval を過ぎたものを忘れる。
+// それでも上限を超える場合は最も古いものを忘れる
+func (l *errorLimiter) evict(now time.Time) {
+	if len(l.entries) < maxErrorEntries {
+		return
+	}
+	var oldest string
+	for msg, entry := range l.entries {
+		if now.Sub(entry.logged) >= l.interval {
+			delete(l.entries, msg)
+			continue
+		}
+		if oldest == "" || entry.logged.Before(l.entries[oldest].logged) {
+			oldest = msg
+		}
+	}
+	if len(l.entries) >= maxErrorEntries {
+		delete(l.entries, oldest)
+	}
+}
+
+func (l *errorLimiter) status() ErrorStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+// RegisterMetrics は meter にパイプラインのメトリクスを登録する。
+// MeterProvider の作成には計測対象のエクスポーターが必要なため、Monitor の作成後に呼び出す
+func (m *Monitor) RegisterMetrics(meter metric.Meter) error {
+	duration, err := meter.Float64Histogram(
+		"otel.sdk.exporter.operation.duration",
+		metric.WithDescription("The duration of exporting a batch of telemetry records."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create export duration histogram: %w", err)
+	}
+	m.duration.Store(&duration)
+
+	for _, st := range []*signalStats{m.traces, m.logs} {
+		if err := registerQueue(meter, st); err != nil {
+			return err
+		}
+	}
+	for _, st := range []*signalStats{m.traces, m.metrics, m.logs} {
+		if err := registerExported(meter, st); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registerQueue はバッチプロセッサーのキューの長さ・上限と、処理（破棄を含む）した件数を登録する
+func registerQueue(meter metric.Meter, st *signalStats) error {
+	component := metric.WithAttributes(semconv.OTelComponentTypeKey.String("batching_" + st.item + "_processor"))
+	dropped := metric.WithAttributes(
+		semconv.OTelComponentTypeKey.String("batching_"+st.item+"_processor"),
+		semconv.ErrorTypeKey.String(errorTypeQueueFull),
+	)
+
+	size, err := meter.Int64ObservableUpDownCounter(
+		"otel.sdk.processor."+st.item+".queue.size",
+		metric.WithDescription(fmt.Sprintf("The number of %ss in the processor queue.", st.item)),
+		metric.WithUnit("{"+st.item+"}"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create %s queue size: %w", st.signal, err)
+	}
+	capacity, err := meter.Int64ObservableUpDownCounter(
+		"otel.sdk.processor."+st.item+".queue.capacity",
+		metric.WithDescription(fmt.Sprintf("The maximum number of %ss the processor queue can hold.", st.item)),
+		metric.WithUnit("{"+st.item+"}"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create %s queue capacity: %w", st.signal, err)
+	}
+	processed, err := meter.Int64ObservableCounter(
+		"otel.sdk.processor."+st.item+".processed",
+		metric.WithDescription(fmt.Sprintf("The number of %ss enqueued or dropped by the processor.", st.item)),
+		metric.WithUnit("{"+st.item+"}"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create %s processed counter: %w", st.signal, err)
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(size, st.queued.Load(), component)
+		o.ObserveInt64(capacity, st.capacity.Load(), component)
+		o.ObserveInt64(processed, st.processed.Load(), component)
+		o.ObserveInt64(processed, st.dropped.Load(), dropped)
+		return nil
+	}, size, capacity, processed)
+	if err != nil {
+		return fmt.Errorf("failed to register %s queue callback: %w", st.signal, err)
+	}
+	return nil
+}
+
+// registerExported はエクスポートに成功・失敗した件数を登録する
+func registerExported(meter metric.Meter, st *signalStats) error {
+	attrs := []attribute.KeyValue{semconv.OTelComponentTypeKey.String(st.component)}
+	ok := metric.WithAttributes(attrs...)
+	failed := metric.WithAttributes(append(attrs, semconv.ErrorTypeKey.String("export_failed"))...)
+	spooled := metric.WithAttributes(append(attrs, semconv.ErrorTypeKey.String(errorTypeSpooled))...)
+
+	exported, err := meter.Int64ObservableCounter(
+		"otel.sdk.exporter."+st.item+".exported",
+		metric.WithDescription(fmt.Sprintf("The number of %ss for which the export has finished, either successful or failed.", st.item)),
+		metric.WithUnit("{"+st.item+"}"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create %s exported counter: %w", st.signal, err)
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(exported, st.exported.Load(), ok)
+		o.ObserveInt64(exported, st.failed.Load(), failed)
+		o.ObserveInt64(exported, st.spooled.Load(), spooled)
+		return nil
+	}, exported)
+	if err != nil {
+		return fmt.Errorf("failed to register %s exported callback: %w", st.signal, err)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+// Package pipeline はテレメトリのパイプライン（バッチプロセッサーと OTLP エクスポーター）自体を計測する。
+//
+// SDK のバッチプロセッサーはキューの長さや破棄した件数を公開しないため、プロセッサーとエクスポーターを
+// ラップしてキューに入れた件数とエクスポートした件数を数える。キューが上限に達した場合は
+// ラッパーが破棄して数えるため、SDK のプロセッサー内で黙って破棄されることはない。
+//
+// エクスポーターが送信に失敗したリクエストをスプール（internal/spool）に保存して nil を返した場合は、
+// MarkSpooled で記録された結果をもとに、成功ではなく spooled として数える。再送の結果はスプールのメトリクスで確認する。
+//
+// 計測値は OpenTelemetry SDK のセルフオブザーバビリティのセマンティック規約に沿ったメトリクス
+// （otel.sdk.processor.* / otel.sdk.exporter.*）と、管理用ポートの /debug/telemetry で確認できる。
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/response"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ScopeName はパイプラインのメトリクスの計装スコープ名
+const ScopeName = "go-app/pipeline"
+
+// シグナル
+const (
+	SignalTraces  = "traces"
+	SignalMetrics = "metrics"
+	SignalLogs    = "logs"
+)
+
+// errorTypeQueueFull はキューが上限に達して破棄したことを表す error.type
+const errorTypeQueueFull = "queue_full"
+
+// errorTypeSpooled は送信できずにスプールへ保存したことを表す error.type
+const errorTypeSpooled = "spooled"
+
+// Monitor はシグナルごとのパイプラインの計測値と、SDK のエラーを保持する
+type Monitor struct {
+	traces  *signalStats
+	metrics *signalStats
+	logs    *signalStats
+	errors  *errorLimiter
+
+	duration atomic.Pointer[metric.Float64Histogram]
+}
+
+// New は計測値を保持する Monitor を作成する。SDK のエラーは Options.Logger にレート制限して出力する
+func New(opts Options) *Monitor {
+	return &Monitor{
+		traces:  &signalStats{signal: SignalTraces, item: "span", component: "otlp_grpc_span_exporter"},
+		metrics: &signalStats{signal: SignalMetrics, item: "metric_data_point", component: "otlp_grpc_metric_exporter"},
+		logs:    &signalStats{signal: SignalLogs, item: "log", component: "otlp_grpc_log_exporter"},
+		errors:  newErrorLimiter(opts),
+	}
+}
+
+// signalStats は 1 つのシグナルのキューとエクスポートの計測値
+type signalStats struct {
+	signal    string
+	item      string
+	component string
+
+	capacity atomic.Int64
+	// queued はキューに入れてからエクスポートを試みるまでの件数（エクスポート中を含む）
+	queued    atomic.Int64
+	processed atomic.Int64
+	dropped   atomic.Int64
+	exported  atomic.Int64
+	failed    atomic.Int64
+	spooled   atomic.Int64
+
+	mu           sync.Mutex
+	exports      int64
+	exportErrors int64
+	lastExport   time.Time
+	lastDuration time.Duration
+	lastError    string
+	lastErrorAt  time.Time
+}
+
+// enqueue はキューに空きがあれば件数を加算して true を返す。上限に達している場合は破棄として数える
+func (st *signalStats) enqueue() bool {
+	capacity := st.capacity.Load()
+	for {
+		n := st.queued.Load()
+		if capacity > 0 && n >= capacity {
+			st.dropped.Add(1)
+			return false
+		}
+		if st.queued.CompareAndSwap(n, n+1) {
+			st.processed.Add(1)
+			return true
+		}
+	}
+}
+
+type spooledKey struct{}
+
+// MarkSpooled は ctx のエクスポートが送信先に届かず、再送のためにスプールへ保存されたことを記録する
+func MarkSpooled(ctx context.Context) {
+	if spooled, ok := ctx.Value(spooledKey{}).(*atomic.Bool); ok {
+		spooled.Store(true)
+	}
+}
+
+// export は fn で n 件をエクスポートし、結果を記録する
+func (m *Monitor) export(ctx context.Context, st *signalStats, n int, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	spooled := new(atomic.Bool)
+	err := fn(context.WithValue(ctx, spooledKey{}, spooled))
+	elapsed := time.Since(start)
+	if st.capacity.Load() > 0 {
+		st.queued.Add(-int64(n))
+	}
+	switch {
+	case err != nil:
+		st.failed.Add(int64(n))
+	case spooled.Load():
+		st.spooled.Add(int64(n))
+	default:
+		st.exported.Add(int64(n))
+	}
+
+	st.mu.Lock()
+	st.exports++
+	st.lastExport = start
+	st.lastDuration = elapsed
+	if err != nil {
+		st.exportErrors++
+		st.lastError = err.Error()
+		st.lastErrorAt = start
+	}
+	st.mu.Unlock()
+
+	if h := m.duration.Load(); h != nil {
+		attrs := []attribute.KeyValue{semconv.OTelComponentTypeKey.String(st.component)}
+		switch {
+		case err != nil:
+			attrs = append(attrs, semconv.ErrorTypeKey.String(errorType(err)))
+		case spooled.Load():
+			attrs = append(attrs, semconv.ErrorTypeKey.String(errorTypeSpooled))
+		}
+		(*h).Record(ctx, elapsed.Seconds(), metric.WithAttributes(attrs...))
+	}
+	return err
+}
+
+// errorType はエクスポートのエラーを error.type の値にする。gRPC のステータスコードがあればそれを使う
+func errorType(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return codes.DeadlineExceeded.String()
+	}
+	if s, ok := status.FromError(err); ok && s.Code() != codes.Unknown {
+		return s.Code().String()
+	}
+	return "_OTHER"
+}
+
+// SignalStatus は 1 つのシグナルのパイプラインの状態
+type SignalStatus struct {
+	Signal string `json:"signal"`
+	// QueueSize と QueueCapacity はバッチプロセッサーのキュー（メトリクスは定期的に読み取るためキューを持たない）
+	QueueSize     int64 `json:"queue_size"`
+	QueueCapacity int64 `json:"queue_capacity"`
+	Processed     int64 `json:"processed"`
+	Dropped       int64 `json:"dropped"`
+	Exported      int64 `json:"exported"`
+	ExportFailed  int64 `json:"export_failed"`
+	// Spooled は送信できずにスプールへ保存した件数。再送の結果はスプールの状態で確認する
+	Spooled int64 `json:"spooled"`
+
+	Exports            int64         `json:"exports"`
+	ExportErrors       int64         `json:"export_errors"`
+	LastExport         time.Time     `json:"last_export"`
+	LastExportDuration time.Duration `json:"last_export_duration_ns"`
+	LastError          string        `json:"last_error,omitempty"`
+	LastErrorTime      time.Time     `json:"last_error_time"`
+}
+
+// Status はパイプライン全体の状態
+type Status struct {
+	Signals []SignalStatus `json:"signals"`
+	Errors  ErrorStatus    `json:"sdk_errors"`
+}
+
+// Status は現在の状態を返す
+func (m *Monitor) Status() Status {
+	status := Status{Errors: m.errors.status()}
+	for _, st := range []*signalStats{m.traces, m.metrics, m.logs} {
+		st.mu.Lock()
+		status.Signals = append(status.Signals, SignalStatus{
+			Signal:             st.signal,
+			QueueSize:          st.queued.Load(),
+			QueueCapacity:      st.capacity.Load(),
+			Processed:          st.processed.Load(),
+			Dropped:            st.dropped.Load(),
+			Exported:           st.exported.Load(),
+			ExportFailed:       st.failed.Load(),
+			Spooled:            st.spooled.Load(),
+			Exports:            st.exports,
+			ExportErrors:       st.exportErrors,
+			LastExport:         st.lastExport,
+			LastExportDuration: st.lastDuration,
+			LastError:          st.lastError,
+			LastErrorTime:      st.lastErrorAt,
+		})
+		st.mu.Unlock()
+	}
+	return status
+}
+
+// AdminRoutes はパイプラインの状態を返す管理 API を返す
+//
+//	GET /  シグナルごとのキュー・破棄・エクスポートの件数と、直近の SDK エラー
+func (m *Monitor) AdminRoutes() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			response.Error(w, r, http.StatusMethodNotAllowed, nil)
+			return
+		}
+		response.JSON(w, r, http.StatusOK, m.Status())
+	})
+}
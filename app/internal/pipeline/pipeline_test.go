@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// blockingExporter は release が閉じられるまでエクスポートを止め、err を返す
+type blockingExporter struct {
+	release chan struct{}
+	err     error
+}
+
+func (e *blockingExporter) ExportSpans(ctx context.Context, _ []sdktrace.ReadOnlySpan) error {
+	select {
+	case <-e.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return e.err
+}
+
+func (e *blockingExporter) Shutdown(context.Context) error { return nil }
+
+func signalStatus(t *testing.T, m *Monitor, signal string) SignalStatus {
+	t.Helper()
+	for _, st := range m.Status().Signals {
+		if st.Signal == signal {
+			return st
+		}
+	}
+	t.Fatalf("signal %s not found", signal)
+	return SignalStatus{}
+}
+
+func TestBatchSpanProcessor_QueueFull(t *testing.T) {
+	m := New(Options{})
+	exp := &blockingExporter{release: make(chan struct{})}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(m.BatchSpanProcessor(exp, BatchConfig{MaxQueueSize: 2, MaxExportBatchSize: 1, ScheduleDelay: time.Millisecond, ExportTimeout: time.Second})))
+	tracer := tp.Tracer("test")
+
+	for range 5 {
+		_, span := tracer.Start(context.Background(), "span")
+		span.End()
+	}
+	if got := signalStatus(t, m, SignalTraces); got.QueueSize != 2 || got.Dropped != 3 || got.Processed != 2 {
+		t.Errorf("while blocked: %+v, want queue 2, processed 2, dropped 3", got)
+	}
+
+	close(exp.release)
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := signalStatus(t, m, SignalTraces); got.QueueSize != 0 || got.Exported != 2 || got.ExportErrors != 0 {
+		t.Errorf("after flush: %+v, want queue 0, exported 2", got)
+	}
+}
+
+func TestBatchSpanProcessor_ExportError(t *testing.T) {
+	m := New(Options{})
+	reader := sdkmetric.NewManualReader()
+	if err := m.RegisterMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter(ScopeName)); err != nil {
+		t.Fatal(err)
+	}
+	exp := &blockingExporter{release: make(chan struct{}), err: errors.New("connection refused")}
+	close(exp.release)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(m.BatchSpanProcessor(exp, DefaultConfig().Spans)))
+	_, span := tp.Tracer("test").Start(context.Background(), "span")
+	span.End()
+	if err := tp.ForceFlush(context.Background()); err == nil {
+		t.Error("ForceFlush: want export error")
+	}
+
+	if got := signalStatus(t, m, SignalTraces); got.ExportFailed != 1 || got.LastError != "connection refused" {
+		t.Errorf("status = %+v, want 1 failed span", got)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, metric := range sm.Metrics {
+			found[metric.Name] = true
+			if metric.Name != "otel.sdk.exporter.span.exported" {
+				continue
+			}
+			for _, dp := range metric.Data.(metricdata.Sum[int64]).DataPoints {
+				if v, ok := dp.Attributes.Value("error.type"); ok && v.AsString() == "export_failed" && dp.Value != 1 {
+					t.Errorf("failed spans with error.type=%s = %d, want 1", v.AsString(), dp.Value)
+				}
+			}
+		}
+	}
+	for _, name := range []string{"otel.sdk.processor.span.queue.size", "otel.sdk.exporter.operation.duration", "otel.sdk.exporter.log.exported"} {
+		if !found[name] {
+			t.Errorf("metric %s not collected", name)
+		}
+	}
+}
+
+// spoolingExporter は送信できなかったスパンをスプールに保存したものとして nil を返す
+type spoolingExporter struct{}
+
+func (spoolingExporter) ExportSpans(ctx context.Context, _ []sdktrace.ReadOnlySpan) error {
+	MarkSpooled(ctx)
+	return nil
+}
+
+func (spoolingExporter) Shutdown(context.Context) error { return nil }
+
+func TestBatchSpanProcessor_Spooled(t *testing.T) {
+	m := New(Options{})
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(m.BatchSpanProcessor(spoolingExporter{}, DefaultConfig().Spans)))
+	_, span := tp.Tracer("test").Start(context.Background(), "span")
+	span.End()
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// スプールに保存したスパンはエクスポートの成功として数えない
+	if got := signalStatus(t, m, SignalTraces); got.Spooled != 1 || got.Exported != 0 || got.ExportFailed != 0 {
+		t.Errorf("status = %+v, want 1 spooled span", got)
+	}
+}
+
+func TestMonitor_HandleRateLimit(t *testing.T) {
+	var buf bytes.Buffer
+	m := New(Options{Logger: slog.New(slog.NewTextHandler(&buf, nil)), ErrorInterval: time.Minute})
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.errors.now = func() time.Time { return now }
+
+	for range 3 {
+		m.Handle(errors.New("export failed"))
+	}
+	if got := strings.Count(buf.String(), "export failed"); got != 1 {
+		t.Errorf("logged %d times within interval, want 1", got)
+	}
+
+	now = now.Add(time.Minute)
+	m.Handle(errors.New("export failed"))
+	if !strings.Contains(buf.String(), "suppressed=2") {
+		t.Errorf("log = %s, want suppressed=2", buf.String())
+	}
+	if got := m.Status().Errors; got.Total != 4 || got.Suppressed != 2 {
+		t.Errorf("error status = %+v, want total 4, suppressed 2", got)
+	}
+}
@@ -0,0 +1,141 @@
+package pipeline
+
+import (
+	"context"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// BatchSpanProcessor は cfg の設定で exp にエクスポートする BatchSpanProcessor を作成し、キューとエクスポートを計測する。
+// キューが cfg.MaxQueueSize に達した場合は BatchSpanProcessor に渡さずに破棄して数える
+func (m *Monitor) BatchSpanProcessor(exp sdktrace.SpanExporter, cfg BatchConfig) sdktrace.SpanProcessor {
+	m.traces.capacity.Store(int64(cfg.MaxQueueSize))
+	return &spanProcessor{
+		SpanProcessor: sdktrace.NewBatchSpanProcessor(&spanExporter{SpanExporter: exp, monitor: m},
+			sdktrace.WithMaxQueueSize(cfg.MaxQueueSize),
+			sdktrace.WithMaxExportBatchSize(cfg.MaxExportBatchSize),
+			sdktrace.WithBatchTimeout(cfg.ScheduleDelay),
+			sdktrace.WithExportTimeout(cfg.ExportTimeout),
+			// キューの上限はラッパーで管理するため、BatchSpanProcessor 側では破棄させない
+			sdktrace.WithBlocking(),
+		),
+		stats: m.traces,
+	}
+}
+
+type spanProcessor struct {
+	sdktrace.SpanProcessor
+	stats *signalStats
+}
+
+func (p *spanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	// BatchSpanProcessor はサンプリングされなかったスパンをキューに入れない
+	if !s.SpanContext().IsSampled() {
+		return
+	}
+	if p.stats.enqueue() {
+		p.SpanProcessor.OnEnd(s)
+	}
+}
+
+type spanExporter struct {
+	sdktrace.SpanExporter
+	monitor *Monitor
+}
+
+func (e *spanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return e.monitor.export(ctx, e.monitor.traces, len(spans), func(ctx context.Context) error {
+		return e.SpanExporter.ExportSpans(ctx, spans)
+	})
+}
+
+// BatchLogProcessor は cfg の設定で exp にエクスポートする sdklog.BatchProcessor を作成し、キューとエクスポートを計測する。
+// キューが cfg.MaxQueueSize に達した場合は BatchProcessor に渡さずに破棄して数える
+func (m *Monitor) BatchLogProcessor(exp sdklog.Exporter, cfg BatchConfig) sdklog.Processor {
+	m.logs.capacity.Store(int64(cfg.MaxQueueSize))
+	return &logProcessor{
+		// 件数をラッパーで制限するため、BatchProcessor のキュー（古いものから破棄する）はあふれない
+		Processor: sdklog.NewBatchProcessor(&logExporter{Exporter: exp, monitor: m},
+			sdklog.WithMaxQueueSize(cfg.MaxQueueSize),
+			sdklog.WithExportMaxBatchSize(cfg.MaxExportBatchSize),
+			sdklog.WithExportInterval(cfg.ScheduleDelay),
+			sdklog.WithExportTimeout(cfg.ExportTimeout),
+		),
+		stats: m.logs,
+	}
+}
+
+type logProcessor struct {
+	sdklog.Processor
+	stats *signalStats
+}
+
+func (p *logProcessor) OnEmit(ctx context.Context, record *sdklog.Record) error {
+	if !p.stats.enqueue() {
+		return nil
+	}
+	return p.Processor.OnEmit(ctx, record)
+}
+
+type logExporter struct {
+	sdklog.Exporter
+	monitor *Monitor
+}
+
+func (e *logExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	return e.monitor.export(ctx, e.monitor.logs, len(records), func(ctx context.Context) error {
+		return e.Exporter.Export(ctx, records)
+	})
+}
+
+// PeriodicReader は cfg の間隔と制限時間で exp にエクスポートし、エクスポートを計測する PeriodicReader を作成する
+func (m *Monitor) PeriodicReader(exp sdkmetric.Exporter, cfg ReaderConfig) *sdkmetric.PeriodicReader {
+	return sdkmetric.NewPeriodicReader(&metricExporter{Exporter: exp, monitor: m},
+		sdkmetric.WithInterval(cfg.Interval),
+		sdkmetric.WithTimeout(cfg.Timeout),
+	)
+}
+
+type metricExporter struct {
+	sdkmetric.Exporter
+	monitor *Monitor
+}
+
+func (e *metricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return e.monitor.export(ctx, e.monitor.metrics, dataPoints(rm), func(ctx context.Context) error {
+		return e.Exporter.Export(ctx, rm)
+	})
+}
+
+// dataPoints は rm に含まれるデータポイントの件数を返す
+func dataPoints(rm *metricdata.ResourceMetrics) int {
+	n := 0
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				n += len(data.DataPoints)
+			case metricdata.Gauge[float64]:
+				n += len(data.DataPoints)
+			case metricdata.Sum[int64]:
+				n += len(data.DataPoints)
+			case metricdata.Sum[float64]:
+				n += len(data.DataPoints)
+			case metricdata.Histogram[int64]:
+				n += len(data.DataPoints)
+			case metricdata.Histogram[float64]:
+				n += len(data.DataPoints)
+			case metricdata.ExponentialHistogram[int64]:
+				n += len(data.DataPoints)
+			case metricdata.ExponentialHistogram[float64]:
+				n += len(data.DataPoints)
+			case metricdata.Summary:
+				n += len(data.DataPoints)
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,124 @@
+package receiver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/response"
+	"github.com/go-chi/chi/v5"
+)
+
+// Routes は保持しているデータを JSON で返す問い合わせ API を返す
+//
+//	GET /stats                                        保持件数
+//	GET /traces?service=&route=&status=&min_duration=&limit= トレースの一覧（新しい順）
+//	GET /traces/{traceID}                             トレースのスパン
+//	GET /logs?service=&trace_id=&min_severity=&limit= ログレコード（新しい順）
+//	GET /metrics?service=&name=&limit=                メトリクスのデータポイント（新しい順）
+func (s *Store) Routes() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/stats", s.getStats)
+	r.Get("/traces", s.getTraces)
+	r.Get("/traces/{traceID}", s.getTrace)
+	r.Get("/logs", s.getLogs)
+	r.Get("/metrics", s.getMetrics)
+	return r
+}
+
+func (s *Store) getStats(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, r, http.StatusOK, s.Stats())
+}
+
+func (s *Store) getTraces(w http.ResponseWriter, r *http.Request) {
+	filter, err := traceFilter(r)
+	if err != nil {
+		response.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	response.JSON(w, r, http.StatusOK, s.Traces(filter))
+}
+
+// traceFilter はクエリパラメーターからトレース一覧の絞り込み条件を作成する
+func traceFilter(r *http.Request) (TraceFilter, error) {
+	q := r.URL.Query()
+	filter := TraceFilter{
+		Service: q.Get("service"),
+		Route:   q.Get("route"),
+		Status:  q.Get("status"),
+	}
+	switch filter.Status {
+	case "", StatusOK, StatusError:
+	default:
+		return TraceFilter{}, fmt.Errorf("invalid status: %q", filter.Status)
+	}
+	if v := q.Get("min_duration"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return TraceFilter{}, fmt.Errorf("invalid min_duration: %q", v)
+		}
+		filter.MinDuration = d
+	}
+	limit, err := queryInt(r, "limit")
+	if err != nil {
+		return TraceFilter{}, err
+	}
+	filter.Limit = limit
+	return filter, nil
+}
+
+func (s *Store) getTrace(w http.ResponseWriter, r *http.Request) {
+	traceID := chi.URLParam(r, "traceID")
+	spans := s.Trace(traceID)
+	if len(spans) == 0 {
+		response.Error(w, r, http.StatusNotFound, fmt.Errorf("trace %s not found", traceID))
+		return
+	}
+	response.JSON(w, r, http.StatusOK, spans)
+}
+
+func (s *Store) getLogs(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit")
+	if err != nil {
+		response.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	minSeverity, err := queryInt(r, "min_severity")
+	if err != nil {
+		response.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	response.JSON(w, r, http.StatusOK, s.Logs(LogFilter{
+		Service:     r.URL.Query().Get("service"),
+		TraceID:     r.URL.Query().Get("trace_id"),
+		MinSeverity: int32(minSeverity),
+		Limit:       limit,
+	}))
+}
+
+func (s *Store) getMetrics(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit")
+	if err != nil {
+		response.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+	response.JSON(w, r, http.StatusOK, s.Metrics(MetricFilter{
+		Service: r.URL.Query().Get("service"),
+		Name:    r.URL.Query().Get("name"),
+		Limit:   limit,
+	}))
+}
+
+// queryInt はクエリパラメーター key を整数として返す。未指定の場合は 0
+func queryInt(r *http.Request, key string) (int, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, v)
+	}
+	return n, nil
+}
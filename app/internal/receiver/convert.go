@@ -0,0 +1,228 @@
+package receiver
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// スパンのステータス
+const (
+	StatusUnset = "unset"
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+func convertSpans(resourceSpans []*tracepb.ResourceSpans) []Span {
+	var spans []Span
+	for _, rs := range resourceSpans {
+		resource := attributes(rs.GetResource().GetAttributes())
+		service := serviceName(rs.GetResource())
+		for _, ss := range rs.GetScopeSpans() {
+			scope := ss.GetScope().GetName()
+			for _, s := range ss.GetSpans() {
+				span := Span{
+					TraceID:       hex.EncodeToString(s.GetTraceId()),
+					SpanID:        hex.EncodeToString(s.GetSpanId()),
+					ParentSpanID:  hex.EncodeToString(s.GetParentSpanId()),
+					Service:       service,
+					Scope:         scope,
+					Name:          s.GetName(),
+					Kind:          enumName(s.GetKind().String(), "SPAN_KIND_"),
+					Start:         unixNano(s.GetStartTimeUnixNano()),
+					End:           unixNano(s.GetEndTimeUnixNano()),
+					StatusCode:    enumName(s.GetStatus().GetCode().String(), "STATUS_CODE_"),
+					StatusMessage: s.GetStatus().GetMessage(),
+					Attributes:    attributes(s.GetAttributes()),
+					Resource:      resource,
+				}
+				span.Duration = span.End.Sub(span.Start)
+				for _, e := range s.GetEvents() {
+					span.Events = append(span.Events, SpanEvent{
+						Name:       e.GetName(),
+						Time:       unixNano(e.GetTimeUnixNano()),
+						Attributes: attributes(e.GetAttributes()),
+					})
+				}
+				spans = append(spans, span)
+			}
+		}
+	}
+	return spans
+}
+
+func convertLogs(resourceLogs []*logspb.ResourceLogs) []LogRecord {
+	var logs []LogRecord
+	for _, rl := range resourceLogs {
+		service := serviceName(rl.GetResource())
+		for _, sl := range rl.GetScopeLogs() {
+			scope := sl.GetScope().GetName()
+			for _, r := range sl.GetLogRecords() {
+				t := r.GetTimeUnixNano()
+				if t == 0 {
+					t = r.GetObservedTimeUnixNano()
+				}
+				severity := r.GetSeverityText()
+				if severity == "" {
+					severity = enumName(r.GetSeverityNumber().String(), "SEVERITY_NUMBER_")
+				}
+				logs = append(logs, LogRecord{
+					Time:           unixNano(t),
+					Service:        service,
+					Scope:          scope,
+					EventName:      r.GetEventName(),
+					Severity:       severity,
+					SeverityNumber: int32(r.GetSeverityNumber()),
+					Body:           value(r.GetBody()),
+					TraceID:        hex.EncodeToString(r.GetTraceId()),
+					SpanID:         hex.EncodeToString(r.GetSpanId()),
+					Attributes:     attributes(r.GetAttributes()),
+				})
+			}
+		}
+	}
+	return logs
+}
+
+func convertMetrics(resourceMetrics []*metricspb.ResourceMetrics) []MetricPoint {
+	var points []MetricPoint
+	for _, rm := range resourceMetrics {
+		service := serviceName(rm.GetResource())
+		for _, sm := range rm.GetScopeMetrics() {
+			scope := sm.GetScope().GetName()
+			for _, m := range sm.GetMetrics() {
+				base := MetricPoint{
+					Service:     service,
+					Scope:       scope,
+					Name:        m.GetName(),
+					Description: m.GetDescription(),
+					Unit:        m.GetUnit(),
+				}
+				points = append(points, metricPoints(base, m)...)
+			}
+		}
+	}
+	return points
+}
+
+func metricPoints(base MetricPoint, m *metricspb.Metric) []MetricPoint {
+	var points []MetricPoint
+	number := func(typ string, dps []*metricspb.NumberDataPoint) {
+		for _, dp := range dps {
+			p := base
+			p.Type = typ
+			p.Time = unixNano(dp.GetTimeUnixNano())
+			p.Attributes = attributes(dp.GetAttributes())
+			switch v := dp.GetValue().(type) {
+			case *metricspb.NumberDataPoint_AsDouble:
+				p.Value = v.AsDouble
+			case *metricspb.NumberDataPoint_AsInt:
+				p.Value = float64(v.AsInt)
+			}
+			points = append(points, p)
+		}
+	}
+
+	switch data := m.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		number("gauge", data.Gauge.GetDataPoints())
+	case *metricspb.Metric_Sum:
+		number("sum", data.Sum.GetDataPoints())
+	case *metricspb.Metric_Histogram:
+		for _, dp := range data.Histogram.GetDataPoints() {
+			p := base
+			p.Type = "histogram"
+			p.Time = unixNano(dp.GetTimeUnixNano())
+			p.Attributes = attributes(dp.GetAttributes())
+			p.Count = dp.GetCount()
+			p.Sum = dp.GetSum()
+			points = append(points, p)
+		}
+	case *metricspb.Metric_ExponentialHistogram:
+		for _, dp := range data.ExponentialHistogram.GetDataPoints() {
+			p := base
+			p.Type = "exponential_histogram"
+			p.Time = unixNano(dp.GetTimeUnixNano())
+			p.Attributes = attributes(dp.GetAttributes())
+			p.Count = dp.GetCount()
+			p.Sum = dp.GetSum()
+			points = append(points, p)
+		}
+	case *metricspb.Metric_Summary:
+		for _, dp := range data.Summary.GetDataPoints() {
+			p := base
+			p.Type = "summary"
+			p.Time = unixNano(dp.GetTimeUnixNano())
+			p.Attributes = attributes(dp.GetAttributes())
+			p.Count = dp.GetCount()
+			p.Sum = dp.GetSum()
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+func serviceName(resource *resourcepb.Resource) string {
+	for _, kv := range resource.GetAttributes() {
+		if kv.GetKey() == "service.name" {
+			return kv.GetValue().GetStringValue()
+		}
+	}
+	return "unknown_service"
+}
+
+func attributes(kvs []*commonpb.KeyValue) map[string]any {
+	if len(kvs) == 0 {
+		return nil
+	}
+	attrs := make(map[string]any, len(kvs))
+	for _, kv := range kvs {
+		attrs[kv.GetKey()] = value(kv.GetValue())
+	}
+	return attrs
+}
+
+// value は AnyValue を JSON に変換できる Go の値にする
+func value(v *commonpb.AnyValue) any {
+	switch v := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return v.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return v.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(v.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]any, 0, len(v.ArrayValue.GetValues()))
+		for _, item := range v.ArrayValue.GetValues() {
+			values = append(values, value(item))
+		}
+		return values
+	case *commonpb.AnyValue_KvlistValue:
+		return attributes(v.KvlistValue.GetValues())
+	default:
+		return nil
+	}
+}
+
+func unixNano(ns uint64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ns)).UTC()
+}
+
+// enumName は "SPAN_KIND_SERVER" のような列挙値の名前を "server" にする
+func enumName(name, prefix string) string {
+	return strings.ToLower(strings.TrimPrefix(name, prefix))
+}
@@ -0,0 +1,243 @@
+package receiver
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/otlpjson"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Receiver は OTLP のエクスポートを受け付けて Store に保存する
+type Receiver struct {
+	store *Store
+}
+
+// New は store に保存する Receiver を作成する
+func New(store *Store) *Receiver {
+	return &Receiver{store: store}
+}
+
+// Store は受信したデータの保存先を返す
+func (rc *Receiver) Store() *Store {
+	return rc.store
+}
+
+// RegisterGRPC は OTLP/gRPC の各サービスを s に登録する
+func (rc *Receiver) RegisterGRPC(s *grpc.Server) {
+	coltracepb.RegisterTraceServiceServer(s, traceService{store: rc.store})
+	colmetricspb.RegisterMetricsServiceServer(s, metricsService{store: rc.store})
+	collogspb.RegisterLogsServiceServer(s, logsService{store: rc.store})
+}
+
+// ServeGRPC は addr で OTLP/gRPC を受け付ける。ctx が終了すると停止する
+func (rc *Receiver) ServeGRPC(ctx context.Context, addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	s := grpc.NewServer()
+	rc.RegisterGRPC(s)
+	go func() {
+		<-ctx.Done()
+		s.GracefulStop()
+	}()
+	slog.InfoContext(ctx, "Starting OTLP gRPC receiver", "addr", lis.Addr().String())
+	if err := s.Serve(lis); err != nil {
+		return fmt.Errorf("failed to serve OTLP gRPC: %w", err)
+	}
+	return nil
+}
+
+// Routes は OTLP/HTTP（/v1/traces, /v1/metrics, /v1/logs）と問い合わせ API（/api/...）、
+// トレースビューアー（/traces）を返す
+func (rc *Receiver) Routes() http.Handler {
+	r := chi.NewRouter()
+	r.Post("/v1/traces", rc.exportHTTP(func() exportRequest { return &traceRequest{store: rc.store} }))
+	r.Post("/v1/metrics", rc.exportHTTP(func() exportRequest { return &metricsRequest{store: rc.store} }))
+	r.Post("/v1/logs", rc.exportHTTP(func() exportRequest { return &logsRequest{store: rc.store} }))
+	r.Mount("/api", rc.store.Routes())
+	r.Mount("/traces", rc.store.UI("/traces"))
+	return r
+}
+
+type traceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+	store *Store
+}
+
+func (s traceService) Export(_ context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	s.store.AddSpans(convertSpans(req.GetResourceSpans()))
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+type metricsService struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	store *Store
+}
+
+func (s metricsService) Export(_ context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	s.store.AddMetrics(convertMetrics(req.GetResourceMetrics()))
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+type logsService struct {
+	collogspb.UnimplementedLogsServiceServer
+	store *Store
+}
+
+func (s logsService) Export(_ context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	s.store.AddLogs(convertLogs(req.GetResourceLogs()))
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+// exportRequest は OTLP/HTTP の 1 種類のリクエストとレスポンスの組
+type exportRequest interface {
+	request() proto.Message
+	save()
+	response() proto.Message
+}
+
+type traceRequest struct {
+	store *Store
+	req   coltracepb.ExportTraceServiceRequest
+}
+
+func (t *traceRequest) request() proto.Message { return &t.req }
+func (t *traceRequest) save()                  { t.store.AddSpans(convertSpans(t.req.GetResourceSpans())) }
+func (t *traceRequest) response() proto.Message {
+	return &coltracepb.ExportTraceServiceResponse{}
+}
+
+type metricsRequest struct {
+	store *Store
+	req   colmetricspb.ExportMetricsServiceRequest
+}
+
+func (m *metricsRequest) request() proto.Message { return &m.req }
+func (m *metricsRequest) save()                  { m.store.AddMetrics(convertMetrics(m.req.GetResourceMetrics())) }
+func (m *metricsRequest) response() proto.Message {
+	return &colmetricspb.ExportMetricsServiceResponse{}
+}
+
+type logsRequest struct {
+	store *Store
+	req   collogspb.ExportLogsServiceRequest
+}
+
+func (l *logsRequest) request() proto.Message { return &l.req }
+func (l *logsRequest) save()                  { l.store.AddLogs(convertLogs(l.req.GetResourceLogs())) }
+func (l *logsRequest) response() proto.Message {
+	return &collogspb.ExportLogsServiceResponse{}
+}
+
+// MaxRequestBytes は OTLP/HTTP で受け付けるリクエストボディの上限。gzip の場合は展開後のサイズにも適用する
+const MaxRequestBytes = 20 << 20
+
+// exportHTTP は protobuf または JSON でエンコードされた OTLP/HTTP のリクエストを受け付けるハンドラーを返す。
+// エラーは OTLP/HTTP の仕様に従い、リクエストと同じエンコーディングの google.rpc.Status で返す
+func (rc *Receiver) exportHTTP(newRequest func() exportRequest) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != contentTypeProtobuf && mediaType != contentTypeJSON {
+			writeStatus(w, r, contentTypeProtobuf, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type %q", mediaType))
+			return
+		}
+
+		var body io.ReadCloser = http.MaxBytesReader(w, r.Body, MaxRequestBytes)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(body)
+			if err != nil {
+				writeStatus(w, r, mediaType, readErrorStatus(err), fmt.Errorf("invalid gzip body: %w", err))
+				return
+			}
+			defer gz.Close()
+			body = http.MaxBytesReader(w, gz, MaxRequestBytes)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			writeStatus(w, r, mediaType, readErrorStatus(err), fmt.Errorf("failed to read body: %w", err))
+			return
+		}
+
+		req := newRequest()
+		if mediaType == contentTypeJSON {
+			err = otlpjson.Unmarshal(data, req.request())
+		} else {
+			err = proto.Unmarshal(data, req.request())
+		}
+		if err != nil {
+			writeStatus(w, r, mediaType, http.StatusBadRequest, fmt.Errorf("failed to decode OTLP request: %w", err))
+			return
+		}
+		req.save()
+
+		out, err := marshal(mediaType, req.response())
+		if err != nil {
+			writeStatus(w, r, mediaType, http.StatusInternalServerError, fmt.Errorf("failed to encode OTLP response: %w", err))
+			return
+		}
+		write(w, r, mediaType, http.StatusOK, out)
+	}
+}
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+// readErrorStatus はボディの読み込みエラーに対応するステータスを返す。上限を超えた場合は 413
+func readErrorStatus(err error) int {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// writeStatus は err を mediaType でエンコードした google.rpc.Status として status で返す
+func writeStatus(w http.ResponseWriter, r *http.Request, mediaType string, status int, err error) {
+	code := codes.InvalidArgument
+	if status >= http.StatusInternalServerError {
+		code = codes.Internal
+		trace.SpanFromContext(r.Context()).RecordError(err)
+	}
+	out, merr := marshal(mediaType, &spb.Status{Code: int32(code), Message: err.Error()})
+	if merr != nil {
+		slog.ErrorContext(r.Context(), "Failed to encode OTLP error status", "error", merr)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	write(w, r, mediaType, status, out)
+}
+
+func marshal(mediaType string, m proto.Message) ([]byte, error) {
+	if mediaType == contentTypeJSON {
+		return protojson.Marshal(m)
+	}
+	return proto.Marshal(m)
+}
+
+func write(w http.ResponseWriter, r *http.Request, mediaType string, status int, out []byte) {
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(status)
+	if _, err := w.Write(out); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write OTLP response", "error", err)
+	}
+}
@@ -0,0 +1,253 @@
+package receiver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// startGRPC は空きポートで OTLP/gRPC を受け付けるレシーバーを起動し、そのアドレスを返す
+func startGRPC(t *testing.T, rc *Receiver) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	rc.RegisterGRPC(s)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+func TestReceiver_GRPC(t *testing.T) {
+	store := NewStore(StoreOptions{})
+	addr := startGRPC(t, New(store))
+	ctx := context.Background()
+	res := resource.NewSchemaless(semconv.ServiceName("test-app"))
+
+	traceExp, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpoint(addr), otlptracegrpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(traceExp), sdktrace.WithResource(res))
+	tracer := tp.Tracer("test")
+	spanCtx, parent := tracer.Start(ctx, "GET /hello")
+	_, child := tracer.Start(spanCtx, "childHello")
+	child.AddEvent("work done")
+	child.SetStatus(codes.Error, "boom")
+	child.End()
+	parent.End()
+	if err := tp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	logExp, err := otlploggrpc.New(ctx, otlploggrpc.WithEndpoint(addr), otlploggrpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(logExp)), sdklog.WithResource(res))
+	var record log.Record
+	record.SetSeverity(log.SeverityWarn)
+	record.SetBody(log.StringValue("hello log"))
+	lp.Logger("test").Emit(spanCtx, record)
+	if err := lp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	traceID := parent.SpanContext().TraceID().String()
+	summaries := store.Traces(TraceFilter{Service: "test-app"})
+	if len(summaries) != 1 {
+		t.Fatalf("traces = %d, want 1", len(summaries))
+	}
+	if got := summaries[0]; got.TraceID != traceID || got.Name != "GET /hello" || got.SpanCount != 2 || got.Errors != 1 {
+		t.Errorf("trace summary = %+v", got)
+	}
+
+	spans := store.Trace(traceID)
+	if len(spans) != 2 || spans[1].Name != "childHello" || spans[1].ParentSpanID != parent.SpanContext().SpanID().String() {
+		t.Fatalf("spans = %+v", spans)
+	}
+	if len(spans[1].Events) != 1 || spans[1].Events[0].Name != "work done" {
+		t.Errorf("child events = %+v", spans[1].Events)
+	}
+
+	logs := store.Logs(LogFilter{TraceID: traceID})
+	if len(logs) != 1 || logs[0].Body != "hello log" || logs[0].Service != "test-app" {
+		t.Errorf("logs = %+v", logs)
+	}
+}
+
+func TestReceiver_HTTPJSON(t *testing.T) {
+	store := NewStore(StoreOptions{})
+	srv := httptest.NewServer(New(store).Routes())
+	defer srv.Close()
+
+	body := `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"nginx"}}]},
+		"scopeSpans":[{"spans":[{"traceId":"0af7651916cd43dd8448eb211c80319c","spanId":"b7ad6b7169203331",
+		"name":"GET /hello","kind":"SPAN_KIND_SERVER","startTimeUnixNano":"1760000000000000000","endTimeUnixNano":"1760000000005000000"}]}]}]}`
+	resp, err := http.Post(srv.URL+"/v1/traces", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/traces/0af7651916cd43dd8448eb211c80319c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var spans []Span
+	if err := json.NewDecoder(resp.Body).Decode(&spans); err != nil {
+		t.Fatalf("status = %d: %v", resp.StatusCode, err)
+	}
+	if len(spans) != 1 || spans[0].Service != "nginx" || spans[0].Kind != "server" || spans[0].Duration != 5*time.Millisecond {
+		t.Errorf("spans = %+v", spans)
+	}
+
+	resp, err = http.Post(srv.URL+"/v1/traces", "text/plain", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("text/plain status = %d, want 415", resp.StatusCode)
+	}
+}
+
+func TestReceiver_HTTPErrors(t *testing.T) {
+	srv := httptest.NewServer(New(NewStore(StoreOptions{})).Routes())
+	defer srv.Close()
+
+	var bomb bytes.Buffer
+	gz := gzip.NewWriter(&bomb)
+	if _, err := gz.Write(make([]byte, MaxRequestBytes+1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name        string
+		contentType string
+		gzip        bool
+		body        []byte
+		wantStatus  int
+		wantType    string
+	}{
+		{"invalid protobuf", "application/x-protobuf", false, []byte{0xff}, http.StatusBadRequest, "application/x-protobuf"},
+		{"invalid json", "application/json", false, []byte(`{`), http.StatusBadRequest, "application/json"},
+		{"too large", "application/json", false, make([]byte, MaxRequestBytes+1), http.StatusRequestEntityTooLarge, "application/json"},
+		{"too large after gzip", "application/x-protobuf", true, bomb.Bytes(), http.StatusRequestEntityTooLarge, "application/x-protobuf"},
+		{"unsupported content type", "text/plain", false, []byte(`hello`), http.StatusUnsupportedMediaType, "application/x-protobuf"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/v1/traces", bytes.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.gzip {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Header.Get("Content-Type"); got != tt.wantType {
+				t.Fatalf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+
+			// エラーはリクエストと同じエンコーディングの google.rpc.Status で返す
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var st spb.Status
+			if tt.wantType == "application/json" {
+				err = protojson.Unmarshal(data, &st)
+			} else {
+				err = proto.Unmarshal(data, &st)
+			}
+			if err != nil {
+				t.Fatalf("failed to decode status %q: %v", data, err)
+			}
+			if st.GetCode() != int32(grpccodes.InvalidArgument) || st.GetMessage() == "" {
+				t.Errorf("status = %v, want InvalidArgument with a message", &st)
+			}
+		})
+	}
+}
+
+func TestStore_Retention(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewStore(StoreOptions{MaxSpans: 3, Retention: time.Minute})
+	store.now = func() time.Time { return now }
+
+	store.AddSpans([]Span{{TraceID: "a"}, {TraceID: "b"}})
+	store.AddSpans([]Span{{TraceID: "c"}, {TraceID: "d"}})
+	if got := store.Stats().Spans; got != 3 {
+		t.Errorf("spans after exceeding max = %d, want 3", got)
+	}
+	if len(store.Trace("a")) != 0 {
+		t.Error("oldest span was not evicted")
+	}
+
+	// 新しいデータが届かなくても、読み出し時に保持期間を過ぎたものは返さない
+	now = now.Add(2 * time.Minute)
+	if got := store.Stats().Spans; got != 0 {
+		t.Errorf("spans after retention without new data = %d, want 0", got)
+	}
+	if len(store.Trace("d")) != 0 || len(store.Traces(TraceFilter{})) != 0 {
+		t.Error("expired spans are still returned")
+	}
+
+	store.AddSpans([]Span{{TraceID: "e"}})
+	if got := store.Stats().Spans; got != 1 {
+		t.Errorf("spans after retention = %d, want 1", got)
+	}
+
+	// 上限に達した後も追加を続けると、破棄した領域を詰めて上限の件数を保持する
+	for i := range 100 {
+		store.AddSpans([]Span{{TraceID: fmt.Sprint(i)}})
+	}
+	if got := store.Stats().Spans; got != 3 {
+		t.Errorf("spans after many batches = %d, want 3", got)
+	}
+	if len(store.Trace("99")) != 1 || len(store.Trace("96")) != 0 {
+		t.Error("store does not keep the newest spans")
+	}
+	if n := len(store.spans.items); n > 6 {
+		t.Errorf("buffer length = %d, want at most twice the max", n)
+	}
+}
@@ -0,0 +1,465 @@
+// Package receiver は OTLP（gRPC / HTTP）でトレース・メトリクス・ログを受け付け、
+// メモリに保持して問い合わせ API で返す、ローカル検証用のレシーバー。
+//
+// アプリケーションに組み込んで起動するか（RECEIVER_ENABLED）、cmd/otlpreceiver を
+// サイドカーとして起動すると、外部の otel-tui などがなくても nginx とアプリのテレメトリを確認できる。
+package receiver
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// StoreOptions は保持するデータの上限。0 の場合は既定値を使う
+type StoreOptions struct {
+	MaxSpans        int
+	MaxLogs         int
+	MaxMetricPoints int
+	// Retention より古いデータは破棄する
+	Retention time.Duration
+}
+
+// 保持数と保持期間の既定値
+const (
+	DefaultMaxSpans        = 10000
+	DefaultMaxLogs         = 10000
+	DefaultMaxMetricPoints = 10000
+	DefaultRetention       = 15 * time.Minute
+)
+
+// Span は受信したスパン
+type Span struct {
+	TraceID       string         `json:"trace_id"`
+	SpanID        string         `json:"span_id"`
+	ParentSpanID  string         `json:"parent_span_id,omitempty"`
+	Service       string         `json:"service"`
+	Scope         string         `json:"scope"`
+	Name          string         `json:"name"`
+	Kind          string         `json:"kind"`
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end"`
+	Duration      time.Duration  `json:"duration_ns"`
+	StatusCode    string         `json:"status_code"`
+	StatusMessage string         `json:"status_message,omitempty"`
+	Attributes    map[string]any `json:"attributes,omitempty"`
+	Events        []SpanEvent    `json:"events,omitempty"`
+	Resource      map[string]any `json:"resource,omitempty"`
+}
+
+// SpanEvent はスパンのイベント
+type SpanEvent struct {
+	Name       string         `json:"name"`
+	Time       time.Time      `json:"time"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// LogRecord は受信したログレコード
+type LogRecord struct {
+	Time           time.Time      `json:"time"`
+	Service        string         `json:"service"`
+	Scope          string         `json:"scope"`
+	EventName      string         `json:"event_name,omitempty"`
+	Severity       string         `json:"severity"`
+	SeverityNumber int32          `json:"severity_number"`
+	Body           any            `json:"body"`
+	TraceID        string         `json:"trace_id,omitempty"`
+	SpanID         string         `json:"span_id,omitempty"`
+	Attributes     map[string]any `json:"attributes,omitempty"`
+}
+
+// MetricPoint は受信したメトリクスのデータポイント 1 件
+type MetricPoint struct {
+	Time        time.Time `json:"time"`
+	Service     string    `json:"service"`
+	Scope       string    `json:"scope"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Unit        string    `json:"unit,omitempty"`
+	// Type は gauge / sum / histogram / exponential_histogram / summary
+	Type       string         `json:"type"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	// Value は gauge / sum の値
+	Value float64 `json:"value"`
+	// Count と Sum はヒストグラム・サマリーの値
+	Count uint64  `json:"count,omitempty"`
+	Sum   float64 `json:"sum,omitempty"`
+}
+
+// Store は受信したテレメトリをメモリに保持する。上限を超えると古いものから破棄する
+type Store struct {
+	opts StoreOptions
+	now  func() time.Time
+
+	mu      sync.RWMutex
+	spans   buffer[Span]
+	logs    buffer[LogRecord]
+	metrics buffer[MetricPoint]
+}
+
+type stored[T any] struct {
+	received time.Time
+	value    T
+}
+
+// buffer は受信した順にデータを保持する。items[head:] が保持中のデータで、
+// 先頭からの破棄は head を進めるだけにし、破棄した領域が半分を超えたときにまとめて詰める
+type buffer[T any] struct {
+	items []stored[T]
+	head  int
+}
+
+// add は values を追加し、保持期間を過ぎたものと上限を超えた古いものを破棄する
+func (b *buffer[T]) add(values []T, now time.Time, max int, retention time.Duration) {
+	for _, v := range values {
+		b.items = append(b.items, stored[T]{received: now, value: v})
+	}
+	drop := b.head + b.expired(now, retention)
+	if over := len(b.items) - drop - max; over > 0 {
+		drop += over
+	}
+	// 破棄した要素を参照し続けないようにゼロ値にする
+	clear(b.items[b.head:drop])
+	b.head = drop
+	if b.head > len(b.items)/2 {
+		b.items = append(b.items[:0:0], b.items[b.head:]...)
+		b.head = 0
+	}
+}
+
+// expired は保持中のデータのうち、先頭から保持期間を過ぎている件数を返す
+func (b *buffer[T]) expired(now time.Time, retention time.Duration) int {
+	live := b.items[b.head:]
+	return sort.Search(len(live), func(i int) bool {
+		return now.Sub(live[i].received) <= retention
+	})
+}
+
+// live は保持期間内のデータを古い順に返す。追加がなくても、読み出し時に保持期間を過ぎたものは含めない
+func (b *buffer[T]) live(now time.Time, retention time.Duration) []stored[T] {
+	return b.items[b.head+b.expired(now, retention):]
+}
+
+// NewStore は opts の上限でデータを保持する Store を作成する
+func NewStore(opts StoreOptions) *Store {
+	if opts.MaxSpans <= 0 {
+		opts.MaxSpans = DefaultMaxSpans
+	}
+	if opts.MaxLogs <= 0 {
+		opts.MaxLogs = DefaultMaxLogs
+	}
+	if opts.MaxMetricPoints <= 0 {
+		opts.MaxMetricPoints = DefaultMaxMetricPoints
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	return &Store{opts: opts, now: time.Now}
+}
+
+// AddSpans はスパンを追加する
+func (s *Store) AddSpans(spans []Span) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spans.add(spans, s.now(), s.opts.MaxSpans, s.opts.Retention)
+}
+
+// AddLogs はログレコードを追加する
+func (s *Store) AddLogs(logs []LogRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs.add(logs, s.now(), s.opts.MaxLogs, s.opts.Retention)
+}
+
+// AddMetrics はメトリクスのデータポイントを追加する
+func (s *Store) AddMetrics(points []MetricPoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics.add(points, s.now(), s.opts.MaxMetricPoints, s.opts.Retention)
+}
+
+// TraceSummary はトレース一覧の 1 件
+type TraceSummary struct {
+	TraceID string `json:"trace_id"`
+	Service string `json:"service"`
+	Name    string `json:"name"`
+	// Route は最初に http.route 属性を持つスパンのルート
+	Route     string        `json:"route,omitempty"`
+	Start     time.Time     `json:"start"`
+	Duration  time.Duration `json:"duration_ns"`
+	SpanCount int           `json:"span_count"`
+	Errors    int           `json:"errors"`
+	Services  []string      `json:"services"`
+}
+
+// TraceFilter はトレース一覧の絞り込み条件
+type TraceFilter struct {
+	// Service を含むトレースのみ返す
+	Service string
+	// Route を http.route に持つスパンを含むトレースのみ返す
+	Route string
+	// Status が StatusError の場合はエラーのスパンを含むトレース、StatusOK の場合は含まないトレースのみ返す
+	Status string
+	// MinDuration 未満のトレースは返さない
+	MinDuration time.Duration
+	// Limit は返す件数の上限。0 の場合は 100
+	Limit int
+}
+
+// match は summary が絞り込み条件を満たすかを返す
+func (f TraceFilter) match(summary TraceSummary, routes map[string]bool) bool {
+	if f.Service != "" && !contains(summary.Services, f.Service) {
+		return false
+	}
+	if f.Route != "" && !routes[f.Route] {
+		return false
+	}
+	switch f.Status {
+	case StatusError:
+		if summary.Errors == 0 {
+			return false
+		}
+	case StatusOK:
+		if summary.Errors > 0 {
+			return false
+		}
+	}
+	return summary.Duration >= f.MinDuration
+}
+
+// Traces は新しい順にトレースの一覧を返す
+func (s *Store) Traces(filter TraceFilter) []TraceSummary {
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+
+	s.mu.RLock()
+	byTrace := make(map[string][]Span)
+	for _, item := range s.liveSpans() {
+		byTrace[item.value.TraceID] = append(byTrace[item.value.TraceID], item.value)
+	}
+	s.mu.RUnlock()
+
+	summaries := make([]TraceSummary, 0, len(byTrace))
+	for traceID, spans := range byTrace {
+		summary := summarize(traceID, spans)
+		if !filter.match(summary, spanRoutes(spans)) {
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Start.After(summaries[j].Start)
+	})
+	if len(summaries) > filter.Limit {
+		summaries = summaries[:filter.Limit]
+	}
+	return summaries
+}
+
+// summarize はルートスパン（親がトレースに含まれないスパンのうち最も早いもの）を代表としてトレースを要約する
+func summarize(traceID string, spans []Span) TraceSummary {
+	ids := make(map[string]bool, len(spans))
+	for _, span := range spans {
+		ids[span.SpanID] = true
+	}
+
+	summary := TraceSummary{TraceID: traceID, SpanCount: len(spans)}
+	var root, routed *Span
+	var end time.Time
+	services := make(map[string]bool)
+	for i, span := range spans {
+		if span.ParentSpanID == "" || !ids[span.ParentSpanID] {
+			if root == nil || span.Start.Before(root.Start) {
+				root = &spans[i]
+			}
+		}
+		if summary.Start.IsZero() || span.Start.Before(summary.Start) {
+			summary.Start = span.Start
+		}
+		if span.End.After(end) {
+			end = span.End
+		}
+		if span.StatusCode == StatusError {
+			summary.Errors++
+		}
+		if route(span) != "" && (routed == nil || span.Start.Before(routed.Start)) {
+			routed = &spans[i]
+		}
+		services[span.Service] = true
+	}
+	if root != nil {
+		summary.Service = root.Service
+		summary.Name = root.Name
+	}
+	if routed != nil {
+		summary.Route = route(*routed)
+	}
+	summary.Duration = end.Sub(summary.Start)
+	summary.Services = sortedKeys(services)
+	return summary
+}
+
+// route はスパンの http.route 属性を返す
+func route(span Span) string {
+	r, _ := span.Attributes["http.route"].(string)
+	return r
+}
+
+func spanRoutes(spans []Span) map[string]bool {
+	routes := make(map[string]bool)
+	for _, span := range spans {
+		if r := route(span); r != "" {
+			routes[r] = true
+		}
+	}
+	return routes
+}
+
+// Services は保持しているスパンのサービス名を名前の順に返す
+func (s *Store) Services() []string {
+	s.mu.RLock()
+	seen := make(map[string]bool)
+	for _, item := range s.liveSpans() {
+		seen[item.value.Service] = true
+	}
+	s.mu.RUnlock()
+	return sortedKeys(seen)
+}
+
+// SpanRoutes は保持しているスパンの http.route を名前の順に返す
+func (s *Store) SpanRoutes() []string {
+	s.mu.RLock()
+	seen := make(map[string]bool)
+	for _, item := range s.liveSpans() {
+		if r := route(item.value); r != "" {
+			seen[r] = true
+		}
+	}
+	s.mu.RUnlock()
+	return sortedKeys(seen)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Trace は traceID のスパンを開始時刻の順に返す
+func (s *Store) Trace(traceID string) []Span {
+	s.mu.RLock()
+	var spans []Span
+	for _, item := range s.liveSpans() {
+		if item.value.TraceID == traceID {
+			spans = append(spans, item.value)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+	return spans
+}
+
+// LogFilter はログの絞り込み条件
+type LogFilter struct {
+	Service string
+	TraceID string
+	// MinSeverity 未満のレコードは返さない
+	MinSeverity int32
+	// Limit は返す件数の上限。0 の場合は 100
+	Limit int
+}
+
+// Logs は新しい順にログレコードを返す
+func (s *Store) Logs(filter LogFilter) []LogRecord {
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := s.logs.live(s.now(), s.opts.Retention)
+	var logs []LogRecord
+	for i := len(items) - 1; i >= 0 && len(logs) < filter.Limit; i-- {
+		record := items[i].value
+		if filter.Service != "" && record.Service != filter.Service {
+			continue
+		}
+		if filter.TraceID != "" && record.TraceID != filter.TraceID {
+			continue
+		}
+		if record.SeverityNumber < filter.MinSeverity {
+			continue
+		}
+		logs = append(logs, record)
+	}
+	return logs
+}
+
+// MetricFilter はメトリクスの絞り込み条件
+type MetricFilter struct {
+	Service string
+	Name    string
+	// Limit は返す件数の上限。0 の場合は 100
+	Limit int
+}
+
+// Metrics は新しい順にデータポイントを返す
+func (s *Store) Metrics(filter MetricFilter) []MetricPoint {
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := s.metrics.live(s.now(), s.opts.Retention)
+	var points []MetricPoint
+	for i := len(items) - 1; i >= 0 && len(points) < filter.Limit; i-- {
+		point := items[i].value
+		if filter.Service != "" && point.Service != filter.Service {
+			continue
+		}
+		if filter.Name != "" && point.Name != filter.Name {
+			continue
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+// Stats は保持しているデータの件数
+type Stats struct {
+	Spans        int `json:"spans"`
+	Logs         int `json:"logs"`
+	MetricPoints int `json:"metric_points"`
+}
+
+// Stats は保持しているデータの件数を返す
+func (s *Store) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := s.now()
+	return Stats{
+		Spans:        len(s.spans.live(now, s.opts.Retention)),
+		Logs:         len(s.logs.live(now, s.opts.Retention)),
+		MetricPoints: len(s.metrics.live(now, s.opts.Retention)),
+	}
+}
+
+// liveSpans は保持期間内のスパンを返す。s.mu を読み取りロックした状態で呼び出すこと
+func (s *Store) liveSpans() []stored[Span] {
+	return s.spans.live(s.now(), s.opts.Retention)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
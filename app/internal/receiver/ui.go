@@ -0,0 +1,183 @@
+package receiver
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"duration": formatDuration,
+	"color":    serviceColor,
+}).ParseFS(templateFS, "templates/*.html"))
+
+// UI は保持しているトレースを HTML で表示するハンドラーを返す。
+// prefix はマウントするパスで、画面内のリンクに使う（例: /traces）
+//
+//	GET /            トレースの一覧（service, route, status, min_duration, limit で絞り込み）
+//	GET /{traceID}   トレースのウォーターフォール
+func (s *Store) UI(prefix string) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	r := chi.NewRouter()
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) { s.traceListPage(w, r, prefix) })
+	r.Get("/{traceID}", func(w http.ResponseWriter, r *http.Request) { s.tracePage(w, r, prefix) })
+	return r
+}
+
+type traceListView struct {
+	Prefix   string
+	Query    map[string]string
+	Error    string
+	Services []string
+	Routes   []string
+	Traces   []TraceSummary
+}
+
+func (s *Store) traceListPage(w http.ResponseWriter, r *http.Request, prefix string) {
+	view := traceListView{
+		Prefix:   prefix,
+		Query:    make(map[string]string),
+		Services: s.Services(),
+		Routes:   s.SpanRoutes(),
+	}
+	for _, key := range []string{"service", "route", "status", "min_duration", "limit"} {
+		view.Query[key] = r.URL.Query().Get(key)
+	}
+	status := http.StatusOK
+	filter, err := traceFilter(r)
+	if err != nil {
+		status = http.StatusBadRequest
+		view.Error = err.Error()
+	} else {
+		view.Traces = s.Traces(filter)
+	}
+	render(w, r, status, "traces.html", view)
+}
+
+type traceView struct {
+	Prefix  string
+	TraceID string
+	Summary TraceSummary
+	Rows    []waterfallRow
+	Logs    []LogRecord
+}
+
+// waterfallRow はウォーターフォールの 1 行。Offset と Width はトレース全体に対する割合（%）
+type waterfallRow struct {
+	Span   Span
+	Depth  int
+	Offset float64
+	Width  float64
+	Events []eventMark
+}
+
+type eventMark struct {
+	Event  SpanEvent
+	Offset float64
+}
+
+func (s *Store) tracePage(w http.ResponseWriter, r *http.Request, prefix string) {
+	traceID := chi.URLParam(r, "traceID")
+	spans := s.Trace(traceID)
+	view := traceView{Prefix: prefix, TraceID: traceID}
+	if len(spans) == 0 {
+		render(w, r, http.StatusNotFound, "trace.html", view)
+		return
+	}
+	view.Summary = summarize(traceID, spans)
+	view.Rows = waterfall(spans, view.Summary.Start, view.Summary.Duration)
+	view.Logs = s.Logs(LogFilter{TraceID: traceID})
+	render(w, r, http.StatusOK, "trace.html", view)
+}
+
+// waterfall は spans を親子関係の深さ優先（兄弟は開始時刻の順）に並べ、各行の位置を計算する。
+// 親がトレースに含まれないスパン（nginx の前段など）は最上位に置く
+func waterfall(spans []Span, start time.Time, total time.Duration) []waterfallRow {
+	ids := make(map[string]bool, len(spans))
+	for _, span := range spans {
+		ids[span.SpanID] = true
+	}
+	children := make(map[string][]Span)
+	var roots []Span
+	for _, span := range spans {
+		if span.ParentSpanID == "" || !ids[span.ParentSpanID] {
+			roots = append(roots, span)
+			continue
+		}
+		children[span.ParentSpanID] = append(children[span.ParentSpanID], span)
+	}
+
+	percent := func(t time.Time) float64 {
+		if total <= 0 {
+			return 0
+		}
+		return float64(t.Sub(start)) / float64(total) * 100
+	}
+
+	var rows []waterfallRow
+	var walk func(spans []Span, depth int)
+	walk = func(spans []Span, depth int) {
+		sort.SliceStable(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+		for _, span := range spans {
+			row := waterfallRow{
+				Span:   span,
+				Depth:  depth,
+				Offset: percent(span.Start),
+				Width:  percent(span.End) - percent(span.Start),
+			}
+			for _, e := range span.Events {
+				row.Events = append(row.Events, eventMark{Event: e, Offset: percent(e.Time)})
+			}
+			rows = append(rows, row)
+			walk(children[span.SpanID], depth+1)
+		}
+	}
+	walk(roots, 0)
+	return rows
+}
+
+// render はテンプレートをバッファに描画してから書き込み、描画に失敗した場合は 500 を返す
+func render(w http.ResponseWriter, r *http.Request, status int, name string, data any) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to render template", "template", name, "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := buf.WriteTo(w); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write page", "error", err)
+	}
+}
+
+// formatDuration は表示用に桁を丸めた所要時間を返す
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
+
+// serviceColor はサービスごとに固定の色を返す
+func serviceColor(service string) template.CSS {
+	h := fnv.New32a()
+	h.Write([]byte(service))
+	return template.CSS(fmt.Sprintf("hsl(%d, 55%%, 55%%)", h.Sum32()%360))
+}
@@ -0,0 +1,119 @@
+package receiver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// demoSpans は nginx → アプリ → childHello の 1 トレースと、遅いエラーのトレースを返す
+func demoSpans() []Span {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	span := func(traceID, spanID, parent, service, name string, offset, duration time.Duration) Span {
+		return Span{
+			TraceID: traceID, SpanID: spanID, ParentSpanID: parent, Service: service, Name: name,
+			Start: start.Add(offset), End: start.Add(offset + duration), Duration: duration, StatusCode: StatusUnset,
+		}
+	}
+	nginx := span("t1", "s1", "", "nginx", "GET /hello", 0, 10*time.Millisecond)
+	app := span("t1", "s2", "s1", "go-app", "/hello", time.Millisecond, 8*time.Millisecond)
+	app.Attributes = map[string]any{"http.route": "/hello"}
+	child := span("t1", "s3", "s2", "go-app", "childHello", 2*time.Millisecond, 5*time.Millisecond)
+	child.Events = []SpanEvent{{Name: "work done", Time: start.Add(4 * time.Millisecond)}}
+
+	slow := span("t2", "s4", "", "go-app", "/slow", time.Second, 2*time.Second)
+	slow.Attributes = map[string]any{"http.route": "/slow"}
+	slow.StatusCode = StatusError
+	return []Span{child, app, nginx, slow}
+}
+
+func TestStore_TracesFilter(t *testing.T) {
+	store := NewStore(StoreOptions{})
+	store.AddSpans(demoSpans())
+
+	tests := []struct {
+		name   string
+		filter TraceFilter
+		want   []string
+	}{
+		{"all", TraceFilter{}, []string{"t2", "t1"}},
+		{"service", TraceFilter{Service: "nginx"}, []string{"t1"}},
+		{"route of child span", TraceFilter{Route: "/hello"}, []string{"t1"}},
+		{"error", TraceFilter{Status: StatusError}, []string{"t2"}},
+		{"ok", TraceFilter{Status: StatusOK}, []string{"t1"}},
+		{"min duration", TraceFilter{MinDuration: time.Second}, []string{"t2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, s := range store.Traces(tt.filter) {
+				got = append(got, s.TraceID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("traces = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := store.Traces(TraceFilter{Service: "nginx"})[0]; got.Name != "GET /hello" || got.Route != "/hello" {
+		t.Errorf("summary = %+v, want root GET /hello with route /hello", got)
+	}
+}
+
+func TestWaterfall(t *testing.T) {
+	spans := demoSpans()[:3]
+	summary := summarize("t1", spans)
+	rows := waterfall(spans, summary.Start, summary.Duration)
+
+	var got []string
+	for _, row := range rows {
+		got = append(got, strings.Repeat(" ", row.Depth)+row.Span.Name)
+	}
+	if want := "GET /hello, /hello,  childHello"; strings.Join(got, ",") != want {
+		t.Errorf("rows = %q, want %q", strings.Join(got, ","), want)
+	}
+	if child := rows[2]; child.Offset != 20 || child.Width != 50 || len(child.Events) != 1 || child.Events[0].Offset != 40 {
+		t.Errorf("child row = %+v", child)
+	}
+}
+
+func TestUI(t *testing.T) {
+	store := NewStore(StoreOptions{})
+	store.AddSpans(demoSpans())
+	srv := httptest.NewServer(New(store).Routes())
+	defer srv.Close()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := get("/traces/?status=error")
+	if status != http.StatusOK || !strings.Contains(body, `href="/traces/t2"`) || strings.Contains(body, `href="/traces/t1"`) {
+		t.Errorf("list: status = %d, body = %s", status, body)
+	}
+
+	status, body = get("/traces/t1")
+	if status != http.StatusOK || !strings.Contains(body, "childHello") || !strings.Contains(body, "work done") {
+		t.Errorf("trace: status = %d, body = %s", status, body)
+	}
+
+	if status, _ = get("/traces/unknown"); status != http.StatusNotFound {
+		t.Errorf("unknown trace status = %d, want 404", status)
+	}
+	if status, _ = get("/traces/?min_duration=fast"); status != http.StatusBadRequest {
+		t.Errorf("invalid filter status = %d, want 400", status)
+	}
+}
@@ -0,0 +1,31 @@
+package response
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+type loggerKey struct{}
+
+// WithLogger は ctx のリクエストでマーシャルや書き込みの失敗を出力するロガーを設定する
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Middleware は後続のハンドラーのレスポンス出力で logger を使うミドルウェアを返す
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(WithLogger(r.Context(), logger)))
+		})
+	}
+}
+
+// loggerFrom は WithLogger で設定したロガーを返す。設定されていない場合は slog.Default() を返す
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
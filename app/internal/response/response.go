@@ -0,0 +1,131 @@
+// Package response は HTTP ハンドラー共通のレスポンス出力を提供する。
+//
+// エラーは RFC 7807 の application/problem+json 形式で返し、
+// ステータスコードに応じてアクティブなスパンのステータスと error.type 属性を設定する。
+// マーシャルや書き込みの失敗は握りつぶさず、WithLogger で設定したロガー（未設定の場合は slog.Default()）に
+// リクエストのコンテキスト付きで出力する。
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ContentTypeJSON    = "application/json; charset=utf-8"
+	ContentTypeProblem = "application/problem+json; charset=utf-8"
+	ContentTypeText    = "text/plain; charset=utf-8"
+)
+
+// Problem は RFC 7807 の Problem Details オブジェクト
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Extensions は RFC 7807 の拡張メンバー。標準メンバーと同じ階層に出力される
+	Extensions map[string]any `json:"-"`
+}
+
+// NewProblem は status に対応する Title を設定した Problem を作成する
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// MarshalJSON は拡張メンバーを標準メンバーと同じ階層に展開する
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	m["type"] = p.Type
+	m["title"] = p.Title
+	m["status"] = p.Status
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+// JSON は v を JSON として status で返す
+func JSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		loggerFrom(r.Context()).ErrorContext(r.Context(), "Failed to marshal response", "error", err)
+		Error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	write(w, r, status, ContentTypeJSON, data)
+}
+
+// Text は s をプレーンテキストとして status で返す
+func Text(w http.ResponseWriter, r *http.Request, status int, s string) {
+	write(w, r, status, ContentTypeText, []byte(s))
+}
+
+// Error は err を Problem として status で返す。
+// 5xx の場合はスパンに err を記録し、ステータスを Error に設定する
+func Error(w http.ResponseWriter, r *http.Request, status int, err error) {
+	detail := ""
+	if err != nil {
+		detail = err.Error()
+		if status >= http.StatusInternalServerError {
+			trace.SpanFromContext(r.Context()).RecordError(err)
+		}
+	}
+	WriteProblem(w, r, NewProblem(status, detail))
+}
+
+// WriteProblem は p を application/problem+json で返す。
+//
+// WithTraceID を設定したリクエストでは trace_id を拡張メンバーに含める。
+// セマンティック規約に従い、5xx の場合のみサーバースパンのステータスを Error にして error.type 属性を設定する。
+// 4xx はクライアント起因のため Unset のままにし、error.type も設定しない。
+func WriteProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	addTraceID(r.Context(), p)
+
+	span := trace.SpanFromContext(r.Context())
+	if p.Status >= http.StatusInternalServerError {
+		span.SetAttributes(semconv.ErrorTypeKey.String(strconv.Itoa(p.Status)))
+		span.SetStatus(codes.Error, p.Title)
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		loggerFrom(r.Context()).ErrorContext(r.Context(), "Failed to marshal problem", "error", err)
+		data = []byte(`{"type":"about:blank","title":"Internal Server Error","status":500}`)
+	}
+	write(w, r, p.Status, ContentTypeProblem, data)
+}
+
+func write(w http.ResponseWriter, r *http.Request, status int, contentType string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if _, err := w.Write(data); err != nil {
+		loggerFrom(r.Context()).ErrorContext(r.Context(), "Failed to write response", "error", err, "status", status)
+	}
+}
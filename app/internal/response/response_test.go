@@ -0,0 +1,36 @@
+package response
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// failingWriter は Write が常に失敗する http.ResponseWriter
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		JSON(w, r, http.StatusOK, map[string]string{"message": "hello"})
+	})
+	handler = Middleware(logger)(handler)
+	handler.ServeHTTP(failingWriter{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// 書き込みの失敗はグローバルなロガーではなく、設定したロガーに出力する
+	if out := buf.String(); !strings.Contains(out, "Failed to write response") || !strings.Contains(out, "connection reset") {
+		t.Errorf("log = %q, want the write failure", out)
+	}
+}
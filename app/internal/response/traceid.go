@@ -0,0 +1,39 @@
+package response
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type traceIDKey struct{}
+
+type traceIDOptions struct {
+	traceURL func(traceID string) string
+}
+
+// WithTraceID は ctx のリクエストで返す Problem に、アクティブなスパンの trace_id を含めるようにする。
+// traceURL を指定すると、その戻り値を trace_url として含める（トレースビューアーへのリンクなど）
+func WithTraceID(ctx context.Context, traceURL func(traceID string) string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceIDOptions{traceURL: traceURL})
+}
+
+// addTraceID は WithTraceID が設定されている場合に p の拡張メンバーへ trace_id と trace_url を追加する
+func addTraceID(ctx context.Context, p *Problem) {
+	opts, ok := ctx.Value(traceIDKey{}).(traceIDOptions)
+	if !ok {
+		return
+	}
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return
+	}
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]any, 2)
+	}
+	traceID := sc.TraceID().String()
+	p.Extensions["trace_id"] = traceID
+	if opts.traceURL != nil {
+		p.Extensions["trace_url"] = opts.traceURL(traceID)
+	}
+}
@@ -0,0 +1,176 @@
+// Package routecheck は記録したトレースから、nginx のスパン名とアプリケーションの http.route が
+// 一致しているかを検証する。
+//
+// 入力は OTLP/JSON 形式（ExportTraceServiceRequest）のトレースで、collector の file エクスポーターのように
+// 1 行に 1 リクエストを書き込んだ JSON Lines にも対応する。
+package routecheck
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// NginxServiceName は nginx のスパンの service.name（nginx.conf の otel_service_name）
+const NginxServiceName = "nginx"
+
+// spanKindServer は OTLP の SPAN_KIND_SERVER
+const spanKindServer = 2
+
+// Mismatch は nginx のスパン名がアプリケーションのルートと一致しなかったルート
+type Mismatch struct {
+	// Want は http.route から期待される nginx のスパン名（"GET /users/{id}"）
+	Want string `json:"want"`
+	// Route はアプリケーションのスパンの http.route 属性
+	Route string `json:"route"`
+	// NginxSpanNames は実際の親スパン（nginx）の名前。ソート済み
+	NginxSpanNames []string `json:"nginx_span_names"`
+	// Count は一致しなかったスパン数
+	Count int `json:"count"`
+	// TraceID は該当するトレースの 1 つ
+	TraceID string `json:"trace_id"`
+}
+
+// Result は検証結果
+type Result struct {
+	// Checked は nginx のスパンを親に持つアプリケーションのスパン数
+	Checked int `json:"checked"`
+	// Mismatches は期待するスパン名で並べた不一致の一覧
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+// Check は r のトレースを読み込み、http.route を持つスパンと親スパンの名前を比較する。
+// 親スパンがエクスポートに含まれないスパンや、親が nginx のサーバースパンでないスパン
+// （loadgen のクライアントスパンなど）は対象外とする
+func Check(r io.Reader) (Result, error) {
+	spans, err := readSpans(r)
+	if err != nil {
+		return Result{}, err
+	}
+
+	byID := make(map[string]span, len(spans))
+	for _, s := range spans {
+		byID[s.TraceID+"/"+s.SpanID] = s
+	}
+
+	var result Result
+	mismatches := make(map[string]*Mismatch)
+	for _, s := range spans {
+		route := s.attr("http.route")
+		if route == "" || s.ParentSpanID == "" {
+			continue
+		}
+		parent, ok := byID[s.TraceID+"/"+s.ParentSpanID]
+		if !ok || parent.service != NginxServiceName || parent.Kind != spanKindServer {
+			continue
+		}
+		result.Checked++
+
+		method := s.attr("http.request.method")
+		if method == "" {
+			method = s.attr("http.method")
+		}
+		want := strings.TrimSpace(method + " " + route)
+		if parent.Name == want {
+			continue
+		}
+		m, ok := mismatches[want]
+		if !ok {
+			m = &Mismatch{Want: want, Route: route, TraceID: s.TraceID}
+			mismatches[want] = m
+		}
+		m.Count++
+		if !contains(m.NginxSpanNames, parent.Name) {
+			m.NginxSpanNames = append(m.NginxSpanNames, parent.Name)
+		}
+	}
+
+	for _, m := range mismatches {
+		sort.Strings(m.NginxSpanNames)
+		result.Mismatches = append(result.Mismatches, *m)
+	}
+	sort.Slice(result.Mismatches, func(i, j int) bool {
+		return result.Mismatches[i].Want < result.Mismatches[j].Want
+	})
+	return result, nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// OTLP/JSON のうち検証に必要な項目のみ
+type exportRequest struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []keyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []span `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type span struct {
+	TraceID      string     `json:"traceId"`
+	SpanID       string     `json:"spanId"`
+	ParentSpanID string     `json:"parentSpanId"`
+	Name         string     `json:"name"`
+	Kind         int        `json:"kind"`
+	Attributes   []keyValue `json:"attributes"`
+
+	// service はスパンを含むリソースの service.name
+	service string
+}
+
+type keyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+func (s span) attr(key string) string {
+	return attr(s.Attributes, key)
+}
+
+func attr(attrs []keyValue, key string) string {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value.StringValue
+		}
+	}
+	return ""
+}
+
+// readSpans は r に含まれる 1 つ以上の ExportTraceServiceRequest からスパンを取り出す
+func readSpans(r io.Reader) ([]span, error) {
+	var spans []span
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var req exportRequest
+		if err := dec.Decode(&req); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decode OTLP trace JSON: %w", err)
+		}
+		for _, rs := range req.ResourceSpans {
+			service := attr(rs.Resource.Attributes, "service.name")
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					s.service = service
+					spans = append(spans, s)
+				}
+			}
+		}
+	}
+	return spans, nil
+}
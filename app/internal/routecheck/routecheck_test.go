@@ -0,0 +1,75 @@
+package routecheck
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func check(t *testing.T, path string) Result {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	result, err := Check(f)
+	if err != nil {
+		t.Fatalf("Check(%s) error = %v", path, err)
+	}
+	return result
+}
+
+func TestCheck_Consistent(t *testing.T) {
+	result := check(t, "testdata/consistent.jsonl")
+	if result.Checked != 3 {
+		t.Errorf("Checked = %d, want 3", result.Checked)
+	}
+	if len(result.Mismatches) != 0 {
+		t.Errorf("Mismatches = %+v, want none", result.Mismatches)
+	}
+}
+
+func TestCheck_Mismatched(t *testing.T) {
+	result := check(t, "testdata/mismatched.jsonl")
+	if result.Checked != 6 {
+		t.Errorf("Checked = %d, want 6", result.Checked)
+	}
+	want := []Mismatch{
+		{
+			Want:           "GET /metrics/heap",
+			Route:          "/metrics/heap",
+			NginxSpanNames: []string{"GET /metrics/*"},
+			Count:          1,
+			TraceID:        "5af7651916cd43dd8448eb211c80319c",
+		},
+		{
+			Want:           "GET /users/{id}",
+			Route:          "/users/{id}",
+			NginxSpanNames: []string{"GET /users/42", "GET /users/43", "GET /users/{user_id}"},
+			Count:          3,
+			TraceID:        "6af7651916cd43dd8448eb211c80319c",
+		},
+	}
+	if !reflect.DeepEqual(result.Mismatches, want) {
+		t.Errorf("Mismatches = %+v\nwant %+v", result.Mismatches, want)
+	}
+}
+
+func TestCheck_NonNginxParent(t *testing.T) {
+	// loadgen のクライアントスパンを親に持つアプリケーションのスパンは比較しない
+	result := check(t, "testdata/client_parent.jsonl")
+	if result.Checked != 1 {
+		t.Errorf("Checked = %d, want 1", result.Checked)
+	}
+	if len(result.Mismatches) != 0 {
+		t.Errorf("Mismatches = %+v, want none", result.Mismatches)
+	}
+}
+
+func TestCheck_InvalidJSON(t *testing.T) {
+	if _, err := Check(strings.NewReader(`{"resourceSpans": [`)); err == nil {
+		t.Error("Check() error = nil, want error")
+	}
+}
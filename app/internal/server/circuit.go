@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// サーキットブレーカーの状態
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// 外部 API のサーキットブレーカーの設定
+const (
+	// upstreamSlowThreshold 以上かかった呼び出しを失敗とみなす
+	upstreamSlowThreshold = 5 * time.Second
+	// upstreamFailureThreshold 回続けて失敗すると開く
+	upstreamFailureThreshold = 3
+	// upstreamCooldown の間は開いたままにする
+	upstreamCooldown = 30 * time.Second
+)
+
+// circuitBreaker は外部 API 呼び出しの連続した失敗を数え、threshold に達すると
+// cooldown の間は開いた状態になる。呼び出しは止めず、状態を観測するためだけに使う。
+// cooldown 後（half-open）は次に記録された結果で閉じるか再び開く
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// State は現在の状態を返す
+func (cb *circuitBreaker) State() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state()
+}
+
+func (cb *circuitBreaker) state() string {
+	switch {
+	case cb.failures < cb.threshold:
+		return circuitClosed
+	case cb.now().Sub(cb.openedAt) < cb.cooldown:
+		return circuitOpen
+	default:
+		return circuitHalfOpen
+	}
+}
+
+// Record は呼び出しの結果を記録する
+func (cb *circuitBreaker) Record(success bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if success {
+		cb.failures = 0
+		return
+	}
+	cb.failures++
+	if cb.failures >= cb.threshold {
+		// half-open での失敗も含め、開いた時刻を更新する
+		cb.openedAt = cb.now()
+	}
+}
+
+// Check は開いている場合にエラーを返す。プローブの詳細として報告する
+func (cb *circuitBreaker) Check(context.Context) error {
+	if state := cb.State(); state == circuitOpen {
+		return fmt.Errorf("upstream API circuit is %s", state)
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// go test ./internal/server -run TestTelemetryContract -update でゴールデンファイルを更新する
+var update = flag.Bool("update", false, "update golden files")
+
+const contractGolden = "testdata/telemetry_contract.golden.json"
+
+// contractRequests はテレメトリ契約テストで実行するリクエスト。
+// ルートを追加した場合はここにも追加すること（追加漏れはテストで検出する）
+var contractRequests = []struct {
+	method string
+	path   string
+	route  string
+}{
+	{http.MethodGet, "/healthz", "/healthz"},
+	// プローブは既定でトレースしないため、スパンは記録されない
+	{http.MethodGet, "/livez", "/livez"},
+	{http.MethodGet, "/readyz", "/readyz"},
+	{http.MethodGet, "/startupz", "/startupz"},
+	{http.MethodGet, "/", "/"},
+	{http.MethodGet, "/hello?name=otel", "/hello"},
+	{http.MethodGet, "/users/42", "/users/{id}"},
+	{http.MethodGet, "/error", "/error"},
+	{http.MethodPost, "/items/add", "/items/add"},
+	{http.MethodPost, "/items/remove", "/items/remove"},
+	{http.MethodGet, "/cpu/fanspeed", "/cpu/fanspeed"},
+	{http.MethodGet, "/external-api", "/external-api"},
+	{http.MethodGet, "/metrics/memory", "/metrics/memory"},
+	{http.MethodGet, "/metrics/connections", "/metrics/connections"},
+	{http.MethodPost, "/connection/open", "/connection/open"},
+	{http.MethodPost, "/connection/close", "/connection/close"},
+	{http.MethodGet, "/metrics/heap", "/metrics/heap"},
+	{http.MethodPost, "/memory/allocate", "/memory/allocate"},
+	{http.MethodPost, "/memory/free", "/memory/free"},
+}
+
+// contract はダッシュボードやアラートが依存するテレメトリの形。
+// 乱数や時刻に依存する値は含めず、名前・種類・属性キーと型のみを記録する
+type contract struct {
+	Traces  []traceContract  `json:"traces"`
+	Metrics []metricContract `json:"metrics"`
+	Logs    []logContract    `json:"logs"`
+}
+
+type traceContract struct {
+	Request string         `json:"request"`
+	Spans   []spanContract `json:"spans"`
+}
+
+type spanContract struct {
+	Name       string            `json:"name"`
+	Kind       string            `json:"kind"`
+	Parent     string            `json:"parent,omitempty"`
+	Status     string            `json:"status"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Events     []eventContract   `json:"events,omitempty"`
+}
+
+type eventContract struct {
+	Name       string            `json:"name"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+type metricContract struct {
+	Scope       string   `json:"scope"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Unit        string   `json:"unit"`
+	Type        string   `json:"type"`
+	Attributes  []string `json:"attributes,omitempty"`
+}
+
+type logContract struct {
+	Body     string `json:"body"`
+	Severity string `json:"severity"`
+	// TraceContext はレコードにトレース ID とスパン ID が付与されているか
+	TraceContext bool              `json:"trace_context"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+func TestTelemetryContract(t *testing.T) {
+	s, tel := newTestServer(t, Options{})
+	routes := s.Routes()
+
+	checkContractCoversRoutes(t, routes.(chi.Routes))
+
+	var got contract
+	for _, req := range contractRequests {
+		before := len(tel.spans.Ended())
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, httptest.NewRequest(req.method, req.path, nil))
+		got.Traces = append(got.Traces, traceContract{
+			Request: req.method + " " + req.route,
+			Spans:   spanContracts(tel.spans.Ended()[before:]),
+		})
+	}
+	got.Metrics = metricContracts(t, tel)
+	got.Logs = logContracts(tel)
+
+	data, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, '\n')
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(contractGolden), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(contractGolden, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(contractGolden)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("telemetry contract changed; if this is intended, run `go test ./internal/server -run TestTelemetryContract -update`\n%s",
+			diffLines(string(want), string(data)))
+	}
+}
+
+// checkContractCoversRoutes は登録済みの全ルートが contractRequests に含まれることを確認する
+func checkContractCoversRoutes(t *testing.T, routes chi.Routes) {
+	t.Helper()
+	covered := make(map[string]bool)
+	for _, req := range contractRequests {
+		covered[req.method+" "+req.route] = true
+	}
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if !covered[method+" "+route] {
+			return fmt.Errorf("route %s %s is not covered by contractRequests", method, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func spanContracts(spans []sdktrace.ReadOnlySpan) []spanContract {
+	names := make(map[string]string)
+	for _, s := range spans {
+		names[s.SpanContext().SpanID().String()] = s.Name()
+	}
+
+	out := make([]spanContract, 0, len(spans))
+	for _, s := range spans {
+		sc := spanContract{
+			Name:       s.Name(),
+			Kind:       s.SpanKind().String(),
+			Parent:     names[s.Parent().SpanID().String()],
+			Status:     s.Status().Code.String(),
+			Attributes: attributeTypes(s.Attributes()),
+		}
+		for _, e := range s.Events() {
+			sc.Events = append(sc.Events, eventContract{Name: e.Name, Attributes: attributeTypes(e.Attributes)})
+		}
+		out = append(out, sc)
+	}
+	// 終了順はネストの深いスパンが先になるため、親から順に並べ替える
+	sort.SliceStable(out, func(i, j int) bool { return depth(out, out[i]) < depth(out, out[j]) })
+	return out
+}
+
+func depth(spans []spanContract, s spanContract) int {
+	d := 0
+	for s.Parent != "" {
+		d++
+		found := false
+		for _, p := range spans {
+			if p.Name == s.Parent {
+				s, found = p, true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return d
+}
+
+func metricContracts(t *testing.T, tel *telemetry) []metricContract {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := tel.reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+
+	var out []metricContract
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			typ, sets := describeData(m.Data)
+			keys := make(map[string]bool)
+			for _, set := range sets {
+				for _, kv := range set.ToSlice() {
+					keys[string(kv.Key)] = true
+				}
+			}
+			out = append(out, metricContract{
+				Scope:       sm.Scope.Name,
+				Name:        m.Name,
+				Description: m.Description,
+				Unit:        m.Unit,
+				Type:        typ,
+				Attributes:  sortedKeys(keys),
+			})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func describeData(data metricdata.Aggregation) (string, []attribute.Set) {
+	var sets []attribute.Set
+	switch d := data.(type) {
+	case metricdata.Sum[int64]:
+		for _, dp := range d.DataPoints {
+			sets = append(sets, dp.Attributes)
+		}
+		return fmt.Sprintf("Sum[int64] monotonic=%t", d.IsMonotonic), sets
+	case metricdata.Sum[float64]:
+		for _, dp := range d.DataPoints {
+			sets = append(sets, dp.Attributes)
+		}
+		return fmt.Sprintf("Sum[float64] monotonic=%t", d.IsMonotonic), sets
+	case metricdata.Gauge[int64]:
+		for _, dp := range d.DataPoints {
+			sets = append(sets, dp.Attributes)
+		}
+		return "Gauge[int64]", sets
+	case metricdata.Gauge[float64]:
+		for _, dp := range d.DataPoints {
+			sets = append(sets, dp.Attributes)
+		}
+		return "Gauge[float64]", sets
+	case metricdata.Histogram[int64]:
+		for _, dp := range d.DataPoints {
+			sets = append(sets, dp.Attributes)
+		}
+		return "Histogram[int64]", sets
+	case metricdata.Histogram[float64]:
+		for _, dp := range d.DataPoints {
+			sets = append(sets, dp.Attributes)
+		}
+		return "Histogram[float64]", sets
+	default:
+		return fmt.Sprintf("%T", data), nil
+	}
+}
+
+func logContracts(tel *telemetry) []logContract {
+	var out []logContract
+	for _, r := range tel.logs.Records() {
+		lc := logContract{
+			Body:         r.Body().AsString(),
+			Severity:     r.Severity().String(),
+			TraceContext: r.TraceID().IsValid() && r.SpanID().IsValid(),
+		}
+		r.WalkAttributes(func(kv log.KeyValue) bool {
+			if lc.Attributes == nil {
+				lc.Attributes = make(map[string]string)
+			}
+			lc.Attributes[kv.Key] = kv.Value.Kind().String()
+			return true
+		})
+		out = append(out, lc)
+	}
+	return out
+}
+
+func attributeTypes(attrs []attribute.KeyValue) map[string]string {
+	if len(attrs) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(attrs))
+	for _, kv := range attrs {
+		m[string(kv.Key)] = kv.Value.Type().String()
+	}
+	return m
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffLines は want と got の差分行を簡易的に表示する
+func diffLines(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	inWant := make(map[string]int)
+	for _, l := range wantLines {
+		inWant[l]++
+	}
+	inGot := make(map[string]int)
+	for _, l := range gotLines {
+		inGot[l]++
+	}
+
+	var b strings.Builder
+	for _, l := range wantLines {
+		if inGot[l] == 0 {
+			fmt.Fprintf(&b, "- %s\n", l)
+		}
+	}
+	for _, l := range gotLines {
+		if inWant[l] == 0 {
+			fmt.Fprintf(&b, "+ %s\n", l)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,314 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/response"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func (s *Server) getHealtz(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, r, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) getRoot(w http.ResponseWriter, r *http.Request) {
+	response.Text(w, r, http.StatusOK, "Welcome to the chi HTTP server behind Nginx!\n")
+}
+
+func (s *Server) getHello(w http.ResponseWriter, r *http.Request) {
+	// span の作成。作成次に属性を設定可能
+	ctx, span := s.tracer.Start(r.Context(), "getHello", trace.WithAttributes(attribute.String("hello", "world")))
+	// さらに属性を追加することも可能
+	span.SetAttributes(attribute.Bool("isTrue", true), attribute.String("stringAttr", "hi!"))
+	// 属性のキーは、事前に定義されたものも利用できる
+	myKey := attribute.Key("myCoolAttribute")
+	span.SetAttributes(myKey.String("a value"))
+	defer span.End()
+
+	// AddEvent により特定のタイミングで、Event を追加可能。mutex で排他処理をしているときや、特定の分岐に入る時などに利用できそう
+	span.AddEvent("Hello with AddEvent")
+
+	// メトリクスをカウント
+	s.instruments.requestCounter.Add(r.Context(), 1, metric.WithAttributes(
+		attribute.String("endpoint", "/hello"),
+		attribute.String("method", r.Method),
+	))
+	s.logger.InfoContext(ctx, "Incremented request counter for /hello endpoint")
+
+	s.childHello(ctx)
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = "World"
+	}
+	response.JSON(w, r, http.StatusOK, map[string]string{"message": fmt.Sprintf("Hello, %s!", name)})
+}
+
+func (s *Server) childHello(ctx context.Context) {
+	ctx, childSpan := s.tracer.Start(ctx, "childHello")
+	defer childSpan.End()
+
+	childSpan.AddEvent("Hello with AddEvent from child", trace.WithAttributes(attribute.String("childEvent", "hello child")))
+	s.logger.InfoContext(ctx, "This is a child function")
+}
+
+func (s *Server) getUserByID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	response.JSON(w, r, http.StatusOK, map[string]any{"id": id, "profile": map[string]any{"nickname": "guest", "created_at": time.Now().UTC()}})
+}
+
+func (s *Server) getError(w http.ResponseWriter, r *http.Request) {
+	// span の作成。作成次に属性を設定可能
+	_, span := s.tracer.Start(r.Context(), "getError")
+	defer span.End()
+
+	// ステータスにエラーを設定。設定すると、このスパンだけでなく、トレース全体がエラーとして扱われる
+	span.SetStatus(codes.Error, "Internal Server Error")
+	// Event にエラー情報を追加する。このメソッドだけでは、トレース全体のステータスは変わらない（厳密には、直前のスパンまでエラーステータスになる）ため、span.SetStatus と合わせて使う
+	span.RecordError(fmt.Errorf("err: Internal Server Error"))
+
+	// レスポンスは problem+json で返す。サーバースパンのステータスと error.type も設定される
+	response.WriteProblem(w, r, response.NewProblem(http.StatusInternalServerError,
+		"This is a sample 500 error endpoint for testing OpenTelemetry"))
+}
+
+func (s *Server) addItem(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.tracer.Start(r.Context(), "addItem")
+	defer span.End()
+
+	// itemsCounterをインクリメント
+	s.instruments.itemsCounter.Add(ctx, 1)
+	s.logger.InfoContext(ctx, "Incremented items counter")
+
+	response.JSON(w, r, http.StatusOK, map[string]string{
+		"message": "Item added successfully",
+		"action":  "increment",
+	})
+}
+
+func (s *Server) removeItem(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.tracer.Start(r.Context(), "removeItem")
+	defer span.End()
+
+	// itemsCounterをデクリメント
+	s.instruments.itemsCounter.Add(ctx, -1)
+	s.logger.InfoContext(ctx, "Decremented items counter")
+
+	response.JSON(w, r, http.StatusOK, map[string]string{
+		"message": "Item removed successfully",
+		"action":  "decrement",
+	})
+}
+
+func (s *Server) getCPUFanSpeed(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.tracer.Start(r.Context(), "getCPUFanSpeed")
+	defer span.End()
+
+	// fanSpeedチャンネルからシミュレーターの最新の値を非ブロッキングで取得
+	var fanSpeed int64
+	select {
+	case fanSpeed = <-s.fanSpeed:
+		// Gaugeメトリクスを記録
+		s.instruments.speedGauge.Record(ctx, fanSpeed)
+		s.logger.InfoContext(ctx, "Recorded fan speed", "speed_rpm", fanSpeed)
+	default:
+		// チャンネルに値がない場合はランダムな値を生成
+		fanSpeed = getCPUFanSpeed()
+		s.instruments.speedGauge.Record(ctx, fanSpeed)
+	}
+
+	response.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"fanSpeed": fanSpeed,
+		"unit":     "rpm",
+		"message":  "Current CPU fan speed",
+	})
+}
+
+func (s *Server) callExternalAPI(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.tracer.Start(r.Context(), "callExternalAPI")
+	defer span.End()
+
+	// 処理開始時刻を記録
+	startTime := time.Now()
+
+	// 外部APIコールをエミュレート（50ms～10秒のランダムな遅延、より分散させる）
+	// 50% : 50ms - 1秒 (高速レスポンス)
+	// 30% : 1秒 - 5秒 (中程度のレスポンス)
+	// 20% : 5秒 - 10秒 (遅いレスポンス)
+	var apiLatency time.Duration
+	randValue := rand.Float32()
+	if randValue < 0.5 {
+		// 50ms - 1000ms
+		apiLatency = time.Duration(50+rand.Intn(950)) * time.Millisecond
+	} else if randValue < 0.8 {
+		// 1秒 - 5秒
+		apiLatency = time.Duration(1000+rand.Intn(4000)) * time.Millisecond
+	} else {
+		// 5秒 - 10秒
+		apiLatency = time.Duration(5000+rand.Intn(5000)) * time.Millisecond
+	}
+
+	// スパンに属性を追加
+	span.SetAttributes(
+		attribute.String("api.endpoint", "https://api.example.com/data"),
+		attribute.String("api.method", "GET"),
+		attribute.Int64("api.latency_ms", int64(apiLatency.Milliseconds())),
+		attribute.String("api.circuit.state", s.upstream.State()),
+	)
+
+	// 外部APIコールの開始をイベントとして記録
+	span.AddEvent("External API call started", trace.WithAttributes(
+		attribute.String("api.url", "https://api.example.com/data"),
+	))
+
+	// 外部APIコールをエミュレート
+	s.sleep(apiLatency)
+	// upstreamSlowThreshold 以上かかった呼び出しは失敗としてサーキットブレーカーに記録する
+	s.upstream.Record(apiLatency < upstreamSlowThreshold)
+
+	// 外部APIコールの完了をイベントとして記録
+	span.AddEvent("External API call completed", trace.WithAttributes(
+		attribute.Int("api.status_code", 200),
+	))
+
+	// 処理時間を計測
+	duration := time.Since(startTime).Seconds()
+
+	// ヒストグラムメトリクスに記録
+	s.instruments.histogram.Record(ctx, duration, metric.WithAttributes(
+		attribute.String("api.endpoint", "external_api"),
+		attribute.Int("api.status_code", 200),
+	))
+	s.logger.InfoContext(ctx, "Recorded API call duration", "duration_seconds", duration)
+
+	// レスポンスを返す
+	response.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"message":     "External API call completed successfully",
+		"duration_ms": apiLatency.Milliseconds(),
+		"status":      "success",
+	})
+}
+
+func (s *Server) getMemoryMetrics(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "getMemoryMetrics")
+	defer span.End()
+
+	// 現在のメモリ使用量を返す（ObservableCounterによって自動的に収集されている値）
+	response.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"current_memory_bytes": s.memory.Load(),
+		"unit":                 "bytes",
+		"message":              "Current memory usage tracked by Observable Counter",
+	})
+}
+
+func (s *Server) getConnectionMetrics(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "getConnectionMetrics")
+	defer span.End()
+
+	// 現在のアクティブコネクション数を返す
+	connections := s.connections.Load()
+
+	response.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"active_connections": connections,
+		"unit":               "connections",
+		"message":            "Active connections tracked by Observable UpDownCounter",
+	})
+}
+
+func (s *Server) simulateConnect(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.tracer.Start(r.Context(), "simulateConnect")
+	defer span.End()
+
+	// コネクションを増やす
+	connections := s.connections.Add(1, 0, math.MaxInt64)
+
+	s.logger.InfoContext(ctx, "Connection opened", "total_connections", connections)
+
+	response.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"action":             "connect",
+		"active_connections": connections,
+		"message":            "Connection opened",
+	})
+}
+
+func (s *Server) simulateDisconnect(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.tracer.Start(r.Context(), "simulateDisconnect")
+	defer span.End()
+
+	// コネクションを減らす
+	connections := s.connections.Add(-1, 0, math.MaxInt64)
+
+	s.logger.InfoContext(ctx, "Connection closed", "total_connections", connections)
+
+	response.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"action":             "disconnect",
+		"active_connections": connections,
+		"message":            "Connection closed",
+	})
+}
+
+func (s *Server) getHeapMetrics(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "getHeapMetrics")
+	defer span.End()
+
+	// 現在のヒープメモリ使用量を返す
+	heapUsage := s.heap.Load()
+
+	response.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"heap_bytes": heapUsage,
+		"heap_mb":    float64(heapUsage) / (1024 * 1024),
+		"unit":       "bytes",
+		"message":    "Heap memory usage tracked by Observable Gauge",
+	})
+}
+
+func (s *Server) allocateMemory(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.tracer.Start(r.Context(), "allocateMemory")
+	defer span.End()
+
+	// メモリを割り当てる（10MB〜50MBのランダムな量）
+	allocation := int64(10*1024*1024) + int64(rand.Intn(40*1024*1024))
+
+	heapUsage := s.heap.Add(allocation, minHeapUsage, maxHeapUsage)
+
+	s.logger.InfoContext(ctx, "Memory allocated",
+		"allocated_mb", float64(allocation)/(1024*1024),
+		"total_heap_mb", float64(heapUsage)/(1024*1024))
+
+	response.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"action":        "allocate",
+		"allocated_mb":  float64(allocation) / (1024 * 1024),
+		"total_heap_mb": float64(heapUsage) / (1024 * 1024),
+		"message":       "Memory allocated",
+	})
+}
+
+func (s *Server) freeMemory(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.tracer.Start(r.Context(), "freeMemory")
+	defer span.End()
+
+	// メモリを解放する（10MB〜50MBのランダムな量）
+	deallocation := int64(10*1024*1024) + int64(rand.Intn(40*1024*1024))
+
+	heapUsage := s.heap.Add(-deallocation, minHeapUsage, maxHeapUsage)
+
+	s.logger.InfoContext(ctx, "Memory freed",
+		"freed_mb", float64(deallocation)/(1024*1024),
+		"total_heap_mb", float64(heapUsage)/(1024*1024))
+
+	response.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"action":        "free",
+		"freed_mb":      float64(deallocation) / (1024 * 1024),
+		"total_heap_mb": float64(heapUsage) / (1024 * 1024),
+		"message":       "Memory freed",
+	})
+}
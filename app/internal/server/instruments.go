@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// instruments はハンドラーとバックグラウンド処理が利用するメトリクスの計装
+type instruments struct {
+	requestCounter       metric.Int64Counter
+	itemsCounter         metric.Int64UpDownCounter
+	speedGauge           metric.Int64Gauge
+	histogram            metric.Float64Histogram
+	memoryObservable     metric.Float64ObservableCounter
+	connectionObservable metric.Int64ObservableUpDownCounter
+	heapObservable       metric.Int64ObservableGauge
+
+	recoverer func(http.Handler) http.Handler
+}
+
+func newInstruments(s *Server) (*instruments, error) {
+	var (
+		in  instruments
+		err error
+	)
+
+	in.requestCounter, err = s.meter.Int64Counter(
+		"api.counter",
+		metric.WithDescription("Number of API calls"),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request counter: %w", err)
+	}
+
+	in.itemsCounter, err = s.meter.Int64UpDownCounter(
+		"items.counter",
+		metric.WithDescription("Number of items."),
+		metric.WithUnit("{item}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create items counter: %w", err)
+	}
+
+	in.speedGauge, err = s.meter.Int64Gauge(
+		"cpu.fan.speed",
+		metric.WithDescription("CPU Fan Speed"),
+		metric.WithUnit("{rpm}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create speed gauge: %w", err)
+	}
+
+	in.histogram, err = s.meter.Float64Histogram(
+		"task.duration",
+		metric.WithDescription("The duration of task execution."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task duration histogram: %w", err)
+	}
+
+	// Float64ObservableCounterを作成
+	in.memoryObservable, err = s.meter.Float64ObservableCounter(
+		"memory.usage",
+		metric.WithDescription("Current memory usage in bytes"),
+		metric.WithUnit("By"),
+		metric.WithFloat64Callback(func(ctx context.Context, o metric.Float64Observer) error {
+			// デモンストレーション目的でランダムな値を生成
+			// 実際のアプリケーションでは、実際のメモリ使用量を取得するように置き換えてください
+			// 100MB〜500MBの間でランダムに増加する値をシミュレート
+			memoryUsage := float64(100*1024*1024) + float64(rand.Intn(400*1024*1024))
+			s.memory.Store(memoryUsage)
+			o.Observe(memoryUsage, metric.WithAttributes(
+				attribute.String("memory.type", "heap"),
+			))
+			s.metricsLogger.DebugContext(ctx, "Observable Counter reported memory usage",
+				"memory_mb", memoryUsage/(1024*1024))
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create memory observable counter: %w", err)
+	}
+
+	// Int64ObservableUpDownCounterを作成
+	in.connectionObservable, err = s.meter.Int64ObservableUpDownCounter(
+		"active.connections",
+		metric.WithDescription("Number of active connections"),
+		metric.WithUnit("{connection}"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			connections := s.connections.Load()
+			o.Observe(connections, metric.WithAttributes(
+				attribute.String("connection.type", "http"),
+			))
+			s.metricsLogger.DebugContext(ctx, "Observable UpDownCounter reported active connections",
+				"active_connections", connections)
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection observable updown counter: %w", err)
+	}
+
+	// Int64ObservableGaugeを作成
+	in.heapObservable, err = s.meter.Int64ObservableGauge(
+		"memory.heap",
+		metric.WithDescription("Heap memory usage in bytes"),
+		metric.WithUnit("By"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			heapUsage := s.heap.Load()
+			o.Observe(heapUsage, metric.WithAttributes(
+				attribute.String("memory.state", "used"),
+			))
+			s.metricsLogger.DebugContext(ctx, "Observable Gauge reported heap usage",
+				"heap_mb", float64(heapUsage)/(1024*1024))
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create heap observable gauge: %w", err)
+	}
+
+	in.recoverer, err = middleware.Recoverer(s.meter, s.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &in, nil
+}
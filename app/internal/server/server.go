@@ -0,0 +1,308 @@
+// Package server はデモアプリケーションの HTTP ハンドラーと、
+// それらが利用するトレーサー・メーター・計装・状態をまとめた Server を提供する。
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/fault"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/health"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/logging"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/middleware"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/pipeline"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/receiver"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/response"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/simulator"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/spool"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/telemetryconfig"
+	"github.com/go-chi/chi/v5"
+	"github.com/riandyrn/otelchi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName は計装スコープ名。メーターのビューもこの名前でマッチさせている
+const ScopeName = "go-app"
+
+// Options は Server の依存関係。未指定の項目はグローバルな実装を利用する
+type Options struct {
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
+	Propagator     propagation.TextMapPropagator
+	Logger         *slog.Logger
+	// LoggerProvider はアクセスログの出力先
+	LoggerProvider log.LoggerProvider
+	// LogLevels を指定すると、ログレベルを変更する管理 API を公開する
+	LogLevels *logging.Levels
+	// TelemetryStore を指定すると、組み込みの OTLP レシーバーが受信したデータの問い合わせ API（/telemetry）と
+	// トレースビューアー（/traces）を管理用ポートで公開する
+	TelemetryStore *receiver.Store
+
+	// Faults は故障注入ミドルウェアの初期設定
+	Faults fault.Config
+
+	// TrustedProxies は X-Forwarded-For などのヘッダーを信頼するプロキシ（nginx）のアドレス範囲。
+	// 空の場合はヘッダーを無視し、接続元のアドレスをクライアントとする
+	TrustedProxies []netip.Prefix
+
+	// AccessLog はアクセスログのサンプリング率と除外パス。LoggerProvider は Options.LoggerProvider で上書きされる
+	AccessLog middleware.AccessLogOptions
+
+	// Pipeline を指定すると、テレメトリのパイプラインの状態を管理用ポートの /debug/telemetry で公開する
+	Pipeline *pipeline.Monitor
+	// Spool を指定すると、エクスポートのスプールの状態を管理用ポートの /debug/spool で公開する
+	Spool *spool.Spooler
+	// Config を指定すると、テレメトリの設定と再読み込みの API を管理用ポートの /config で公開する
+	Config *telemetryconfig.Reloader
+
+	// Health は /livez, /readyz, /startupz で公開するプローブ。サーバーは外部 API のサーキットと
+	// ストアのチェッカーを readiness に登録する。未指定の場合は起動済みの Health を作成する
+	Health *health.Health
+	// ProbeTracing を true にすると、プローブのリクエストもトレースする
+	ProbeTracing bool
+
+	// TraceResponse はトレース ID を返すレスポンスヘッダーと、エラーボディに trace_id を含めない除外パス
+	TraceResponse middleware.TraceResponseOptions
+}
+
+// Server はハンドラーが利用する依存関係と状態を保持する
+type Server struct {
+	tracerProvider trace.TracerProvider
+	propagator     propagation.TextMapPropagator
+	tracer         trace.Tracer
+	meter          metric.Meter
+	logger         *slog.Logger
+	metricsLogger  *slog.Logger
+	simLogger      *slog.Logger
+	logLevels      *logging.Levels
+	telemetryStore *receiver.Store
+	pipeline       *pipeline.Monitor
+	spool          *spool.Spooler
+	config         *telemetryconfig.Reloader
+	instruments    *instruments
+	faults         *fault.Injector
+	trustedProxies func(http.Handler) http.Handler
+	accessLog      func(http.Handler) http.Handler
+	traceResponse  func(http.Handler) http.Handler
+	health         *health.Health
+	probeTracing   bool
+	upstream       *circuitBreaker
+
+	memory      *floatStore
+	connections *intStore
+	heap        *intStore
+	fanSpeed    chan int64
+	// fanSpeedValue はファン速度のシミュレーターが最後に設定した値
+	fanSpeedValue *intStore
+	simulations   *simulator.Manager
+
+	// 外部 API 呼び出しの遅延をエミュレートする関数。テストで差し替えられるようにしている
+	sleep func(time.Duration)
+}
+
+// NewServer は opts から Server を作成し、メトリクスの計装を登録する
+func NewServer(opts Options) (*Server, error) {
+	if opts.TracerProvider == nil {
+		opts.TracerProvider = otel.GetTracerProvider()
+	}
+	if opts.MeterProvider == nil {
+		opts.MeterProvider = otel.GetMeterProvider()
+	}
+	if opts.Propagator == nil {
+		opts.Propagator = otel.GetTextMapPropagator()
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.LoggerProvider == nil {
+		opts.LoggerProvider = global.GetLoggerProvider()
+	}
+	opts.AccessLog.LoggerProvider = opts.LoggerProvider
+
+	injector, err := fault.NewInjector(opts.Faults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fault injector: %w", err)
+	}
+
+	if opts.Health == nil {
+		opts.Health = health.New(health.Options{})
+		opts.Health.MarkStarted()
+	}
+
+	traceResponse, err := middleware.TraceResponse(opts.TraceResponse)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace response middleware: %w", err)
+	}
+
+	s := &Server{
+		tracerProvider: opts.TracerProvider,
+		propagator:     opts.Propagator,
+		tracer:         opts.TracerProvider.Tracer(ScopeName),
+		meter:          opts.MeterProvider.Meter(ScopeName),
+		logger:         opts.Logger,
+		metricsLogger:  opts.Logger.With(logging.ComponentKey, "metrics"),
+		simLogger:      opts.Logger.With(logging.ComponentKey, "simulator"),
+		logLevels:      opts.LogLevels,
+		telemetryStore: opts.TelemetryStore,
+		pipeline:       opts.Pipeline,
+		spool:          opts.Spool,
+		config:         opts.Config,
+		faults:         injector,
+		trustedProxies: middleware.TrustedProxies(opts.TrustedProxies),
+		accessLog:      middleware.AccessLog(opts.AccessLog),
+		traceResponse:  traceResponse,
+		health:         opts.Health,
+		probeTracing:   opts.ProbeTracing,
+		upstream:       newCircuitBreaker(upstreamFailureThreshold, upstreamCooldown),
+		memory:         &floatStore{},
+		connections:    &intStore{},
+		heap:           &intStore{value: initialHeapUsage()},
+		fanSpeed:       make(chan int64, 1),
+		fanSpeedValue:  &intStore{value: 2000},
+		sleep:          time.Sleep,
+	}
+
+	s.instruments, err = newInstruments(s)
+	if err != nil {
+		return nil, err
+	}
+	s.simulations, err = s.newSimulations()
+	if err != nil {
+		return nil, err
+	}
+
+	// 外部 API のサーキットが開いていてもリクエストは受け付けるため、状態は詳細として報告するだけにする
+	s.health.AddInfo("upstream-api", s.upstream)
+	s.health.AddReadiness("store", health.CheckerFunc(s.checkStores))
+	return s, nil
+}
+
+// Routes はアプリケーションの chi ルーターを返す
+func (s *Server) Routes() http.Handler {
+	r := chi.NewRouter()
+
+	// WithChiRoutes を指定すると、ハンドラー実行前にルートパターンからスパン名を決められる
+	r.Use(otelchi.Middleware(ScopeName,
+		otelchi.WithChiRoutes(r),
+		otelchi.WithTracerProvider(s.tracerProvider),
+		otelchi.WithPropagators(s.propagator),
+		otelchi.WithFilter(s.traceFilter),
+	))
+
+	// レスポンス出力の失敗をサーバーのロガーに出力する
+	r.Use(response.Middleware(s.logger))
+
+	// nginx が付与したヘッダーからクライアントのアドレスとスキームを解決し、サーバースパンに設定する
+	r.Use(s.trustedProxies)
+
+	// レスポンスヘッダーとエラーボディでトレース ID を返す。recoverer が返す 500 にも含めるため、その前に登録する
+	r.Use(s.traceResponse)
+
+	// リクエストごとのアクセスログ。サーバースパンと相関付けるため otelchi の後、
+	// recoverer が返した 500 を記録するためその前に登録する
+	r.Use(s.accessLog)
+
+	// panic をスパンに記録して 500 を返す。スパンを参照するため otelchi の後に登録する
+	r.Use(s.instruments.recoverer)
+
+	// 故障注入ミドルウェア。otelchi のスパンにイベントを記録するため、その後に登録する
+	r.Use(s.faults.Middleware)
+
+	// ルーティングできなかった場合も problem+json で返す
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		response.WriteProblem(w, r, response.NewProblem(http.StatusNotFound, ""))
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		response.WriteProblem(w, r, response.NewProblem(http.StatusMethodNotAllowed, ""))
+	})
+
+	// Define routes
+	r.Get("/healthz", s.getHealtz)
+	r.Get(health.LivePath, s.health.Livez)
+	r.Get(health.ReadyPath, s.health.Readyz)
+	r.Get(health.StartupPath, s.health.Startupz)
+	r.Get("/", s.getRoot)
+	r.Get("/hello", s.getHello)
+	r.Get("/users/{id}", s.getUserByID)
+	r.Get("/error", s.getError)
+	r.Post("/items/add", s.addItem)
+	r.Post("/items/remove", s.removeItem)
+	r.Get("/cpu/fanspeed", s.getCPUFanSpeed)
+	r.Get("/external-api", s.callExternalAPI)
+	r.Get("/metrics/memory", s.getMemoryMetrics)
+	r.Get("/metrics/connections", s.getConnectionMetrics)
+	r.Post("/connection/open", s.simulateConnect)
+	r.Post("/connection/close", s.simulateDisconnect)
+	r.Get("/metrics/heap", s.getHeapMetrics)
+	r.Post("/memory/allocate", s.allocateMemory)
+	r.Post("/memory/free", s.freeMemory)
+
+	return r
+}
+
+// Health はプローブの状態を返す。起動の完了とシャットダウンの開始を記録するために使う
+func (s *Server) Health() *health.Health {
+	return s.health
+}
+
+// traceFilter は ProbeTracing が false の場合にプローブのリクエストをトレースしない
+func (s *Server) traceFilter(r *http.Request) bool {
+	if s.probeTracing {
+		return true
+	}
+	for _, path := range health.Paths() {
+		if r.URL.Path == path {
+			return false
+		}
+	}
+	return true
+}
+
+// checkStores はハンドラーが使うストアのロックを取得できるかを確認する。
+// ロックが解放されない場合は Health のタイムアウトで失敗する
+func (s *Server) checkStores(context.Context) error {
+	s.memory.Load()
+	s.connections.Load()
+	s.heap.Load()
+	return nil
+}
+
+// AdminRoutes は管理用ポートで公開するルーターを返す
+func (s *Server) AdminRoutes() http.Handler {
+	r := chi.NewRouter()
+	r.Use(response.Middleware(s.logger))
+	r.Mount("/faults", s.faults.AdminRoutes())
+	r.Mount("/simulators", s.simulations.AdminRoutes())
+	if s.logLevels != nil {
+		r.Mount("/log-level", s.logLevels.AdminRoutes())
+	}
+	if s.telemetryStore != nil {
+		r.Mount("/telemetry", s.telemetryStore.Routes())
+		r.Mount("/traces", s.telemetryStore.UI("/traces"))
+	}
+	if s.pipeline != nil {
+		r.Mount("/debug/telemetry", s.pipeline.AdminRoutes())
+	}
+	if s.spool != nil {
+		r.Mount("/debug/spool", s.spool.AdminRoutes())
+	}
+	if s.config != nil {
+		r.Mount("/config", s.config.AdminRoutes())
+	}
+	return r
+}
+
+// StartSimulations はデモ用の値を変動させるシミュレーターを開始する。ctx のキャンセルで停止する。
+// 管理用ポートの /simulators で停止・一時停止やパラメーターの変更ができる
+func (s *Server) StartSimulations(ctx context.Context) error {
+	return s.simulations.Start(ctx)
+}
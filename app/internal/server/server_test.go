@@ -0,0 +1,786 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/fault"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/health"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/middleware"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/simulator"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/telemetryconfig"
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// logExporter はエクスポートされたログレコードをメモリに保持する sdklog.Exporter
+type logExporter struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (e *logExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range records {
+		e.records = append(e.records, r.Clone())
+	}
+	return nil
+}
+
+func (e *logExporter) Shutdown(context.Context) error   { return nil }
+func (e *logExporter) ForceFlush(context.Context) error { return nil }
+
+func (e *logExporter) Records() []sdklog.Record {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]sdklog.Record(nil), e.records...)
+}
+
+// telemetry はテスト用のインメモリなトレース・メトリクス・ログの出力先
+type telemetry struct {
+	spans  *tracetest.SpanRecorder
+	reader *sdkmetric.ManualReader
+	logs   *logExporter
+}
+
+func newTestServer(t *testing.T, opts Options) (*Server, *telemetry) {
+	t.Helper()
+
+	tel := &telemetry{
+		spans:  tracetest.NewSpanRecorder(),
+		reader: sdkmetric.NewManualReader(),
+		logs:   &logExporter{},
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(tel.spans))
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(tel.reader), sdkmetric.WithView(Views()...))
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(tel.logs)))
+	t.Cleanup(func() {
+		ctx := context.Background()
+		_ = tp.Shutdown(ctx)
+		_ = mp.Shutdown(ctx)
+		_ = lp.Shutdown(ctx)
+	})
+
+	opts.TracerProvider = tp
+	opts.MeterProvider = mp
+	opts.Propagator = propagation.TraceContext{}
+	opts.Logger = otelslog.NewLogger(ScopeName, otelslog.WithLoggerProvider(lp))
+	opts.LoggerProvider = lp
+
+	s, err := NewServer(opts)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	// 外部 API 呼び出しの遅延は待たない
+	s.sleep = func(time.Duration) {}
+	return s, tel
+}
+
+func (tel *telemetry) span(t *testing.T, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, s := range tel.spans.Ended() {
+		if s.Name() == name {
+			return s
+		}
+	}
+	t.Fatalf("span %q not found", name)
+	return nil
+}
+
+func (tel *telemetry) metric(t *testing.T, name string) metricdata.Metrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := tel.reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+	t.Fatalf("metric %q not found", name)
+	return metricdata.Metrics{}
+}
+
+func (tel *telemetry) log(t *testing.T, body string) sdklog.Record {
+	t.Helper()
+	for _, r := range tel.logs.Records() {
+		if r.Body().AsString() == body {
+			return r
+		}
+	}
+	t.Fatalf("log %q not found", body)
+	return sdklog.Record{}
+}
+
+func spanAttr(s sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range s.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func hasEvent(s sdktrace.ReadOnlySpan, name string) bool {
+	for _, e := range s.Events() {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func sumValue(t *testing.T, m metricdata.Metrics) int64 {
+	t.Helper()
+	sum, ok := m.Data.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("metric %q data = %T, want metricdata.Sum[int64]", m.Name, m.Data)
+	}
+	var total int64
+	for _, dp := range sum.DataPoints {
+		total += dp.Value
+	}
+	return total
+}
+
+func TestRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		// wantSpans はサーバースパン以外にハンドラーが作成するスパン
+		wantSpans []string
+		wantLogs  []string
+		check     func(t *testing.T, tel *telemetry, body []byte)
+	}{
+		{
+			name:       "healthz",
+			method:     http.MethodGet,
+			path:       "/healthz",
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, tel *telemetry, body []byte) {
+				if got := strings.TrimSpace(string(body)); got != `{"status":"ok"}` {
+					t.Errorf("body = %s, want {\"status\":\"ok\"}", got)
+				}
+			},
+		},
+		{
+			name:       "root",
+			method:     http.MethodGet,
+			path:       "/",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "hello",
+			method:     http.MethodGet,
+			path:       "/hello?name=otel",
+			wantStatus: http.StatusOK,
+			wantSpans:  []string{"getHello", "childHello"},
+			wantLogs:   []string{"Incremented request counter for /hello endpoint", "This is a child function"},
+			check: func(t *testing.T, tel *telemetry, body []byte) {
+				hello := tel.span(t, "getHello")
+				for key, want := range map[attribute.Key]attribute.Value{
+					"hello":           attribute.StringValue("world"),
+					"isTrue":          attribute.BoolValue(true),
+					"stringAttr":      attribute.StringValue("hi!"),
+					"myCoolAttribute": attribute.StringValue("a value"),
+				} {
+					if got, ok := spanAttr(hello, key); !ok || got != want {
+						t.Errorf("getHello attribute %s = %v, want %v", key, got.Emit(), want.Emit())
+					}
+				}
+				if !hasEvent(hello, "Hello with AddEvent") {
+					t.Error("getHello has no \"Hello with AddEvent\" event")
+				}
+
+				child := tel.span(t, "childHello")
+				if child.Parent().SpanID() != hello.SpanContext().SpanID() {
+					t.Error("childHello is not a child of getHello")
+				}
+				if !hasEvent(child, "Hello with AddEvent from child") {
+					t.Error("childHello has no \"Hello with AddEvent from child\" event")
+				}
+
+				if got := sumValue(t, tel.metric(t, "api.counter")); got != 1 {
+					t.Errorf("api.counter = %d, want 1", got)
+				}
+				var resp map[string]string
+				if err := json.Unmarshal(body, &resp); err != nil || resp["message"] != "Hello, otel!" {
+					t.Errorf("body = %s, want message \"Hello, otel!\"", body)
+				}
+			},
+		},
+		{
+			name:       "user by id",
+			method:     http.MethodGet,
+			path:       "/users/42",
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, tel *telemetry, body []byte) {
+				var resp map[string]any
+				if err := json.Unmarshal(body, &resp); err != nil || resp["id"] != "42" {
+					t.Errorf("body = %s, want id 42", body)
+				}
+			},
+		},
+		{
+			name:       "error",
+			method:     http.MethodGet,
+			path:       "/error",
+			wantStatus: http.StatusInternalServerError,
+			wantSpans:  []string{"getError"},
+			check: func(t *testing.T, tel *telemetry, body []byte) {
+				span := tel.span(t, "getError")
+				if span.Status().Code != codes.Error {
+					t.Errorf("getError status = %v, want Error", span.Status().Code)
+				}
+				if !hasEvent(span, "exception") {
+					t.Error("getError has no exception event")
+				}
+				server := tel.span(t, "/error")
+				if server.Status().Code != codes.Error {
+					t.Errorf("server span status = %v, want Error", server.Status().Code)
+				}
+				if got, _ := spanAttr(server, "error.type"); got.AsString() != "500" {
+					t.Errorf("server span error.type = %q, want 500", got.AsString())
+				}
+			},
+		},
+		{
+			name:       "add item",
+			method:     http.MethodPost,
+			path:       "/items/add",
+			wantStatus: http.StatusOK,
+			wantSpans:  []string{"addItem"},
+			wantLogs:   []string{"Incremented items counter"},
+			check: func(t *testing.T, tel *telemetry, body []byte) {
+				if got := sumValue(t, tel.metric(t, "items.counter")); got != 1 {
+					t.Errorf("items.counter = %d, want 1", got)
+				}
+			},
+		},
+		{
+			name:       "remove item",
+			method:     http.MethodPost,
+			path:       "/items/remove",
+			wantStatus: http.StatusOK,
+			wantSpans:  []string{"removeItem"},
+			wantLogs:   []string{"Decremented items counter"},
+			check: func(t *testing.T, tel *telemetry, body []byte) {
+				if got := sumValue(t, tel.metric(t, "items.counter")); got != -1 {
+					t.Errorf("items.counter = %d, want -1", got)
+				}
+			},
+		},
+		{
+			name:       "cpu fan speed",
+			method:     http.MethodGet,
+			path:       "/cpu/fanspeed",
+			wantStatus: http.StatusOK,
+			wantSpans:  []string{"getCPUFanSpeed"},
+			check: func(t *testing.T, tel *telemetry, body []byte) {
+				gauge, ok := tel.metric(t, "cpu.fan.speed").Data.(metricdata.Gauge[int64])
+				if !ok || len(gauge.DataPoints) != 1 {
+					t.Fatalf("cpu.fan.speed = %+v, want one gauge data point", gauge)
+				}
+				if v := gauge.DataPoints[0].Value; v < 1500 || v >= 2500 {
+					t.Errorf("cpu.fan.speed = %d, want [1500, 2500)", v)
+				}
+			},
+		},
+		{
+			name:       "external api",
+			method:     http.MethodGet,
+			path:       "/external-api",
+			wantStatus: http.StatusOK,
+			wantSpans:  []string{"callExternalAPI"},
+			wantLogs:   []string{"Recorded API call duration"},
+			check: func(t *testing.T, tel *telemetry, body []byte) {
+				span := tel.span(t, "callExternalAPI")
+				if !hasEvent(span, "External API call started") || !hasEvent(span, "External API call completed") {
+					t.Error("callExternalAPI is missing the start/complete events")
+				}
+				if _, ok := spanAttr(span, "api.latency_ms"); !ok {
+					t.Error("callExternalAPI has no api.latency_ms attribute")
+				}
+				// task.duration はビューで request.latency に名前が変わる
+				hist, ok := tel.metric(t, "request.latency").Data.(metricdata.Histogram[float64])
+				if !ok || len(hist.DataPoints) != 1 || hist.DataPoints[0].Count != 1 {
+					t.Errorf("request.latency = %+v, want one observation", hist)
+				}
+			},
+		},
+		{
+			name:       "memory metrics",
+			method:     http.MethodGet,
+			path:       "/metrics/memory",
+			wantStatus: http.StatusOK,
+			wantSpans:  []string{"getMemoryMetrics"},
+			check: func(t *testing.T, tel *telemetry, body []byte) {
+				sum, ok := tel.metric(t, "memory.usage").Data.(metricdata.Sum[float64])
+				if !ok || len(sum.DataPoints) != 1 || !sum.IsMonotonic {
+					t.Errorf("memory.usage = %+v, want one monotonic data point", sum)
+				}
+			},
+		},
+		{
+			name:       "connection metrics",
+			method:     http.MethodGet,
+			path:       "/metrics/connections",
+			wantStatus: http.StatusOK,
+			wantSpans:  []string{"getConnectionMetrics"},
+		},
+		{
+			name:       "open connection",
+			method:     http.MethodPost,
+			path:       "/connection/open",
+			wantStatus: http.StatusOK,
+			wantSpans:  []string{"simulateConnect"},
+			wantLogs:   []string{"Connection opened"},
+			check: func(t *testing.T, tel *telemetry, body []byte) {
+				if got := sumValue(t, tel.metric(t, "active.connections")); got != 1 {
+					t.Errorf("active.connections = %d, want 1", got)
+				}
+			},
+		},
+		{
+			name:       "close connection",
+			method:     http.MethodPost,
+			path:       "/connection/close",
+			wantStatus: http.StatusOK,
+			wantSpans:  []string{"simulateDisconnect"},
+			wantLogs:   []string{"Connection closed"},
+			check: func(t *testing.T, tel *telemetry, body []byte) {
+				if got := sumValue(t, tel.metric(t, "active.connections")); got != 0 {
+					t.Errorf("active.connections = %d, want 0", got)
+				}
+			},
+		},
+		{
+			name:       "heap metrics",
+			method:     http.MethodGet,
+			path:       "/metrics/heap",
+			wantStatus: http.StatusOK,
+			wantSpans:  []string{"getHeapMetrics"},
+			check: func(t *testing.T, tel *telemetry, body []byte) {
+				gauge, ok := tel.metric(t, "memory.heap").Data.(metricdata.Gauge[int64])
+				if !ok || len(gauge.DataPoints) != 1 {
+					t.Errorf("memory.heap = %+v, want one gauge data point", gauge)
+				}
+			},
+		},
+		{
+			name:       "allocate memory",
+			method:     http.MethodPost,
+			path:       "/memory/allocate",
+			wantStatus: http.StatusOK,
+			wantSpans:  []string{"allocateMemory"},
+			wantLogs:   []string{"Memory allocated"},
+		},
+		{
+			name:       "free memory",
+			method:     http.MethodPost,
+			path:       "/memory/free",
+			wantStatus: http.StatusOK,
+			wantSpans:  []string{"freeMemory"},
+			wantLogs:   []string{"Memory freed"},
+		},
+		{
+			name:       "not found",
+			method:     http.MethodGet,
+			path:       "/no-such-route",
+			wantStatus: http.StatusNotFound,
+			check: func(t *testing.T, tel *telemetry, body []byte) {
+				// 4xx はクライアント起因のため、サーバースパンをエラーにしない
+				server := tel.spans.Ended()[0]
+				if server.Status().Code != codes.Unset {
+					t.Errorf("server span status = %v, want Unset", server.Status().Code)
+				}
+				if got, ok := spanAttr(server, "error.type"); ok {
+					t.Errorf("server span error.type = %q, want unset", got.AsString())
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, tel := newTestServer(t, Options{})
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rec := httptest.NewRecorder()
+			s.Routes().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			spans := tel.spans.Ended()
+			if len(spans) != len(tt.wantSpans)+1 {
+				t.Errorf("got %d spans, want %d", len(spans), len(tt.wantSpans)+1)
+			}
+			for _, name := range tt.wantSpans {
+				span := tel.span(t, name)
+				if !span.Parent().IsValid() {
+					t.Errorf("span %q has no parent", name)
+				}
+			}
+
+			// リクエスト中のログはスパンと相関付けられている
+			for _, want := range tt.wantLogs {
+				record := tel.log(t, want)
+				if record.TraceID() != spans[0].SpanContext().TraceID() || !record.SpanID().IsValid() {
+					t.Errorf("log %q is not correlated with the request trace", want)
+				}
+			}
+
+			if tt.check != nil {
+				tt.check(t, tel, rec.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestRoutes_Panic(t *testing.T) {
+	s, tel := newTestServer(t, Options{
+		Faults: fault.Config{Rules: []fault.Rule{{Route: "/healthz", PanicProbability: 1}}},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	rec := httptest.NewRecorder()
+	s.Routes().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/problem+json") {
+		t.Errorf("Content-Type = %q, want application/problem+json", ct)
+	}
+
+	span := tel.span(t, "/healthz")
+	if span.Status().Code != codes.Error {
+		t.Errorf("server span status = %v, want Error", span.Status().Code)
+	}
+	var stack bool
+	for _, e := range span.Events() {
+		for _, kv := range e.Attributes {
+			if kv.Key == "exception.stacktrace" && kv.Value.AsString() != "" {
+				stack = true
+			}
+		}
+	}
+	if !stack {
+		t.Error("server span has no exception.stacktrace")
+	}
+	if got := sumValue(t, tel.metric(t, "http.server.panics")); got != 1 {
+		t.Errorf("http.server.panics = %d, want 1", got)
+	}
+
+	// panic はリクエストのトレースに関連付けた Error のログとしても記録する
+	record := tel.log(t, "Recovered from panic")
+	if record.Severity() != log.SeverityError {
+		t.Errorf("severity = %v, want ERROR", record.Severity())
+	}
+	if record.TraceID() != span.SpanContext().TraceID() || record.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("log is not correlated with the server span")
+	}
+	attrs := map[string]string{}
+	record.WalkAttributes(func(kv log.KeyValue) bool {
+		attrs[kv.Key] = kv.Value.String()
+		return true
+	})
+	if attrs["http.route"] != "/healthz" || attrs["exception.stacktrace"] == "" {
+		t.Errorf("log attributes = %v, want http.route and exception.stacktrace", attrs)
+	}
+}
+
+func TestRoutes_AccessLog(t *testing.T) {
+	s, tel := newTestServer(t, Options{
+		AccessLog: middleware.AccessLogOptions{ExcludePaths: []string{"/healthz"}},
+		// httptest.NewRequest の接続元は 192.0.2.1
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+	})
+	routes := s.Routes()
+
+	for _, path := range []string{"/users/42", "/healthz", "/missing"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Forwarded-For", "203.0.113.7, 192.0.2.10")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		routes.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var accessLogs []sdklog.Record
+	for _, r := range tel.logs.Records() {
+		if r.EventName() == middleware.AccessLogEventName {
+			accessLogs = append(accessLogs, r)
+		}
+	}
+	// /healthz は除外される
+	if len(accessLogs) != 2 {
+		t.Fatalf("access logs = %d, want 2", len(accessLogs))
+	}
+
+	record := tel.log(t, "GET /users/{id} 200")
+	if record.Severity() != log.SeverityInfo {
+		t.Errorf("severity = %v, want INFO", record.Severity())
+	}
+	span := tel.span(t, "/users/{id}")
+	if record.TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("trace id = %s, want %s", record.TraceID(), span.SpanContext().TraceID())
+	}
+	if v, _ := spanAttr(span, "client.address"); v.AsString() != "203.0.113.7" {
+		t.Errorf("span client.address = %q, want 203.0.113.7", v.AsString())
+	}
+	attrs := map[string]log.Value{}
+	record.WalkAttributes(func(kv log.KeyValue) bool {
+		attrs[kv.Key] = kv.Value
+		return true
+	})
+	if got := attrs["client.address"].AsString(); got != "203.0.113.7" {
+		t.Errorf("client.address = %q, want 203.0.113.7", got)
+	}
+	if got := attrs["url.scheme"].AsString(); got != "https" {
+		t.Errorf("url.scheme = %q, want https", got)
+	}
+	if got := attrs["http.route"].AsString(); got != "/users/{id}" {
+		t.Errorf("http.route = %q, want /users/{id}", got)
+	}
+
+	notFound := tel.log(t, "GET /missing 404")
+	if got := notFound.Severity(); got != log.SeverityWarn {
+		t.Errorf("404 severity = %v, want WARN", got)
+	}
+}
+
+func TestRoutes_AccessLogSampleRateZero(t *testing.T) {
+	// SampleRate が 0 の場合は 2xx / 3xx を記録せず、4xx / 5xx だけを記録する
+	rate := 0.0
+	s, tel := newTestServer(t, Options{AccessLog: middleware.AccessLogOptions{SampleRate: &rate}})
+	routes := s.Routes()
+	for _, path := range []string{"/users/42", "/missing"} {
+		routes.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var bodies []string
+	for _, r := range tel.logs.Records() {
+		if r.EventName() == middleware.AccessLogEventName {
+			bodies = append(bodies, r.Body().AsString())
+		}
+	}
+	if strings.Join(bodies, ",") != "GET /missing 404" {
+		t.Errorf("access logs = %q, want only the 404", bodies)
+	}
+}
+
+func TestRoutes_TraceResponse(t *testing.T) {
+	s, tel := newTestServer(t, Options{
+		TraceResponse: middleware.TraceResponseOptions{
+			ExcludePaths: []string{"/healthz"},
+			ViewerURL:    "http://localhost:8081/traces/",
+		},
+	})
+	routes := s.Routes()
+
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/error", nil))
+	server := tel.span(t, "/error").SpanContext()
+	traceID := server.TraceID().String()
+
+	if got := rec.Header().Get(middleware.HeaderTraceID); got != traceID {
+		t.Errorf("X-Trace-Id = %q, want %q", got, traceID)
+	}
+	want := "00-" + traceID + "-" + server.SpanID().String() + "-01"
+	if got := rec.Header().Get(middleware.HeaderTraceResponse); got != want {
+		t.Errorf("traceresponse = %q, want %q", got, want)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["trace_id"] != traceID {
+		t.Errorf("trace_id = %v, want %s", body["trace_id"], traceID)
+	}
+	if body["trace_url"] != "http://localhost:8081/traces/"+traceID {
+		t.Errorf("trace_url = %v", body["trace_url"])
+	}
+
+	rec = httptest.NewRecorder()
+	routes.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if got := rec.Header().Get(middleware.HeaderTraceID); got != "" {
+		t.Errorf("excluded path X-Trace-Id = %q, want none", got)
+	}
+}
+
+func TestRoutes_Probes(t *testing.T) {
+	// キャッシュせずに毎回チェックする
+	probes := health.New(health.Options{CacheTTL: time.Nanosecond})
+	s, tel := newTestServer(t, Options{Health: probes})
+	routes := s.Routes()
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	if got := get("/startupz"); got != http.StatusServiceUnavailable {
+		t.Errorf("/startupz before start status = %d, want 503", got)
+	}
+	probes.MarkStarted()
+	for _, path := range []string{"/livez", "/readyz", "/startupz"} {
+		if got := get(path); got != http.StatusOK {
+			t.Errorf("%s status = %d, want 200", path, got)
+		}
+	}
+	// プローブは既定でトレースしない
+	if n := len(tel.spans.Ended()); n != 0 {
+		t.Errorf("probe spans = %d, want 0", n)
+	}
+
+	// 外部 API の遅い呼び出しが続くとサーキットが開くが、readiness は詳細として報告するだけで失敗しない。
+	// /external-api は止めずに呼び出し、サーキットの状態をスパンに記録する
+	for range upstreamFailureThreshold {
+		s.upstream.Record(false)
+	}
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report health.Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || report.Info["upstream-api"].Status != health.StatusFail {
+		t.Errorf("/readyz with open circuit = %d %s, want 200 with failed upstream-api info", rec.Code, rec.Body.String())
+	}
+	if got := get("/external-api"); got != http.StatusOK {
+		t.Errorf("/external-api with open circuit status = %d, want 200", got)
+	}
+	if got, _ := spanAttr(tel.span(t, "callExternalAPI"), "api.circuit.state"); got.AsString() != circuitOpen {
+		t.Errorf("api.circuit.state = %q, want %s", got.AsString(), circuitOpen)
+	}
+	if got := get("/livez"); got != http.StatusOK {
+		t.Errorf("/livez with open circuit status = %d, want 200", got)
+	}
+}
+
+func TestViews_WithTelemetryConfig(t *testing.T) {
+	// 設定のビューが組み込みのビューと同じ計装に一致しても、ストリームは 1 つだけ作られる
+	ctx := context.Background()
+	views := telemetryconfig.NewViews([]telemetryconfig.ViewConfig{
+		{Instrument: "task.duration", Scope: ScopeName, Buckets: []float64{0.1, 1}},
+	}, Views()...)
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithView(views.View()))
+	defer func() { _ = mp.Shutdown(ctx) }()
+
+	hist, err := mp.Meter(ScopeName).Float64Histogram("task.duration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist.Record(ctx, 0.5)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	var metrics []metricdata.Metrics
+	for _, sm := range rm.ScopeMetrics {
+		metrics = append(metrics, sm.Metrics...)
+	}
+	if len(metrics) != 1 || metrics[0].Name != "request.latency" {
+		t.Fatalf("metrics = %+v, want only request.latency", metrics)
+	}
+	data, ok := metrics[0].Data.(metricdata.Histogram[float64])
+	if !ok || len(data.DataPoints) != 1 {
+		t.Fatalf("request.latency data = %+v, want one histogram data point", metrics[0].Data)
+	}
+	if got := data.DataPoints[0].Bounds; len(got) != 2 || got[0] != 0.1 || got[1] != 1 {
+		t.Errorf("bounds = %v, want [0.1 1]", got)
+	}
+}
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	now := time.Unix(0, 0)
+	cb := newCircuitBreaker(2, time.Minute)
+	cb.now = func() time.Time { return now }
+
+	cb.Record(false)
+	if got := cb.State(); got != circuitClosed {
+		t.Errorf("State() below threshold = %s, want closed", got)
+	}
+	cb.Record(false)
+	if got := cb.State(); got != circuitOpen {
+		t.Errorf("State() at threshold = %s, want open", got)
+	}
+
+	// cooldown 後は half-open になり、失敗すると再び開く
+	now = now.Add(time.Minute)
+	if got := cb.State(); got != circuitHalfOpen {
+		t.Errorf("State() after cooldown = %s, want half-open", got)
+	}
+	cb.Record(false)
+	if got := cb.State(); got != circuitOpen {
+		t.Errorf("State() after failure in half-open = %s, want open", got)
+	}
+
+	// 成功すると閉じる
+	now = now.Add(time.Minute)
+	cb.Record(true)
+	if got := cb.State(); got != circuitClosed {
+		t.Errorf("State() after success = %s, want closed", got)
+	}
+}
+
+func TestAdminRoutes_Simulators(t *testing.T) {
+	s, _ := newTestServer(t, Options{})
+	admin := s.AdminRoutes()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.StartSimulations(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/simulators/connections/stop", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("stop status = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/simulators", nil))
+	var states []simulator.State
+	if err := json.Unmarshal(rec.Body.Bytes(), &states); err != nil {
+		t.Fatalf("failed to decode %s: %v", rec.Body, err)
+	}
+	got := map[string]string{}
+	for _, st := range states {
+		got[st.Name] = st.Status
+	}
+	want := map[string]string{
+		SimulatorFanSpeed:    simulator.StatusRunning,
+		SimulatorConnections: simulator.StatusStopped,
+		SimulatorHeap:        simulator.StatusRunning,
+	}
+	for name, status := range want {
+		if got[name] != status {
+			t.Errorf("%s status = %q, want %q", name, got[name], status)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/simulator"
+)
+
+// シミュレーターの名前。管理 API の /simulators/{name} で操作する
+const (
+	SimulatorFanSpeed    = "fanspeed"
+	SimulatorConnections = "connections"
+	SimulatorHeap        = "heap"
+)
+
+// シミュレーターの初期パラメーター
+var (
+	// ファン速度は 5 回だけ変化させて完了する
+	fanSpeedParams = simulator.Params{
+		MinIntervalMS: 0, MaxIntervalMS: 2000,
+		MinDelta: -200, MaxDelta: 200,
+		Min: 1500, Max: 2500,
+		Limit: 5,
+	}
+	// コネクション数は 2〜4 秒ごとに -5〜+10 の間で変動させる
+	connectionsParams = simulator.Params{
+		MinIntervalMS: 2000, MaxIntervalMS: 4000,
+		MinDelta: -5, MaxDelta: 10,
+		Min: 0, Max: 100,
+	}
+	// ヒープメモリ使用量は 1〜3 秒ごとに -10MB〜+20MB の間で変動させる
+	heapParams = simulator.Params{
+		MinIntervalMS: 1000, MaxIntervalMS: 3000,
+		MinDelta: -10 * 1024 * 1024, MaxDelta: 20 * 1024 * 1024,
+		Min: minHeapUsage, Max: maxHeapUsage,
+	}
+)
+
+// getCPUFanSpeed はデモンストレーション目的でランダムなファン速度を生成します
+// 実際のアプリケーションでは、これを実際のファン速度を取得するように置き換えてください
+func getCPUFanSpeed() int64 {
+	return int64(1500 + rand.Intn(1000))
+}
+
+// newSimulations はファン速度・コネクション数・ヒープメモリ使用量のシミュレーターを作成する
+func (s *Server) newSimulations() (*simulator.Manager, error) {
+	fanSpeed, err := simulator.NewRandom(SimulatorFanSpeed, fanSpeedParams, s.stepFanSpeed, s.fanSpeedValue.Load)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fan speed simulator: %w", err)
+	}
+	connections, err := simulator.NewRandom(SimulatorConnections, connectionsParams, s.stepConnections, s.connections.Load)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connections simulator: %w", err)
+	}
+	heap, err := simulator.NewRandom(SimulatorHeap, heapParams, s.stepHeap, s.heap.Load)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create heap simulator: %w", err)
+	}
+	return simulator.NewManager(fanSpeed, connections, heap), nil
+}
+
+// stepFanSpeed はファン速度を変化させ、/cpu/fanspeed が読み出す fanSpeed チャンネルに送信する。
+// 読み出されていない古い値は捨てて最新の値に置き換える
+func (s *Server) stepFanSpeed(_ context.Context, delta, lower, upper int64) int64 {
+	speed := s.fanSpeedValue.Add(delta, lower, upper)
+	select {
+	case <-s.fanSpeed:
+	default:
+	}
+	select {
+	case s.fanSpeed <- speed:
+	default:
+	}
+	return speed
+}
+
+// stepConnections はコネクション数を変化させる
+func (s *Server) stepConnections(ctx context.Context, delta, lower, upper int64) int64 {
+	total := s.connections.Add(delta, lower, upper)
+	s.simLogger.DebugContext(ctx, "Simulated connection change",
+		"change", delta, "total_connections", total)
+	return total
+}
+
+// stepHeap はヒープメモリ使用量を変化させる
+func (s *Server) stepHeap(ctx context.Context, delta, lower, upper int64) int64 {
+	total := s.heap.Add(delta, lower, upper)
+	s.simLogger.DebugContext(ctx, "Simulated heap memory change",
+		"change_mb", float64(delta)/(1024*1024), "total_heap_mb", float64(total)/(1024*1024))
+	return total
+}
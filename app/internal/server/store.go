@@ -0,0 +1,64 @@
+package server
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// ヒープメモリ使用量の上下限（10MB〜500MB）
+const (
+	minHeapUsage = 10 * 1024 * 1024
+	maxHeapUsage = 500 * 1024 * 1024
+)
+
+// intStore は Observable な計装から参照される int64 の値を排他制御付きで保持する
+type intStore struct {
+	mu    sync.Mutex
+	value int64
+}
+
+// Load は現在の値を返す
+func (st *intStore) Load() int64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.value
+}
+
+// Add は delta を加算し、[lower, upper] の範囲に丸めた結果を返す
+func (st *intStore) Add(delta, lower, upper int64) int64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.value += delta
+	if st.value < lower {
+		st.value = lower
+	}
+	if st.value > upper {
+		st.value = upper
+	}
+	return st.value
+}
+
+// floatStore は Observable な計装が最後に報告した float64 の値を保持する
+type floatStore struct {
+	mu    sync.Mutex
+	value float64
+}
+
+// Load は現在の値を返す
+func (st *floatStore) Load() float64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.value
+}
+
+// Store は値を更新する
+func (st *floatStore) Store(v float64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.value = v
+}
+
+// initialHeapUsage はヒープメモリ使用量の初期値（50MB〜200MBの範囲）を返す
+func initialHeapUsage() int64 {
+	return int64(50*1024*1024) + int64(rand.Intn(150*1024*1024))
+}
@@ -0,0 +1,19 @@
+package server
+
+import (
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// Views は MeterProvider に登録するビューを返す
+func Views() []sdkmetric.View {
+	// task.duration ヒストグラムの名前を request.latency に変更するビュー
+	view := sdkmetric.NewView(sdkmetric.Instrument{
+		Name: "task.duration",
+		Scope: instrumentation.Scope{
+			Name: ScopeName,
+		},
+	}, sdkmetric.Stream{Name: "request.latency"})
+
+	return []sdkmetric.View{view}
+}
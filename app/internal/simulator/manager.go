@@ -0,0 +1,145 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/response"
+	"github.com/go-chi/chi/v5"
+)
+
+// ErrNotStarted は Manager.Start の前に管理 API からシミュレーターを開始しようとした場合のエラー
+var ErrNotStarted = errors.New("simulations have not been started")
+
+// Manager は名前付きのシミュレーターをまとめて開始し、管理 API で操作できるようにする
+type Manager struct {
+	sims   []Simulator
+	byName map[string]Simulator
+
+	mu sync.Mutex
+	// ctx は Start に渡されたコンテキスト。管理 API から開始したシミュレーターもこれに従って停止する
+	ctx context.Context
+}
+
+// NewManager は sims を管理する Manager を作成する
+func NewManager(sims ...Simulator) *Manager {
+	m := &Manager{sims: sims, byName: make(map[string]Simulator, len(sims))}
+	for _, sim := range sims {
+		m.byName[sim.Name()] = sim
+	}
+	return m
+}
+
+// Start はすべてのシミュレーターを開始する。ctx のキャンセルで停止する
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	m.ctx = ctx
+	m.mu.Unlock()
+
+	var errs []error
+	for _, sim := range m.sims {
+		if err := sim.Start(ctx); err != nil && !errors.Is(err, ErrRunning) {
+			errs = append(errs, fmt.Errorf("failed to start simulator %s: %w", sim.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Get は name のシミュレーターを返す
+func (m *Manager) Get(name string) (Simulator, bool) {
+	sim, ok := m.byName[name]
+	return sim, ok
+}
+
+// States はすべてのシミュレーターの状態を返す
+func (m *Manager) States() []State {
+	states := make([]State, 0, len(m.sims))
+	for _, sim := range m.sims {
+		states = append(states, sim.State())
+	}
+	return states
+}
+
+// AdminRoutes はシミュレーターを操作する管理 API を返す
+//
+//	GET  /                全シミュレーターの状態を返す
+//	GET  /{name}          状態を返す
+//	PUT  /{name}          パラメーターを変更する（指定した項目のみ）
+//	POST /{name}/start    開始する
+//	POST /{name}/stop     停止する
+//	POST /{name}/pause    一時停止する
+//	POST /{name}/resume   再開する
+func (m *Manager) AdminRoutes() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		response.JSON(w, r, http.StatusOK, m.States())
+	})
+	r.Route("/{name}", func(r chi.Router) {
+		r.Get("/", m.handle(func(*http.Request, Simulator) error { return nil }))
+		r.Put("/", m.handle(putParams))
+		r.Post("/start", m.handle(func(_ *http.Request, sim Simulator) error {
+			m.mu.Lock()
+			ctx := m.ctx
+			m.mu.Unlock()
+			if ctx == nil {
+				return ErrNotStarted
+			}
+			return sim.Start(ctx)
+		}))
+		r.Post("/stop", m.handle(func(_ *http.Request, sim Simulator) error { return sim.Stop() }))
+		r.Post("/pause", m.handle(func(_ *http.Request, sim Simulator) error { return sim.Pause() }))
+		r.Post("/resume", m.handle(func(_ *http.Request, sim Simulator) error { return sim.Resume() }))
+	})
+	return r
+}
+
+// handle は {name} のシミュレーターに op を実行し、状態を返すハンドラーを返す
+func (m *Manager) handle(op func(*http.Request, Simulator) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		sim, ok := m.Get(name)
+		if !ok {
+			response.Error(w, r, http.StatusNotFound, fmt.Errorf("simulator %q not found", name))
+			return
+		}
+		if err := op(r, sim); err != nil {
+			response.Error(w, r, statusOf(err), err)
+			return
+		}
+		response.JSON(w, r, http.StatusOK, sim.State())
+	}
+}
+
+// putParams は現在のパラメーターにリクエストボディの項目を上書きして適用する
+func putParams(r *http.Request, sim Simulator) error {
+	params := sim.Params()
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return &badRequestError{err}
+	}
+	if err := sim.SetParams(params); err != nil {
+		return &badRequestError{err}
+	}
+	return nil
+}
+
+type badRequestError struct{ err error }
+
+func (e *badRequestError) Error() string { return e.err.Error() }
+func (e *badRequestError) Unwrap() error { return e.err }
+
+func statusOf(err error) int {
+	var bad *badRequestError
+	switch {
+	case errors.As(err, &bad):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrRunning), errors.Is(err, ErrNotRunning), errors.Is(err, ErrPaused),
+		errors.Is(err, ErrNotPaused), errors.Is(err, ErrNotStarted):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
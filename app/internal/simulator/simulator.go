@@ -0,0 +1,299 @@
+// Package simulator はデモ用のメトリクスの値をバックグラウンドで変動させるシミュレーターと、
+// それらを開始・停止・一時停止し、パラメーターを変更する管理 API を提供する。
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// シミュレーターの状態
+const (
+	StatusStopped   = "stopped"
+	StatusRunning   = "running"
+	StatusPaused    = "paused"
+	StatusCompleted = "completed"
+)
+
+// 状態遷移できない操作のエラー
+var (
+	ErrRunning    = errors.New("simulator is already running")
+	ErrNotRunning = errors.New("simulator is not running")
+	ErrPaused     = errors.New("simulator is already paused")
+	ErrNotPaused  = errors.New("simulator is not paused")
+)
+
+// Simulator はバックグラウンドで値を変動させる処理。Start に渡した ctx のキャンセルでも停止する
+type Simulator interface {
+	Name() string
+	Start(ctx context.Context) error
+	Stop() error
+	Pause() error
+	Resume() error
+	Params() Params
+	SetParams(p Params) error
+	State() State
+}
+
+// Params はシミュレーターのパラメーター。間隔・変化量は範囲内から一様に選ぶ
+type Params struct {
+	MinIntervalMS int64 `json:"min_interval_ms"`
+	MaxIntervalMS int64 `json:"max_interval_ms"`
+	MinDelta      int64 `json:"min_delta"`
+	MaxDelta      int64 `json:"max_delta"`
+	// Min と Max は値の範囲。変化後の値はこの範囲に収める
+	Min int64 `json:"min"`
+	Max int64 `json:"max"`
+	// Limit は変化させる回数。0 の場合は停止するまで続ける
+	Limit int64 `json:"limit"`
+}
+
+// パラメーターの上限。範囲の幅の計算や time.Duration への変換が int64 を超えないようにする
+const (
+	// MaxIntervalMS は max_interval_ms の上限（24 時間）
+	MaxIntervalMS = int64(24 * time.Hour / time.Millisecond)
+	// MaxAbsDelta は min_delta と max_delta の絶対値の上限（JSON の数値として誤差なく扱える 2^53）
+	MaxAbsDelta = int64(1) << 53
+)
+
+// Validate はパラメーターの妥当性を検証する
+func (p Params) Validate() error {
+	if p.MinIntervalMS < 0 || p.MaxIntervalMS <= 0 || p.MinIntervalMS > p.MaxIntervalMS {
+		return fmt.Errorf("interval must satisfy 0 <= min_interval_ms <= max_interval_ms and max_interval_ms > 0: %d, %d", p.MinIntervalMS, p.MaxIntervalMS)
+	}
+	if p.MaxIntervalMS > MaxIntervalMS {
+		return fmt.Errorf("max_interval_ms must not exceed %d: %d", MaxIntervalMS, p.MaxIntervalMS)
+	}
+	if p.MinDelta < -MaxAbsDelta || p.MaxDelta > MaxAbsDelta {
+		return fmt.Errorf("delta must be within [-%d, %d]: %d, %d", MaxAbsDelta, MaxAbsDelta, p.MinDelta, p.MaxDelta)
+	}
+	if p.MinDelta > p.MaxDelta {
+		return fmt.Errorf("min_delta must not exceed max_delta: %d > %d", p.MinDelta, p.MaxDelta)
+	}
+	if p.Min > p.Max {
+		return fmt.Errorf("min must not exceed max: %d > %d", p.Min, p.Max)
+	}
+	if p.Limit < 0 {
+		return fmt.Errorf("limit must not be negative: %d", p.Limit)
+	}
+	return nil
+}
+
+func (p Params) interval() time.Duration {
+	return time.Duration(p.MinIntervalMS+rand.Int63n(p.MaxIntervalMS-p.MinIntervalMS+1)) * time.Millisecond
+}
+
+func (p Params) delta() int64 {
+	return p.MinDelta + rand.Int63n(p.MaxDelta-p.MinDelta+1)
+}
+
+// State は管理 API で返すシミュレーターの状態
+type State struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Params Params `json:"params"`
+	// Value は現在の値。シミュレーター以外（ハンドラーなど）による変更も含む
+	Value int64 `json:"value"`
+	// Steps は開始してから値を変化させた回数
+	Steps    int64      `json:"steps"`
+	LastStep *time.Time `json:"last_step,omitempty"`
+}
+
+// StepFunc は現在の値に delta を加えて [min, max] に収め、変化後の値を返す
+type StepFunc func(ctx context.Context, delta, min, max int64) int64
+
+// Random は Params の範囲からランダムに選んだ間隔と変化量で値を変化させる Simulator
+type Random struct {
+	name  string
+	step  StepFunc
+	value func() int64
+
+	mu       sync.Mutex
+	params   Params
+	status   string
+	steps    int64
+	lastStep time.Time
+	cancel   context.CancelFunc
+	done     chan struct{}
+	// resume は一時停止中に作成し、再開時に閉じる
+	resume chan struct{}
+}
+
+var _ Simulator = (*Random)(nil)
+
+// NewRandom は停止状態の Random を作成する。value は State で返す現在の値
+func NewRandom(name string, params Params, step StepFunc, value func() int64) (*Random, error) {
+	if err := params.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid params for simulator %s: %w", name, err)
+	}
+	return &Random{name: name, params: params, step: step, value: value, status: StatusStopped}, nil
+}
+
+// Name はシミュレーターの名前を返す
+func (s *Random) Name() string {
+	return s.name
+}
+
+// Start はバックグラウンドで値の変化を開始する。完了した後に呼び出すと回数を数え直して再開する
+func (s *Random) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status == StatusRunning || s.status == StatusPaused {
+		return ErrRunning
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.status = StatusRunning
+	s.steps = 0
+	go s.run(ctx, s.done)
+	return nil
+}
+
+// Stop は値の変化を停止し、バックグラウンドの処理が終了するまで待つ
+func (s *Random) Stop() error {
+	s.mu.Lock()
+	if s.status == StatusStopped {
+		s.mu.Unlock()
+		return ErrNotRunning
+	}
+	cancel, done := s.cancel, s.done
+	s.status = StatusStopped
+	s.resume = nil
+	s.mu.Unlock()
+
+	cancel()
+	<-done
+	return nil
+}
+
+// Pause は値の変化を一時停止する
+func (s *Random) Pause() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch s.status {
+	case StatusPaused:
+		return ErrPaused
+	case StatusRunning:
+		s.status = StatusPaused
+		s.resume = make(chan struct{})
+		return nil
+	default:
+		return ErrNotRunning
+	}
+}
+
+// Resume は一時停止した値の変化を再開する
+func (s *Random) Resume() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status != StatusPaused {
+		return ErrNotPaused
+	}
+	s.status = StatusRunning
+	close(s.resume)
+	s.resume = nil
+	return nil
+}
+
+// Params は現在のパラメーターを返す
+func (s *Random) Params() Params {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.params
+}
+
+// SetParams はパラメーターを変更する。実行中の場合は次の変化から適用する
+func (s *Random) SetParams(p Params) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.params = p
+	return nil
+}
+
+// State は現在の状態を返す
+func (s *Random) State() State {
+	s.mu.Lock()
+	state := State{
+		Name:   s.name,
+		Status: s.status,
+		Params: s.params,
+		Steps:  s.steps,
+	}
+	if !s.lastStep.IsZero() {
+		lastStep := s.lastStep
+		state.LastStep = &lastStep
+	}
+	s.mu.Unlock()
+	state.Value = s.value()
+	return state
+}
+
+func (s *Random) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	defer func() {
+		// Start に渡した ctx がキャンセルされた場合も停止状態にする。Stop の後に再開した場合は別の実行のため変更しない
+		s.mu.Lock()
+		if s.done == done && (s.status == StatusRunning || s.status == StatusPaused) {
+			s.status = StatusStopped
+			s.resume = nil
+		}
+		s.mu.Unlock()
+	}()
+	for {
+		s.mu.Lock()
+		params, resume := s.params, s.resume
+		if params.Limit > 0 && s.steps >= params.Limit && s.status == StatusRunning {
+			s.status = StatusCompleted
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+
+		if resume != nil {
+			select {
+			case <-resume:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		if !sleep(ctx, params.interval()) {
+			return
+		}
+
+		s.mu.Lock()
+		// 待機中に一時停止された場合は変化させない
+		paused := s.status == StatusPaused
+		s.mu.Unlock()
+		if paused {
+			continue
+		}
+
+		s.step(ctx, params.delta(), params.Min, params.Max)
+
+		s.mu.Lock()
+		s.steps++
+		s.lastStep = time.Now()
+		s.mu.Unlock()
+	}
+}
+
+// sleep は d だけ待機する。ctx がキャンセルされた場合は false を返す
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
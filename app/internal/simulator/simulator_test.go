@@ -0,0 +1,191 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fastParams = Params{MinIntervalMS: 1, MaxIntervalMS: 1, MinDelta: 1, MaxDelta: 1, Min: 0, Max: 100}
+
+func newCounter(t *testing.T, name string, params Params) (*Random, *atomic.Int64) {
+	t.Helper()
+	var value atomic.Int64
+	sim, err := NewRandom(name, params, func(_ context.Context, delta, lower, upper int64) int64 {
+		return value.Add(delta)
+	}, value.Load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sim, &value
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition was not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRandom_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	params := fastParams
+	params.Limit = 3
+	sim, value := newCounter(t, "counter", params)
+
+	if err := sim.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.Start(ctx); err != ErrRunning {
+		t.Errorf("second Start() error = %v, want ErrRunning", err)
+	}
+	waitFor(t, func() bool { return sim.State().Status == StatusCompleted })
+	if st := sim.State(); st.Steps != 3 || st.Value != 3 || st.LastStep == nil {
+		t.Errorf("state = %+v, want 3 steps and value 3", st)
+	}
+
+	// 回数の上限をなくして再開し、一時停止中は変化しないことを確認する
+	params.Limit = 0
+	if err := sim.SetParams(params); err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.Start(ctx); err != nil {
+		t.Fatalf("Start() after completion error = %v", err)
+	}
+	waitFor(t, func() bool { return value.Load() > 5 })
+	if err := sim.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.Pause(); err != ErrPaused {
+		t.Errorf("second Pause() error = %v, want ErrPaused", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	paused := value.Load()
+	time.Sleep(20 * time.Millisecond)
+	if got := value.Load(); got != paused {
+		t.Errorf("value changed while paused: %d -> %d", paused, got)
+	}
+	if err := sim.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return value.Load() > paused })
+
+	if err := sim.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	stopped := value.Load()
+	time.Sleep(10 * time.Millisecond)
+	if got := value.Load(); got != stopped || sim.State().Status != StatusStopped {
+		t.Errorf("after Stop() value = %d (was %d), status = %s", got, stopped, sim.State().Status)
+	}
+	if err := sim.Stop(); err != ErrNotRunning {
+		t.Errorf("second Stop() error = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestRandom_StopsOnContextCancel(t *testing.T) {
+	sim, _ := newCounter(t, "counter", fastParams)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := sim.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	waitFor(t, func() bool { return sim.State().Status == StatusStopped })
+}
+
+func TestParams_Validate(t *testing.T) {
+	valid := Params{MinIntervalMS: 0, MaxIntervalMS: MaxIntervalMS, MinDelta: -MaxAbsDelta, MaxDelta: MaxAbsDelta, Max: 1}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate(%+v) = %v, want nil", valid, err)
+	}
+	// 上限の範囲では間隔と変化量の計算が桁あふれしない
+	for range 100 {
+		if d := valid.interval(); d < 0 || d > 24*time.Hour {
+			t.Fatalf("interval() = %v, want within [0, 24h]", d)
+		}
+		if d := valid.delta(); d < -MaxAbsDelta || d > MaxAbsDelta {
+			t.Fatalf("delta() = %d, want within ±%d", d, MaxAbsDelta)
+		}
+	}
+
+	for _, p := range []Params{
+		{MaxIntervalMS: math.MaxInt64},
+		{MaxIntervalMS: MaxIntervalMS + 1},
+		{MaxIntervalMS: 1, MinDelta: math.MinInt64, MaxDelta: math.MaxInt64},
+		{MaxIntervalMS: 1, MinDelta: -1, MaxDelta: math.MaxInt64},
+		{MaxIntervalMS: 1, MinDelta: -MaxAbsDelta - 1},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", p)
+		}
+	}
+}
+
+func TestManager_AdminRoutes(t *testing.T) {
+	sim, _ := newCounter(t, "counter", fastParams)
+	m := NewManager(sim)
+	handler := m.AdminRoutes()
+
+	do := func(method, path, body string) (int, string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec.Code, rec.Body.String()
+	}
+
+	if code, _ := do(http.MethodPost, "/counter/start", ""); code != http.StatusConflict {
+		t.Errorf("start before Manager.Start = %d, want 409", code)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := m.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	code, body := do(http.MethodPut, "/counter", `{"max_interval_ms": 50, "max": 10}`)
+	if code != http.StatusOK {
+		t.Fatalf("PUT = %d %s", code, body)
+	}
+	var st State
+	if err := json.Unmarshal([]byte(body), &st); err != nil {
+		t.Fatal(err)
+	}
+	// 指定しなかった項目は変更しない
+	want := fastParams
+	want.MaxIntervalMS, want.Max = 50, 10
+	if st.Params != want || st.Status != StatusRunning {
+		t.Errorf("state = %+v, want params %+v running", st, want)
+	}
+
+	for _, tt := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPut, "/counter", `{"min": 20}`, http.StatusBadRequest},
+		{http.MethodPut, "/counter", `{"max_interval_ms": 9223372036854775807}`, http.StatusBadRequest},
+		{http.MethodPut, "/counter", `{"min_interval_ms": 0, "max_interval_ms": 86400001}`, http.StatusBadRequest},
+		{http.MethodPut, "/counter", `{"min_delta": -9223372036854775808, "max_delta": 9223372036854775807}`, http.StatusBadRequest},
+		{http.MethodPut, "/counter", `{"min_delta": -1, "max_delta": 9223372036854775807}`, http.StatusBadRequest},
+		{http.MethodPost, "/counter/pause", "", http.StatusOK},
+		{http.MethodPost, "/counter/pause", "", http.StatusConflict},
+		{http.MethodPost, "/counter/resume", "", http.StatusOK},
+		{http.MethodPost, "/counter/stop", "", http.StatusOK},
+		{http.MethodPost, "/counter/resume", "", http.StatusConflict},
+		{http.MethodGet, "/unknown", "", http.StatusNotFound},
+		{http.MethodGet, "/", "", http.StatusOK},
+	} {
+		if code, body := do(tt.method, tt.path, tt.body); code != tt.want {
+			t.Errorf("%s %s = %d %s, want %d", tt.method, tt.path, code, body, tt.want)
+		}
+	}
+}
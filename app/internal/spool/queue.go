@@ -0,0 +1,153 @@
+package spool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// entryExt は保存したペイロードのファイルの拡張子
+const entryExt = ".pb"
+
+// Queue はディレクトリに 1 ペイロード 1 ファイルで保存する FIFO のキュー。
+// 合計サイズが maxBytes を超えると古いものから削除する。再起動後も残ったファイルから再開できる
+type Queue struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	seq     uint64
+	entries []entry
+	bytes   int64
+	dropped int64
+}
+
+type entry struct {
+	name string
+	size int64
+}
+
+// OpenQueue は dir のキューを開く。dir が存在しない場合は作成する
+func OpenQueue(dir string, maxBytes int64) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+	q := &Queue{dir: dir, maxBytes: maxBytes}
+	for _, f := range files {
+		name := f.Name()
+		// 書き込み途中で終了した一時ファイルは捨てる
+		if strings.HasPrefix(name, ".tmp-") {
+			_ = os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, entryExt), 10, 64)
+		if err != nil || !strings.HasSuffix(name, entryExt) {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat spool entry: %w", err)
+		}
+		q.entries = append(q.entries, entry{name: name, size: info.Size()})
+		q.bytes += info.Size()
+		if seq >= q.seq {
+			q.seq = seq + 1
+		}
+	}
+	sort.Slice(q.entries, func(i, j int) bool { return q.entries[i].name < q.entries[j].name })
+	return q, nil
+}
+
+// Push は data を末尾に追加する。maxBytes を超えた場合は先頭から削除する
+func (q *Queue) Push(data []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	name := fmt.Sprintf("%020d%s", q.seq, entryExt)
+	tmp, err := os.CreateTemp(q.dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create spool entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write spool entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write spool entry: %w", err)
+	}
+	// rename で置くため、途中まで書いたファイルを読むことはない
+	if err := os.Rename(tmp.Name(), filepath.Join(q.dir, name)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to commit spool entry: %w", err)
+	}
+	q.seq++
+	q.entries = append(q.entries, entry{name: name, size: int64(len(data))})
+	q.bytes += int64(len(data))
+
+	for q.maxBytes > 0 && q.bytes > q.maxBytes && len(q.entries) > 0 {
+		q.removeLocked(q.entries[0].name)
+		q.dropped++
+	}
+	return nil
+}
+
+// Peek は先頭のペイロードとその名前を返す。空の場合は ok が false
+func (q *Queue) Peek() (data []byte, name string, ok bool, err error) {
+	q.mu.Lock()
+	if len(q.entries) == 0 {
+		q.mu.Unlock()
+		return nil, "", false, nil
+	}
+	name = q.entries[0].name
+	q.mu.Unlock()
+
+	data, err = os.ReadFile(filepath.Join(q.dir, name))
+	if err != nil {
+		return nil, name, true, fmt.Errorf("failed to read spool entry: %w", err)
+	}
+	return data, name, true, nil
+}
+
+// Remove は name のペイロードを削除する。既に削除されている場合は何もしない
+func (q *Queue) Remove(name string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.removeLocked(name)
+}
+
+func (q *Queue) removeLocked(name string) {
+	for i, e := range q.entries {
+		if e.name != name {
+			continue
+		}
+		_ = os.Remove(filepath.Join(q.dir, name))
+		q.bytes -= e.size
+		q.entries = append(q.entries[:i], q.entries[i+1:]...)
+		return
+	}
+}
+
+// QueueStats はキューの状態
+type QueueStats struct {
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+	// Dropped は容量を超えて削除した件数
+	Dropped int64 `json:"dropped"`
+}
+
+// Stats は現在の状態を返す
+func (q *Queue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return QueueStats{Entries: len(q.entries), Bytes: q.bytes, Dropped: q.dropped}
+}
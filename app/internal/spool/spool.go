@@ -0,0 +1,336 @@
+// Package spool はコレクター（otel-tui など）に接続できない間の OTLP エクスポートをディスクに保存し、
+// 接続が回復したら保存した順に再送する。
+//
+// OTLP/gRPC エクスポーターの gRPC クライアントにインターセプターとして組み込むため、
+// トレース・メトリクス・ログのいずれも、エクスポーターが送る protobuf のリクエストをそのまま保存する。
+// 送信に失敗したリクエストを保存している間は、順序を保つため新しいリクエストも送信せずに末尾へ追加する。
+// 保存したリクエストはエクスポーターには成功として返し、pipeline.Monitor には spooled として記録する。
+package spool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/fileexport"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/pipeline"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/response"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// ScopeName はスプールのメトリクスの計装スコープ名
+const ScopeName = "go-app/spool"
+
+// 既定値
+const (
+	DefaultMaxBytes      = 64 << 20
+	DefaultRetryInterval = 5 * time.Second
+	// resendTimeout は再送 1 件の制限時間
+	resendTimeout = 10 * time.Second
+)
+
+// Options はスプールの設定
+type Options struct {
+	// Dir はペイロードを保存するディレクトリ。シグナルごとにサブディレクトリを作成する
+	Dir string
+	// MaxBytes はシグナルごとの保存サイズの上限。超えた場合は古いものから削除する。0 の場合は DefaultMaxBytes
+	MaxBytes int64
+	// RetryInterval は再送を試みる間隔。0 の場合は DefaultRetryInterval
+	RetryInterval time.Duration
+}
+
+// Spooler は OTLP/gRPC の Export を横取りし、失敗したリクエストをディスクに保存して再送する
+type Spooler struct {
+	opts   Options
+	queues map[string]*signalQueue
+}
+
+// signalQueue は 1 つのシグナル（gRPC メソッド）のキュー
+type signalQueue struct {
+	signal     string
+	method     string
+	newRequest func() proto.Message
+	newReply   func() proto.Message
+	queue      *Queue
+	cc         atomic.Pointer[grpc.ClientConn]
+
+	// mu は送信と保存の順序を保つため、Export と再送を直列にする
+	mu       sync.Mutex
+	resent   atomic.Int64
+	rejected atomic.Int64
+}
+
+// New は opts.Dir の下にシグナルごとのキューを開く。前回の実行で残ったペイロードも再送の対象になる
+func New(opts Options) (*Spooler, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("spool directory is required")
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = DefaultRetryInterval
+	}
+
+	s := &Spooler{opts: opts, queues: make(map[string]*signalQueue)}
+	for _, sq := range []*signalQueue{
+		{
+			signal:     "traces",
+			method:     fileexport.TraceMethod,
+			newRequest: func() proto.Message { return &coltracepb.ExportTraceServiceRequest{} },
+			newReply:   func() proto.Message { return &coltracepb.ExportTraceServiceResponse{} },
+		},
+		{
+			signal:     "metrics",
+			method:     fileexport.MetricsMethod,
+			newRequest: func() proto.Message { return &colmetricspb.ExportMetricsServiceRequest{} },
+			newReply:   func() proto.Message { return &colmetricspb.ExportMetricsServiceResponse{} },
+		},
+		{
+			signal:     "logs",
+			method:     fileexport.LogsMethod,
+			newRequest: func() proto.Message { return &collogspb.ExportLogsServiceRequest{} },
+			newReply:   func() proto.Message { return &collogspb.ExportLogsServiceResponse{} },
+		},
+	} {
+		q, err := OpenQueue(filepath.Join(opts.Dir, sq.signal), opts.MaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		sq.queue = q
+		s.queues[sq.method] = sq
+	}
+	return s, nil
+}
+
+// DialOption は OTLP/gRPC エクスポーターに渡す gRPC のダイヤルオプションを返す
+//
+//	otlptracegrpc.New(ctx, otlptracegrpc.WithDialOption(spooler.DialOption()))
+func (s *Spooler) DialOption() grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(s.intercept)
+}
+
+type resendKey struct{}
+
+func (s *Spooler) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	sq, ok := s.queues[method]
+	// 再送自体は横取りしない
+	if !ok || ctx.Value(resendKey{}) != nil {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	sq.cc.Store(cc)
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+	// 未送信のペイロードがある間は、順序を保つため末尾に追加する
+	if sq.queue.Stats().Entries > 0 {
+		return sq.push(ctx, msg)
+	}
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if retryable(err) {
+		if perr := sq.push(ctx, msg); perr != nil {
+			return errors.Join(err, perr)
+		}
+		return nil
+	}
+	return err
+}
+
+// push は msg を末尾に保存し、エクスポートの結果を spooled として記録する
+func (sq *signalQueue) push(ctx context.Context, msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s payload: %w", sq.signal, err)
+	}
+	if err := sq.queue.Push(data); err != nil {
+		return err
+	}
+	pipeline.MarkSpooled(ctx)
+	return nil
+}
+
+// retryable はコレクターの停止や過負荷など、時間をおけば成功しうるエラーの場合に true を返す。
+// Canceled は呼び出し元（エクスポーター）が送信をやめた場合のため、保存しない
+func retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// Run は ctx が終了するまで、RetryInterval ごとに保存したペイロードを再送する
+func (s *Spooler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.RetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, sq := range s.queues {
+				sq.drain(ctx)
+			}
+		}
+	}
+}
+
+// drain は保存した順に再送し、再送できなくなった時点で止める。
+// 接続先が受け付けないペイロード（不正なデータなど）は再送しても成功しないため捨てる
+func (sq *signalQueue) drain(ctx context.Context) {
+	cc := sq.cc.Load()
+	if cc == nil {
+		// エクスポーターがまだ一度も送信していない
+		return
+	}
+	for ctx.Err() == nil {
+		sq.mu.Lock()
+		done := sq.resendOne(ctx, cc)
+		sq.mu.Unlock()
+		if done {
+			return
+		}
+	}
+}
+
+// resendOne は先頭のペイロードを 1 件再送する。続けて再送しない場合は true を返す
+func (sq *signalQueue) resendOne(ctx context.Context, cc *grpc.ClientConn) bool {
+	data, name, ok, err := sq.queue.Peek()
+	if !ok {
+		return true
+	}
+	req := sq.newRequest()
+	if err == nil {
+		err = proto.Unmarshal(data, req)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Discarding unreadable spooled payload", "signal", sq.signal, "entry", name, "error", err)
+		sq.queue.Remove(name)
+		sq.rejected.Add(1)
+		return false
+	}
+
+	callCtx, cancel := context.WithTimeout(context.WithValue(ctx, resendKey{}, true), resendTimeout)
+	defer cancel()
+	err = cc.Invoke(callCtx, sq.method, req, sq.newReply())
+	switch {
+	case ctx.Err() != nil:
+		// 停止中の再送の失敗は接続先の拒否ではないため、保存したまま次回に再送する
+		return true
+	case err == nil:
+		sq.queue.Remove(name)
+		sq.resent.Add(1)
+		return false
+	case retryable(err):
+		return true
+	default:
+		slog.WarnContext(ctx, "Discarding spooled payload rejected by the endpoint", "signal", sq.signal, "entry", name, "error", err)
+		sq.queue.Remove(name)
+		sq.rejected.Add(1)
+		return false
+	}
+}
+
+// SignalStatus は 1 つのシグナルのスプールの状態
+type SignalStatus struct {
+	Signal string `json:"signal"`
+	QueueStats
+	Resent   int64 `json:"resent"`
+	Rejected int64 `json:"rejected"`
+}
+
+// Status はシグナルごとのスプールの状態を返す
+func (s *Spooler) Status() []SignalStatus {
+	var statuses []SignalStatus
+	for _, signal := range []string{"traces", "metrics", "logs"} {
+		for _, sq := range s.queues {
+			if sq.signal != signal {
+				continue
+			}
+			statuses = append(statuses, SignalStatus{
+				Signal:     sq.signal,
+				QueueStats: sq.queue.Stats(),
+				Resent:     sq.resent.Load(),
+				Rejected:   sq.rejected.Load(),
+			})
+		}
+	}
+	return statuses
+}
+
+// AdminRoutes はスプールの状態を返す管理 API を返す
+//
+//	GET /  シグナルごとの保存件数・サイズ・削除件数・再送件数
+func (s *Spooler) AdminRoutes() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			response.Error(w, r, http.StatusMethodNotAllowed, nil)
+			return
+		}
+		response.JSON(w, r, http.StatusOK, s.Status())
+	})
+}
+
+// RegisterMetrics は meter にスプールの件数とサイズのメトリクスを登録する
+func (s *Spooler) RegisterMetrics(meter metric.Meter) error {
+	depth, err := meter.Int64ObservableUpDownCounter("exporter.spool.depth",
+		metric.WithDescription("Number of OTLP export requests waiting in the disk spool."),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		return fmt.Errorf("failed to create spool depth: %w", err)
+	}
+	size, err := meter.Int64ObservableUpDownCounter("exporter.spool.size",
+		metric.WithDescription("Total size of OTLP export requests waiting in the disk spool."),
+		metric.WithUnit("By"))
+	if err != nil {
+		return fmt.Errorf("failed to create spool size: %w", err)
+	}
+	dropped, err := meter.Int64ObservableCounter("exporter.spool.dropped",
+		metric.WithDescription("Number of spooled OTLP export requests discarded because the spool was full or the endpoint rejected them."),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		return fmt.Errorf("failed to create spool dropped counter: %w", err)
+	}
+	resent, err := meter.Int64ObservableCounter("exporter.spool.resent",
+		metric.WithDescription("Number of spooled OTLP export requests resent successfully."),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		return fmt.Errorf("failed to create spool resent counter: %w", err)
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, st := range s.Status() {
+			attrs := metric.WithAttributes(attribute.String("otel.signal", st.Signal))
+			o.ObserveInt64(depth, int64(st.Entries), attrs)
+			o.ObserveInt64(size, st.Bytes, attrs)
+			o.ObserveInt64(dropped, st.Dropped+st.Rejected, attrs)
+			o.ObserveInt64(resent, st.Resent, attrs)
+		}
+		return nil
+	}, depth, size, dropped, resent)
+	if err != nil {
+		return fmt.Errorf("failed to register spool callback: %w", err)
+	}
+	return nil
+}
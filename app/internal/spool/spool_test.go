@@ -0,0 +1,172 @@
+package spool
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// fakeTraceService は down の間 Unavailable を返し、それ以外は受信したリクエストを記録する
+type fakeTraceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+	down atomic.Bool
+
+	mu       sync.Mutex
+	received []string
+}
+
+func (f *fakeTraceService) Export(_ context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	if f.down.Load() {
+		return nil, status.Error(codes.Unavailable, "collector is down")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, rs := range req.GetResourceSpans() {
+		f.received = append(f.received, rs.GetSchemaUrl())
+	}
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func (f *fakeTraceService) Received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.received...)
+}
+
+func traceRequest(id string) *coltracepb.ExportTraceServiceRequest {
+	return &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{SchemaUrl: id}},
+	}
+}
+
+func TestSpooler_SpoolsAndResendsInOrder(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := &fakeTraceService{}
+	gs := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(gs, svc)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	s, err := New(Options{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		s.DialOption(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	client := coltracepb.NewTraceServiceClient(cc)
+	ctx := context.Background()
+
+	// 停止中の送信は成功として扱い、保存する
+	svc.down.Store(true)
+	for _, id := range []string{"1", "2"} {
+		if _, err := client.Export(ctx, traceRequest(id)); err != nil {
+			t.Fatalf("Export(%s) error = %v, want spooled", id, err)
+		}
+	}
+	// 回復後も、保存済みのものがある間は順序を保つため保存する
+	svc.down.Store(false)
+	if _, err := client.Export(ctx, traceRequest("3")); err != nil {
+		t.Fatalf("Export(3) error = %v", err)
+	}
+	if got := svc.Received(); len(got) != 0 {
+		t.Fatalf("received before resend = %v, want none", got)
+	}
+	traces := s.Status()[0]
+	if traces.Signal != "traces" || traces.Entries != 3 {
+		t.Fatalf("status = %+v, want 3 traces entries", traces)
+	}
+
+	for _, sq := range s.queues {
+		sq.drain(ctx)
+	}
+	got := svc.Received()
+	want := []string{"1", "2", "3"}
+	if len(got) != len(want) {
+		t.Fatalf("received = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("received = %v, want %v", got, want)
+		}
+	}
+	if traces := s.Status()[0]; traces.Entries != 0 || traces.Bytes != 0 || traces.Resent != 3 {
+		t.Errorf("status after resend = %+v", traces)
+	}
+
+	// 空になった後は直接送信する
+	if _, err := client.Export(ctx, traceRequest("4")); err != nil {
+		t.Fatalf("Export(4) error = %v", err)
+	}
+	if got := svc.Received(); len(got) != 4 || got[3] != "4" {
+		t.Errorf("received = %v, want 4 sent directly", got)
+	}
+
+	// 呼び出し元がキャンセルした送信は保存しない
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := client.Export(canceled, traceRequest("5")); status.Code(err) != codes.Canceled {
+		t.Errorf("Export(5) with canceled context error = %v, want Canceled", err)
+	}
+	if traces := s.Status()[0]; traces.Entries != 0 {
+		t.Errorf("status after canceled export = %+v, want no entries", traces)
+	}
+}
+
+func TestQueue_EvictsOldestAndReopens(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenQueue(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []string{"aaaa", "bbbb", "cccc"} {
+		if err := q.Push([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := q.Stats(); st.Entries != 2 || st.Bytes != 8 || st.Dropped != 1 {
+		t.Fatalf("stats = %+v, want 2 entries, 8 bytes, 1 dropped", st)
+	}
+
+	// 再起動後も残ったファイルから順に読める
+	q, err = OpenQueue(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"bbbb", "cccc"} {
+		data, name, ok, err := q.Peek()
+		if err != nil || !ok {
+			t.Fatalf("Peek() ok = %v, err = %v", ok, err)
+		}
+		if string(data) != want {
+			t.Errorf("Peek() = %q, want %q", data, want)
+		}
+		q.Remove(name)
+	}
+	if _, _, ok, _ := q.Peek(); ok {
+		t.Error("Peek() ok = true after draining, want false")
+	}
+	if err := q.Push([]byte("dddd")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _, _, _ := q.Peek(); string(data) != "dddd" {
+		t.Errorf("Peek() after reopen push = %q, want dddd", data)
+	}
+}
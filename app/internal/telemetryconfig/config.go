@@ -0,0 +1,129 @@
+// Package telemetryconfig は実行中に変更できるテレメトリの設定（サンプリング率・ログレベル・メトリクスのビュー）を
+// YAML ファイルから読み込み、ファイルの変更や SIGHUP で再起動せずに反映する。
+//
+//	sampling:
+//	  ratio: 0.5
+//	logging:
+//	  level: debug
+//	  components:
+//	    metrics: warn
+//	views:
+//	  - instrument: memory.usage
+//	    name: memory.usage.bytes
+package telemetryconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// Config はテレメトリの設定ファイルの内容。未指定の項目は起動時の設定のまま変更しない
+type Config struct {
+	Sampling SamplingConfig `yaml:"sampling" json:"sampling"`
+	Logging  LoggingConfig  `yaml:"logging" json:"logging"`
+	Views    []ViewConfig   `yaml:"views" json:"views"`
+}
+
+// SamplingConfig はトレースのサンプリングの設定。親スパンがある場合は親の判定に従う
+type SamplingConfig struct {
+	// Ratio はルートスパンをサンプリングする割合（0〜1）。未指定の場合は 1
+	Ratio *float64 `yaml:"ratio" json:"ratio,omitempty"`
+}
+
+// ratio は未指定の場合に 1 を返す
+func (c SamplingConfig) ratio() float64 {
+	if c.Ratio == nil {
+		return 1
+	}
+	return *c.Ratio
+}
+
+// LoggingConfig はログレベルの設定。項目を削除すると起動時のレベルに戻す
+type LoggingConfig struct {
+	Level      string            `yaml:"level" json:"level,omitempty"`
+	Components map[string]string `yaml:"components" json:"components,omitempty"`
+}
+
+// ViewConfig はメトリクスのビュー。Scope が空の場合はすべての計装スコープの Instrument に適用する
+type ViewConfig struct {
+	Instrument  string `yaml:"instrument" json:"instrument"`
+	Scope       string `yaml:"scope" json:"scope,omitempty"`
+	Name        string `yaml:"name" json:"name,omitempty"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	// Buckets はヒストグラムのバケット境界。昇順で指定する
+	Buckets []float64 `yaml:"buckets" json:"buckets,omitempty"`
+	// Drop を true にすると計測値を集計しない
+	Drop bool `yaml:"drop" json:"drop,omitempty"`
+}
+
+// Load は path の設定ファイルを読み込む。path が空の場合は空の設定を返す
+func Load(path string) (Config, error) {
+	if path == "" {
+		return Config{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read telemetry config: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse は YAML の data を解析して検証する
+func Parse(data []byte) (Config, error) {
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	// 空のファイルはすべて未指定として扱う
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("failed to parse telemetry config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Validate は設定の値を検証する。1 つでも不正な値があれば何も反映しない
+func (c Config) Validate() error {
+	if r := c.Sampling.ratio(); r < 0 || r > 1 {
+		return fmt.Errorf("sampling.ratio must be between 0 and 1, got %v", r)
+	}
+	if c.Logging.Level != "" {
+		if _, err := logging.ParseLevel(c.Logging.Level, slog.LevelInfo); err != nil {
+			return fmt.Errorf("invalid logging.level: %w", err)
+		}
+	}
+	for _, component := range sortedKeys(c.Logging.Components) {
+		if _, err := logging.ParseLevel(c.Logging.Components[component], slog.LevelInfo); err != nil {
+			return fmt.Errorf("invalid logging.components.%s: %w", component, err)
+		}
+	}
+	for i, v := range c.Views {
+		if v.Instrument == "" {
+			return fmt.Errorf("views[%d].instrument is required", i)
+		}
+		for j := 1; j < len(v.Buckets); j++ {
+			if v.Buckets[j] <= v.Buckets[j-1] {
+				return fmt.Errorf("views[%d].buckets must be in increasing order", i)
+			}
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
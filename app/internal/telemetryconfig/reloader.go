@@ -0,0 +1,258 @@
+package telemetryconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/logging"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/response"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName は再読み込みのスパンの計装スコープ名
+const ScopeName = "go-app/telemetryconfig"
+
+// DefaultWatchInterval は設定ファイルの変更を確認する既定の間隔
+const DefaultWatchInterval = 2 * time.Second
+
+// 再読み込みのきっかけ
+const (
+	TriggerFile   = "file"
+	TriggerSignal = "signal"
+	TriggerAdmin  = "admin"
+)
+
+// levelSource はログレベルの監査ログに記録する変更元
+const levelSource = "config"
+
+// Options は Reloader の設定
+type Options struct {
+	// Path は設定ファイルのパス
+	Path string
+	// Initial は起動時に Load で読み込んだ設定。Sampler と Views はこの設定で作成しておく
+	Initial Config
+	Sampler *Sampler
+	Views   *Views
+	// Levels を指定すると、設定ファイルのログレベルを反映する
+	Levels *logging.Levels
+	// WatchInterval は Watch が設定ファイルの変更を確認する間隔。0 の場合は DefaultWatchInterval
+	WatchInterval time.Duration
+	// TracerProvider は config.reload スパンの出力先。未指定の場合はグローバルな TracerProvider を使う。
+	// スパンには SamplingPriorityKey を付けるため、Sampler を使う TracerProvider ではサンプリング率にかかわらず記録する
+	TracerProvider trace.TracerProvider
+}
+
+// Reloader は設定ファイルを再読み込みし、サンプラー・ログレベル・ビューを差し替える
+type Reloader struct {
+	opts Options
+
+	mu      sync.Mutex
+	current Config
+	// seen は最後に読み込んだファイルの内容のハッシュ。失敗した内容を繰り返し読み込まないために使う
+	seen [sha256.Size]byte
+	last *ReloadResult
+}
+
+// ReloadResult は最後の再読み込みの結果
+type ReloadResult struct {
+	Time    time.Time `json:"time"`
+	Trigger string    `json:"trigger"`
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
+	// RestartRequired は作成済みのため変更したビューが反映されない計装（"<スコープ>/<名前>"）
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+// NewReloader は opts.Initial のログレベルを反映した Reloader を作成する
+func NewReloader(ctx context.Context, opts Options) *Reloader {
+	if opts.WatchInterval <= 0 {
+		opts.WatchInterval = DefaultWatchInterval
+	}
+	if opts.Sampler == nil {
+		opts.Sampler = NewSampler(opts.Initial.Sampling)
+	}
+	if opts.Views == nil {
+		opts.Views = NewViews(opts.Initial.Views)
+	}
+	r := &Reloader{opts: opts}
+	if data, err := os.ReadFile(opts.Path); err == nil {
+		r.seen = sha256.Sum256(data)
+	}
+	r.applyLogging(ctx, Config{}, opts.Initial)
+	r.current = opts.Initial
+	return r
+}
+
+// Reload は設定ファイルを読み込んで反映し、config.reload スパンに結果を記録する。
+// 読み込みや検証に失敗した場合は何も変更せずにエラーを返す
+func (r *Reloader) Reload(ctx context.Context, trigger string) error {
+	data, err := os.ReadFile(r.opts.Path)
+	if err != nil {
+		err = fmt.Errorf("failed to read telemetry config: %w", err)
+	}
+	return r.reload(ctx, trigger, data, err)
+}
+
+func (r *Reloader) reload(ctx context.Context, trigger string, data []byte, readErr error) error {
+	ctx, span := r.tracer().Start(ctx, "config.reload", trace.WithAttributes(
+		attribute.String("config.path", r.opts.Path),
+		attribute.String("config.trigger", trigger),
+		// 再読み込みはサンプリング率を 0 にした場合も記録する
+		SamplingPriorityKey.Int(1),
+	))
+	defer span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &ReloadResult{Time: time.Now(), Trigger: trigger}
+	r.last = result
+	if data != nil {
+		r.seen = sha256.Sum256(data)
+	}
+
+	cfg, err := Config{}, readErr
+	if err == nil {
+		cfg, err = Parse(data)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to reload telemetry config")
+		slog.ErrorContext(ctx, "Failed to reload telemetry config", "path", r.opts.Path, "trigger", trigger, "error", err)
+		return err
+	}
+
+	old := r.current
+	r.opts.Sampler.set(cfg.Sampling.ratio())
+	r.applyLogging(ctx, old, cfg)
+	result.RestartRequired = r.opts.Views.set(cfg.Views)
+	r.current = cfg
+	result.Success = true
+
+	span.SetAttributes(
+		attribute.Float64("config.sampling.ratio", cfg.Sampling.ratio()),
+		attribute.Int("config.views", len(cfg.Views)),
+		attribute.StringSlice("config.views.restart_required", result.RestartRequired),
+	)
+	if len(result.RestartRequired) > 0 {
+		slog.WarnContext(ctx, "Metric view changes for existing instruments take effect after restart", "instruments", result.RestartRequired)
+	}
+	slog.InfoContext(ctx, "Reloaded telemetry config", "path", r.opts.Path, "trigger", trigger, "sampling_ratio", cfg.Sampling.ratio())
+	return nil
+}
+
+// applyLogging は old から変わったログレベルだけを反映する。管理 API で変更したレベルは、
+// 設定ファイルの同じ項目を変更しない限り上書きしない
+func (r *Reloader) applyLogging(ctx context.Context, old, cfg Config) {
+	levels := r.opts.Levels
+	if levels == nil {
+		return
+	}
+	// 検証済みのためエラーにならない
+	if cfg.Logging.Level != old.Logging.Level {
+		if cfg.Logging.Level == "" {
+			levels.ResetLevel(ctx, levelSource)
+		} else {
+			level, _ := logging.ParseLevel(cfg.Logging.Level, slog.LevelInfo)
+			levels.SetLevel(ctx, level, levelSource)
+		}
+	}
+	for _, component := range sortedKeys(cfg.Logging.Components) {
+		value := cfg.Logging.Components[component]
+		if prev, ok := old.Logging.Components[component]; ok && prev == value {
+			continue
+		}
+		level, _ := logging.ParseLevel(value, slog.LevelInfo)
+		levels.SetComponentLevel(ctx, component, level, levelSource)
+	}
+	for _, component := range sortedKeys(old.Logging.Components) {
+		if _, ok := cfg.Logging.Components[component]; !ok {
+			levels.ResetComponentLevel(ctx, component, levelSource)
+		}
+	}
+}
+
+func (r *Reloader) tracer() trace.Tracer {
+	tp := r.opts.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(ScopeName)
+}
+
+// Watch は ctx が終了するまで WatchInterval ごとに設定ファイルを確認し、内容が変わっていれば再読み込みする。
+// ConfigMap のようにシンボリックリンクを差し替える更新にも対応するため、更新時刻ではなく内容で比較する
+func (r *Reloader) Watch(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.WatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			data, err := os.ReadFile(r.opts.Path)
+			if err != nil {
+				// 書き換えの途中でファイルが一時的に存在しない場合があるため、次の確認を待つ
+				continue
+			}
+			sum := sha256.Sum256(data)
+			r.mu.Lock()
+			changed := !bytes.Equal(sum[:], r.seen[:])
+			r.mu.Unlock()
+			if changed {
+				_ = r.reload(ctx, TriggerFile, data, nil)
+			}
+		}
+	}
+}
+
+// Status は管理 API で返す現在の設定と最後の再読み込みの結果
+type Status struct {
+	Path          string        `json:"path"`
+	Config        Config        `json:"config"`
+	SamplingRatio float64       `json:"sampling_ratio"`
+	LastReload    *ReloadResult `json:"last_reload,omitempty"`
+}
+
+// Status は現在の設定と最後の再読み込みの結果を返す
+func (r *Reloader) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Status{
+		Path:          r.opts.Path,
+		Config:        r.current,
+		SamplingRatio: r.opts.Sampler.Ratio(),
+		LastReload:    r.last,
+	}
+}
+
+// AdminRoutes は設定を参照・再読み込みする管理 API を返す
+//
+//	GET  /        現在の設定と最後の再読み込みの結果を返す
+//	POST /reload  設定ファイルを再読み込みする
+func (r *Reloader) AdminRoutes() http.Handler {
+	router := chi.NewRouter()
+	router.Get("/", func(w http.ResponseWriter, req *http.Request) {
+		response.JSON(w, req, http.StatusOK, r.Status())
+	})
+	router.Post("/reload", func(w http.ResponseWriter, req *http.Request) {
+		if err := r.Reload(req.Context(), TriggerAdmin); err != nil {
+			response.Error(w, req, http.StatusUnprocessableEntity, err)
+			return
+		}
+		response.JSON(w, req, http.StatusOK, r.Status())
+	})
+	return router
+}
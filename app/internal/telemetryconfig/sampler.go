@@ -0,0 +1,62 @@
+package telemetryconfig
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SamplingPriorityKey が 1 以上のスパンは、サンプリング率や親の判定にかかわらず記録する。
+// 設定の再読み込みのように、件数が少なく必ず残したいスパンに付ける
+const SamplingPriorityKey = attribute.Key("sampling.priority")
+
+// Sampler は設定の再読み込みで差し替えられるサンプラー。TracerProvider には起動時にこれを渡す
+type Sampler struct {
+	current atomic.Pointer[samplerState]
+}
+
+type samplerState struct {
+	ratio   float64
+	sampler sdktrace.Sampler
+}
+
+// NewSampler は cfg のサンプリング率で Sampler を作成する
+func NewSampler(cfg SamplingConfig) *Sampler {
+	s := &Sampler{}
+	s.set(cfg.ratio())
+	return s
+}
+
+// set はサンプリング率を変更する。実行中のスパンの判定には影響しない
+func (s *Sampler) set(ratio float64) {
+	s.current.Store(&samplerState{
+		ratio:   ratio,
+		sampler: sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio)),
+	})
+}
+
+// Ratio は現在のサンプリング率を返す
+func (s *Sampler) Ratio() float64 {
+	return s.current.Load().ratio
+}
+
+// ShouldSample は SamplingPriorityKey が 1 以上なら記録し、それ以外は現在のサンプラーに判定を委ねる
+func (s *Sampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	for _, kv := range p.Attributes {
+		if kv.Key == SamplingPriorityKey && kv.Value.AsInt64() > 0 {
+			return sdktrace.SamplingResult{
+				Decision:   sdktrace.RecordAndSample,
+				Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+			}
+		}
+	}
+	return s.current.Load().sampler.ShouldSample(p)
+}
+
+// Description はサンプラーの説明を返す
+func (s *Sampler) Description() string {
+	return fmt.Sprintf("Reloadable{%s}", s.current.Load().sampler.Description())
+}
@@ -0,0 +1,206 @@
+package telemetryconfig
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/logging"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func writeConfig(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloader_Reload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "telemetry.yaml")
+	writeConfig(t, path, "sampling:\n  ratio: 1\n")
+	initial, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	levels := logging.NewLevels(slog.LevelInfo)
+	sampler := NewSampler(initial.Sampling)
+	r := NewReloader(ctx, Options{
+		Path:           path,
+		Initial:        initial,
+		Sampler:        sampler,
+		Levels:         levels,
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+	})
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sampler))
+
+	writeConfig(t, path, `
+sampling:
+  ratio: 0
+logging:
+  level: debug
+  components:
+    metrics: warn
+`)
+	if err := r.Reload(ctx, TriggerSignal); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if _, span := tp.Tracer("test").Start(ctx, "root"); span.SpanContext().IsSampled() {
+		t.Error("root span is sampled after ratio 0")
+	}
+	if got := levels.Level(""); got != slog.LevelDebug {
+		t.Errorf("level = %v, want debug", got)
+	}
+	if got := levels.Level("metrics"); got != slog.LevelWarn {
+		t.Errorf("metrics level = %v, want warn", got)
+	}
+
+	// 不正な設定は何も反映しない
+	writeConfig(t, path, "sampling:\n  ratio: 2\nlogging:\n  level: error\n")
+	if err := r.Reload(ctx, TriggerSignal); err == nil {
+		t.Fatal("Reload() error = nil, want invalid ratio")
+	}
+	if got := sampler.Ratio(); got != 0 {
+		t.Errorf("ratio = %v, want 0 kept", got)
+	}
+	if got := levels.Level(""); got != slog.LevelDebug {
+		t.Errorf("level = %v, want debug kept", got)
+	}
+	if last := r.Status().LastReload; last == nil || last.Success || !strings.Contains(last.Error, "sampling.ratio") {
+		t.Errorf("last reload = %+v, want failure", last)
+	}
+
+	// 削除した項目は起動時のレベルに戻す
+	writeConfig(t, path, "")
+	if err := r.Reload(ctx, TriggerSignal); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := levels.Level("metrics"); got != slog.LevelInfo {
+		t.Errorf("metrics level = %v, want info after removal", got)
+	}
+	if got := sampler.Ratio(); got != 1 {
+		t.Errorf("ratio = %v, want 1 after removal", got)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("got %d config.reload spans, want 3", len(spans))
+	}
+	for i, want := range []codes.Code{codes.Unset, codes.Error, codes.Unset} {
+		if spans[i].Name() != "config.reload" || spans[i].Status().Code != want {
+			t.Errorf("span[%d] = %s %v, want config.reload %v", i, spans[i].Name(), spans[i].Status().Code, want)
+		}
+	}
+}
+
+func TestReloader_ReloadSpanAlwaysSampled(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "telemetry.yaml")
+	writeConfig(t, path, "sampling:\n  ratio: 0\n")
+	initial, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// config.reload スパンはサンプリング率 0 でも記録する
+	recorder := tracetest.NewSpanRecorder()
+	sampler := NewSampler(initial.Sampling)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sampler), sdktrace.WithSpanProcessor(recorder))
+	r := NewReloader(ctx, Options{Path: path, Initial: initial, Sampler: sampler, TracerProvider: tp})
+	if err := r.Reload(ctx, TriggerAdmin); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if _, span := tp.Tracer("test").Start(ctx, "root"); span.SpanContext().IsSampled() {
+		t.Error("root span is sampled with ratio 0")
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "config.reload" || !spans[0].SpanContext().IsSampled() {
+		t.Fatalf("spans = %v, want a sampled config.reload span", spans)
+	}
+}
+
+func TestReloader_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry.yaml")
+	writeConfig(t, path, "sampling:\n  ratio: 1\n")
+	sampler := NewSampler(SamplingConfig{})
+	r := NewReloader(context.Background(), Options{Path: path, Sampler: sampler, WatchInterval: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx)
+
+	writeConfig(t, path, "sampling:\n  ratio: 0.25\n")
+	deadline := time.Now().Add(2 * time.Second)
+	for sampler.Ratio() != 0.25 {
+		if time.Now().After(deadline) {
+			t.Fatal("config change was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if last := r.Status().LastReload; last == nil || last.Trigger != TriggerFile {
+		t.Errorf("last reload = %+v, want trigger file", last)
+	}
+}
+
+func TestViews_AppliesToNewInstruments(t *testing.T) {
+	ctx := context.Background()
+	views := NewViews(nil)
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithView(views.View()))
+	meter := mp.Meter("test")
+
+	existing, _ := meter.Int64Counter("existing")
+	pending := views.set([]ViewConfig{
+		{Instrument: "existing", Name: "renamed.existing"},
+		{Instrument: "later", Scope: "test", Name: "renamed.later"},
+	})
+	if strings.Join(pending, ",") != "test/existing" {
+		t.Errorf("restart required = %v, want [test/existing]", pending)
+	}
+
+	later, _ := meter.Int64Counter("later")
+	existing.Add(ctx, 1)
+	later.Add(ctx, 1)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			names = append(names, m.Name)
+		}
+	}
+	got := strings.Join(names, ",")
+	if !strings.Contains(got, "existing") || strings.Contains(got, "renamed.existing") || !strings.Contains(got, "renamed.later") {
+		t.Errorf("metrics = %v, want existing unchanged and later renamed", names)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"unknown field":    "sampling:\n  rate: 1\n",
+		"level":            "logging:\n  level: loud\n",
+		"component level":  "logging:\n  components:\n    metrics: loud\n",
+		"view instrument":  "views:\n  - name: x\n",
+		"buckets not sort": "views:\n  - instrument: x\n    buckets: [10, 5]\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse([]byte(data)); err == nil {
+				t.Error("Parse() error = nil, want error")
+			}
+		})
+	}
+}
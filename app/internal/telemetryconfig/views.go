@@ -0,0 +1,109 @@
+package telemetryconfig
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// Views は設定ファイルのビューを MeterProvider に適用する。
+// SDK は計装の作成時にビューを適用して集計を確定するため、再読み込みしたビューはそれ以降に作成される計装にだけ適用される。
+// 作成済みの計装に影響する変更は反映せず、再起動が必要なものとして報告する。
+//
+// 組み込みのビュー（server.Views() など）と同じ計装に一致する設定は、組み込みのビューのストリームに重ねて適用する。
+// MeterProvider にビューを別々に登録すると 1 つの計装から 2 つのストリームが作られるため、組み込みのビューは NewViews に渡す
+type Views struct {
+	rules atomic.Pointer[[]ViewConfig]
+	base  []sdkmetric.View
+
+	mu sync.Mutex
+	// created は作成済みの計装。キーは "<スコープ>/<名前>"
+	created map[string]sdkmetric.Instrument
+}
+
+// NewViews は base の組み込みのビューに rules を重ねて適用する Views を作成する
+func NewViews(rules []ViewConfig, base ...sdkmetric.View) *Views {
+	v := &Views{base: base, created: make(map[string]sdkmetric.Instrument)}
+	v.rules.Store(&rules)
+	return v
+}
+
+// View は MeterProvider に渡す sdkmetric.View を返す。組み込みのビューも含むため、MeterProvider には別に登録しない
+func (v *Views) View() sdkmetric.View {
+	return v.view
+}
+
+func (v *Views) view(inst sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+	v.mu.Lock()
+	v.created[inst.Scope.Name+"/"+inst.Name] = inst
+	v.mu.Unlock()
+
+	stream, matched := v.baseStream(inst)
+	if rule, ok := match(*v.rules.Load(), inst); ok {
+		return rule.apply(stream, inst), true
+	}
+	return stream, matched
+}
+
+// baseStream は inst に最初に一致する組み込みのビューのストリームを返す。
+// 一致しない場合は計装の名前・説明・単位を引き継いだストリームを返す
+func (v *Views) baseStream(inst sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+	for _, view := range v.base {
+		if s, ok := view(inst); ok {
+			return s, true
+		}
+	}
+	return sdkmetric.Stream{
+		Name:        inst.Name,
+		Description: inst.Description,
+		Unit:        inst.Unit,
+	}, false
+}
+
+// set は rules に差し替え、作成済みの計装のうちビューが変わるもの（再起動するまで反映されないもの）を返す
+func (v *Views) set(rules []ViewConfig) []string {
+	old := *v.rules.Swap(&rules)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var pending []string
+	for key, inst := range v.created {
+		before, _ := match(old, inst)
+		after, _ := match(rules, inst)
+		if !reflect.DeepEqual(before, after) {
+			pending = append(pending, key)
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+// match は inst に最初に一致するビューを返す
+func match(rules []ViewConfig, inst sdkmetric.Instrument) (ViewConfig, bool) {
+	for _, rule := range rules {
+		if rule.Instrument == inst.Name && (rule.Scope == "" || rule.Scope == inst.Scope.Name) {
+			return rule, true
+		}
+	}
+	return ViewConfig{}, false
+}
+
+// apply は s に設定を重ねたストリームを返す。未指定の項目は s の値を引き継ぐ
+func (c ViewConfig) apply(s sdkmetric.Stream, inst sdkmetric.Instrument) sdkmetric.Stream {
+	if c.Name != "" {
+		s.Name = c.Name
+	}
+	if c.Description != "" {
+		s.Description = c.Description
+	}
+	switch {
+	case c.Drop:
+		s.Aggregation = sdkmetric.AggregationDrop{}
+	case len(c.Buckets) > 0 && inst.Kind == sdkmetric.InstrumentKindHistogram:
+		s.Aggregation = sdkmetric.AggregationExplicitBucketHistogram{Boundaries: c.Buckets}
+	}
+	return s
+}
@@ -2,68 +2,56 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
-	"math/rand"
+	"net"
 	"net/http"
 	"os"
-	"sync"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/riandyrn/otelchi"
-	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/fault"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/fileexport"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/health"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/logging"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/middleware"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/pipeline"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/receiver"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/server"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/spool"
+	"github.com/Msksgm/curl-otel-nginx-web-app/internal/telemetryconfig"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/log/global"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/instrumentation"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
-	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
 )
 
-var (
-	tracer                 trace.Tracer
-	meter                  metric.Meter
-	requestCounter         metric.Int64Counter
-	itemsCounter           metric.Int64UpDownCounter
-	fanSpeedSubsciption    chan int64
-	speedGauge             metric.Int64Gauge
-	histogram              metric.Float64Histogram
-	memoryObservable       metric.Float64ObservableCounter
-	currentMemoryUsage     float64
-	connectionObservable   metric.Int64ObservableUpDownCounter
-	activeConnections      int64
-	activeConnectionsMutex sync.Mutex
-	heapObservable         metric.Int64ObservableGauge
-	currentHeapUsage       int64
-	heapUsageMutex         sync.Mutex
-)
-
-func newOTelTUIExporter(ctx context.Context) (*otlptrace.Exporter, error) {
-	// Get New OTel TUI endpoint from environment variable or use default
-	endpoint := os.Getenv("OTLP_ENDPOINT")
+func newOTelTUIExporter(ctx context.Context, endpoint string, dialOpts ...grpc.DialOption) (*otlptrace.Exporter, error) {
 	if endpoint == "" {
 		return nil, fmt.Errorf("OTLP_ENDPOINT environment variable is required")
 	}
 
-	log.Printf("Initializing OpenTelemetry with OTLP endpoint: %s", endpoint)
+	slog.InfoContext(ctx, "Initializing OpenTelemetry with OTLP endpoint", "endpoint", endpoint)
 
 	// Create OTLP trace exporter with New Relic configuration
 	exporter, err := otlptracegrpc.New(ctx,
 		otlptracegrpc.WithEndpoint(endpoint),
 		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithDialOption(dialOpts...),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
@@ -72,19 +60,18 @@ func newOTelTUIExporter(ctx context.Context) (*otlptrace.Exporter, error) {
 	return exporter, nil
 }
 
-func newOTelMetricExporter(ctx context.Context) (sdkmetric.Exporter, error) {
-	// Get OTLP endpoint from environment variable
-	endpoint := os.Getenv("OTLP_ENDPOINT")
+func newOTelMetricExporter(ctx context.Context, endpoint string, dialOpts ...grpc.DialOption) (sdkmetric.Exporter, error) {
 	if endpoint == "" {
 		return nil, fmt.Errorf("OTLP_ENDPOINT environment variable is required")
 	}
 
-	log.Printf("Initializing OpenTelemetry Metrics with OTLP endpoint: %s", endpoint)
+	slog.InfoContext(ctx, "Initializing OpenTelemetry Metrics with OTLP endpoint", "endpoint", endpoint)
 
 	// Create OTLP metric exporter
 	exporter, err := otlpmetricgrpc.New(ctx,
 		otlpmetricgrpc.WithEndpoint(endpoint),
 		otlpmetricgrpc.WithInsecure(),
+		otlpmetricgrpc.WithDialOption(dialOpts...),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create metric exporter: %w", err)
@@ -93,19 +80,18 @@ func newOTelMetricExporter(ctx context.Context) (sdkmetric.Exporter, error) {
 	return exporter, nil
 }
 
-func newOTelLogExporter(ctx context.Context) (sdklog.Exporter, error) {
-	// Get OTLP endpoint from environment variable
-	endpoint := os.Getenv("OTLP_ENDPOINT")
+func newOTelLogExporter(ctx context.Context, endpoint string, dialOpts ...grpc.DialOption) (sdklog.Exporter, error) {
 	if endpoint == "" {
 		return nil, fmt.Errorf("OTLP_ENDPOINT environment variable is required")
 	}
 
-	log.Printf("Initializing OpenTelemetry Log with OTLP endpoint: %s", endpoint)
+	slog.InfoContext(ctx, "Initializing OpenTelemetry Log with OTLP endpoint", "endpoint", endpoint)
 
 	// Create OTLP log exporter
 	exporter, err := otlploggrpc.New(ctx,
 		otlploggrpc.WithEndpoint(endpoint),
 		otlploggrpc.WithInsecure(),
+		otlploggrpc.WithDialOption(dialOpts...),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create metric exporter: %w", err)
@@ -125,442 +111,214 @@ func newResource() (*resource.Resource, error) {
 	)
 }
 
-func newTracerProvider(exp sdktrace.SpanExporter, res *resource.Resource) *sdktrace.TracerProvider {
+func newTracerProvider(monitor *pipeline.Monitor, cfg pipeline.BatchConfig, exp sdktrace.SpanExporter, res *resource.Resource, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
 	// Create TracerProvider
-	return sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exp),
+	return sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{
+		sdktrace.WithSpanProcessor(monitor.BatchSpanProcessor(exp, cfg)),
 		sdktrace.WithResource(res),
-	)
+	}, opts...)...)
 }
 
-func newMeterProvider(metricExporter sdkmetric.Exporter, res *resource.Resource) *sdkmetric.MeterProvider {
-	// task.duration ヒストグラムの名前を request.latency に変更するビュー
-	view := sdkmetric.NewView(sdkmetric.Instrument{
-		Name: "task.duration",
-		Scope: instrumentation.Scope{
-			Name: "go-app",
-		},
-	}, sdkmetric.Stream{Name: "request.latency"})
-
-	return sdkmetric.NewMeterProvider(
-		sdkmetric.WithView(view),
+func newMeterProvider(monitor *pipeline.Monitor, cfg pipeline.ReaderConfig, metricExporter sdkmetric.Exporter, res *resource.Resource, opts ...sdkmetric.Option) *sdkmetric.MeterProvider {
+	return sdkmetric.NewMeterProvider(append([]sdkmetric.Option{
 		sdkmetric.WithResource(res),
-		sdkmetric.WithReader(
-			sdkmetric.NewPeriodicReader(metricExporter,
-				// デモ目的で3sに設定（デフォルトは1m）
-				sdkmetric.WithInterval(3*time.Second)),
-		),
-	)
+		// 送信間隔はデモ目的で既定を 3s にしている（OTEL_METRIC_EXPORT_INTERVAL で変更できる）
+		sdkmetric.WithReader(monitor.PeriodicReader(metricExporter, cfg)),
+	}, opts...)...)
 }
 
-func newLoggerProvider(exp sdklog.Exporter, res *resource.Resource) *sdklog.LoggerProvider {
-	processor := sdklog.NewBatchProcessor(exp)
-	return sdklog.NewLoggerProvider(
+func newLoggerProvider(monitor *pipeline.Monitor, cfg pipeline.BatchConfig, exp sdklog.Exporter, res *resource.Resource, opts ...sdklog.LoggerProviderOption) *sdklog.LoggerProvider {
+	processor := monitor.BatchLogProcessor(exp, cfg)
+	return sdklog.NewLoggerProvider(append([]sdklog.LoggerProviderOption{
 		sdklog.WithResource(res),
 		sdklog.WithProcessor(processor),
-	)
-}
-
-func getHealtz(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	data, _ := json.Marshal(map[string]string{"status": "ok"})
-	w.Write(data)
-}
-
-func getRoot(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("Welcome to the chi HTTP server behind Nginx!\n"))
-}
-
-func getHello(w http.ResponseWriter, r *http.Request) {
-	// span の作成。作成次に属性を設定可能
-	ctx, span := tracer.Start(r.Context(), "getHello", trace.WithAttributes(attribute.String("hello", "world")))
-	// さらに属性を追加することも可能
-	span.SetAttributes(attribute.Bool("isTrue", true), attribute.String("stringAttr", "hi!"))
-	// 属性のキーは、事前に定義されたものも利用できる
-	myKey := attribute.Key("myCoolAttribute")
-	span.SetAttributes(myKey.String("a value"))
-	defer span.End()
-
-	// AddEvent により特定のタイミングで、Event を追加可能。mutex で排他処理をしているときや、特定の分岐に入る時などに利用できそう
-	span.AddEvent("Hello with AddEvent")
-
-	// メトリクスをカウント
-	if requestCounter != nil {
-		requestCounter.Add(r.Context(), 1, metric.WithAttributes(
-			attribute.String("endpoint", "/hello"),
-			attribute.String("method", r.Method),
-		))
-		slog.Info("Incremented request counter for /hello endpoint")
-	}
-
-	childHello(ctx)
-
-	name := r.URL.Query().Get("name")
-	if name == "" {
-		name = "World"
-	}
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	data, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("Hello, %s!", name)})
-	w.Write(data)
-}
-
-func childHello(ctx context.Context) {
-	_, childSpan := tracer.Start(ctx, "childHello")
-	defer childSpan.End()
-
-	childSpan.AddEvent("Hello with AddEvent from child", trace.WithAttributes(attribute.String("childEvent", "hello child")))
-	fmt.Println("This is a child function")
+	}, opts...)...)
 }
 
-func getUserByID(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	data, _ := json.Marshal(map[string]any{"id": id, "profile": map[string]any{"nickname": "guest", "created_at": time.Now().UTC()}})
-	w.Write(data)
+// fileSink は FILE_EXPORT_DIR が指定されている場合に、各プロバイダーに追加するファイルエクスポーターの処理。
+// パイプラインの計測（/debug/telemetry）は OTLP の送信だけを対象にするため、SDK のバッチ処理をそのまま使う
+type fileSink struct {
+	exporter *fileexport.Exporter
+	tracer   []sdktrace.TracerProviderOption
+	meter    []sdkmetric.Option
+	logger   []sdklog.LoggerProviderOption
 }
 
-func getError(w http.ResponseWriter, r *http.Request) {
-	// span の作成。作成次に属性を設定可能
-	_, span := tracer.Start(r.Context(), "getError")
-	defer span.End()
-
-	// ステータスにエラーを設定。設定すると、このスパンだけでなく、トレース全体がエラーとして扱われる
-	span.SetStatus(codes.Error, "Internal Server Error")
-	// Event にエラー情報を追加する。このメソッドだけでは、トレース全体のステータスは変わらない（厳密には、直前のスパンまでエラーステータスになる）ため、span.SetStatus と合わせて使う
-	span.RecordError(fmt.Errorf("err: Internal Server Error"))
-
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusInternalServerError)
-	data, _ := json.Marshal(map[string]string{
-		"error":   "Internal Server Error",
-		"message": "This is a sample 500 error endpoint for testing OpenTelemetry",
-	})
-	w.Write(data)
-}
-
-func addItem(w http.ResponseWriter, r *http.Request) {
-	ctx, span := tracer.Start(r.Context(), "addItem")
-	defer span.End()
-
-	// itemsCounterをインクリメント
-	if itemsCounter != nil {
-		itemsCounter.Add(ctx, 1)
-		slog.Info("Incremented items counter")
+// newFileSink は FILE_EXPORT_DIR に OTLP/JSON の JSON Lines を書き出すファイルエクスポーターを作成する。
+// FILE_EXPORT_MAX_BYTES / FILE_EXPORT_MAX_AGE でファイルを切り替え、FILE_EXPORT_GZIP=true で圧縮する
+func newFileSink(ctx context.Context, cfg pipeline.Config) (*fileSink, error) {
+	dir := os.Getenv("FILE_EXPORT_DIR")
+	if dir == "" {
+		return &fileSink{}, nil
+	}
+	opts := fileexport.Options{Dir: dir}
+	if v := os.Getenv("FILE_EXPORT_MAX_BYTES"); v != "" {
+		maxBytes, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse FILE_EXPORT_MAX_BYTES: %w", err)
+		}
+		opts.MaxBytes = maxBytes
+	}
+	if v := os.Getenv("FILE_EXPORT_MAX_AGE"); v != "" {
+		maxAge, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse FILE_EXPORT_MAX_AGE: %w", err)
+		}
+		opts.MaxAge = maxAge
 	}
+	opts.Gzip, _ = strconv.ParseBool(os.Getenv("FILE_EXPORT_GZIP"))
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	data, _ := json.Marshal(map[string]string{
-		"message": "Item added successfully",
-		"action":  "increment",
-	})
-	w.Write(data)
+	exporter, err := fileexport.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	spanExp, err := exporter.SpanExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metricExp, err := exporter.MetricExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logExp, err := exporter.LogExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Writing telemetry to files", "dir", dir, "gzip", opts.Gzip)
+
+	return &fileSink{
+		exporter: exporter,
+		tracer: []sdktrace.TracerProviderOption{sdktrace.WithBatcher(spanExp,
+			sdktrace.WithMaxQueueSize(cfg.Spans.MaxQueueSize),
+			sdktrace.WithMaxExportBatchSize(cfg.Spans.MaxExportBatchSize),
+			sdktrace.WithBatchTimeout(cfg.Spans.ScheduleDelay),
+			sdktrace.WithExportTimeout(cfg.Spans.ExportTimeout),
+		)},
+		meter: []sdkmetric.Option{sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExp,
+			sdkmetric.WithInterval(cfg.Metrics.Interval),
+			sdkmetric.WithTimeout(cfg.Metrics.Timeout),
+		))},
+		logger: []sdklog.LoggerProviderOption{sdklog.WithProcessor(sdklog.NewBatchProcessor(logExp,
+			sdklog.WithMaxQueueSize(cfg.Logs.MaxQueueSize),
+			sdklog.WithExportMaxBatchSize(cfg.Logs.MaxExportBatchSize),
+			sdklog.WithExportInterval(cfg.Logs.ScheduleDelay),
+			sdklog.WithExportTimeout(cfg.Logs.ExportTimeout),
+		))},
+	}, nil
 }
 
-func removeItem(w http.ResponseWriter, r *http.Request) {
-	ctx, span := tracer.Start(r.Context(), "removeItem")
-	defer span.End()
-
-	// itemsCounterをデクリメント
-	if itemsCounter != nil {
-		itemsCounter.Add(ctx, -1)
-		slog.Info("Decremented items counter")
+// Close はプロバイダーのシャットダウンで書き出した後に、書き込み中のファイルを閉じる
+func (f *fileSink) Close() error {
+	if f.exporter == nil {
+		return nil
 	}
-
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	data, _ := json.Marshal(map[string]string{
-		"message": "Item removed successfully",
-		"action":  "decrement",
-	})
-	w.Write(data)
+	return f.exporter.Close()
 }
 
-func getCPUFanSpeedHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, span := tracer.Start(r.Context(), "getCPUFanSpeed")
-	defer span.End()
-
-	// fanSpeedSubsciptionから最新の値を非ブロッキングで取得
-	var fanSpeed int64
-	select {
-	case speed, ok := <-fanSpeedSubsciption:
-		if ok {
-			fanSpeed = speed
-			// Gaugeメトリクスを記録
-			if speedGauge != nil {
-				speedGauge.Record(ctx, fanSpeed)
-				slog.Info("Recorded fan speed", "speed_rpm", fanSpeed)
-			}
-		} else {
-			// チャンネルがクローズされている場合はランダムな値を生成
-			fanSpeed = int64(1500 + rand.Intn(1000))
-			if speedGauge != nil {
-				speedGauge.Record(ctx, fanSpeed)
-			}
+// startReceiver は RECEIVER_ENABLED=true の場合に組み込みの OTLP レシーバーを起動し、
+// 保存先とアプリ自身から接続する gRPC のエンドポイントを返す
+func startReceiver(ctx context.Context) (*receiver.Store, string) {
+	if enabled, _ := strconv.ParseBool(os.Getenv("RECEIVER_ENABLED")); !enabled {
+		return nil, ""
+	}
+	grpcAddr := os.Getenv("RECEIVER_GRPC_ADDR")
+	if grpcAddr == "" {
+		grpcAddr = ":4317"
+	}
+	httpAddr := os.Getenv("RECEIVER_HTTP_ADDR")
+	if httpAddr == "" {
+		httpAddr = ":4318"
+	}
+	_, port, err := net.SplitHostPort(grpcAddr)
+	if err != nil {
+		log.Fatalf("failed to parse RECEIVER_GRPC_ADDR: %v", err)
+	}
+	var maxSpans int
+	if v := os.Getenv("RECEIVER_MAX_SPANS"); v != "" {
+		if maxSpans, err = strconv.Atoi(v); err != nil {
+			log.Fatalf("failed to parse RECEIVER_MAX_SPANS: %v", err)
 		}
-	default:
-		// チャンネルに値がない場合はランダムな値を生成
-		fanSpeed = int64(1500 + rand.Intn(1000))
-		if speedGauge != nil {
-			speedGauge.Record(ctx, fanSpeed)
+	}
+	var retention time.Duration
+	if v := os.Getenv("RECEIVER_RETENTION"); v != "" {
+		if retention, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("failed to parse RECEIVER_RETENTION: %v", err)
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	data, _ := json.Marshal(map[string]interface{}{
-		"fanSpeed": fanSpeed,
-		"unit":     "rpm",
-		"message":  "Current CPU fan speed",
-	})
-	w.Write(data)
-}
-
-func callExternalAPI(w http.ResponseWriter, r *http.Request) {
-	ctx, span := tracer.Start(r.Context(), "callExternalAPI")
-	defer span.End()
-
-	// 処理開始時刻を記録
-	startTime := time.Now()
-
-	// 外部APIコールをエミュレート（50ms～10秒のランダムな遅延、より分散させる）
-	// 50% : 50ms - 1秒 (高速レスポンス)
-	// 30% : 1秒 - 5秒 (中程度のレスポンス)
-	// 20% : 5秒 - 10秒 (遅いレスポンス)
-	var apiLatency time.Duration
-	randValue := rand.Float32()
-	if randValue < 0.5 {
-		// 50ms - 1000ms
-		apiLatency = time.Duration(50+rand.Intn(950)) * time.Millisecond
-	} else if randValue < 0.8 {
-		// 1秒 - 5秒
-		apiLatency = time.Duration(1000+rand.Intn(4000)) * time.Millisecond
-	} else {
-		// 5秒 - 10秒
-		apiLatency = time.Duration(5000+rand.Intn(5000)) * time.Millisecond
-	}
-
-	// スパンに属性を追加
-	span.SetAttributes(
-		attribute.String("api.endpoint", "https://api.example.com/data"),
-		attribute.String("api.method", "GET"),
-		attribute.Int64("api.latency_ms", int64(apiLatency.Milliseconds())),
-	)
-
-	// 外部APIコールの開始をイベントとして記録
-	span.AddEvent("External API call started", trace.WithAttributes(
-		attribute.String("api.url", "https://api.example.com/data"),
-	))
-
-	// 外部APIコールをエミュレート
-	time.Sleep(apiLatency)
-
-	// 外部APIコールの完了をイベントとして記録
-	span.AddEvent("External API call completed", trace.WithAttributes(
-		attribute.Int("api.status_code", 200),
-	))
-
-	// 処理時間を計測
-	duration := time.Since(startTime).Seconds()
-
-	// ヒストグラムメトリクスに記録
-	if histogram != nil {
-		histogram.Record(ctx, duration, metric.WithAttributes(
-			attribute.String("api.endpoint", "external_api"),
-			attribute.Int("api.status_code", 200),
-		))
-		slog.Info("Recorded API call duration", "duration_seconds", duration)
-	}
-
-	// レスポンスを返す
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	data, _ := json.Marshal(map[string]interface{}{
-		"message":     "External API call completed successfully",
-		"duration_ms": apiLatency.Milliseconds(),
-		"status":      "success",
-	})
-	w.Write(data)
-}
-
-func getMemoryMetrics(w http.ResponseWriter, r *http.Request) {
-	_, span := tracer.Start(r.Context(), "getMemoryMetrics")
-	defer span.End()
-
-	// 現在のメモリ使用量を返す（ObservableCounterによって自動的に収集されている値）
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	data, _ := json.Marshal(map[string]interface{}{
-		"current_memory_bytes": currentMemoryUsage,
-		"unit":                 "bytes",
-		"message":              "Current memory usage tracked by Observable Counter",
-	})
-	w.Write(data)
-}
-
-func getConnectionMetrics(w http.ResponseWriter, r *http.Request) {
-	_, span := tracer.Start(r.Context(), "getConnectionMetrics")
-	defer span.End()
-
-	// 現在のアクティブコネクション数を返す
-	activeConnectionsMutex.Lock()
-	connections := activeConnections
-	activeConnectionsMutex.Unlock()
-
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	data, _ := json.Marshal(map[string]interface{}{
-		"active_connections": connections,
-		"unit":               "connections",
-		"message":            "Active connections tracked by Observable UpDownCounter",
-	})
-	w.Write(data)
-}
-
-func simulateConnect(w http.ResponseWriter, r *http.Request) {
-	_, span := tracer.Start(r.Context(), "simulateConnect")
-	defer span.End()
-
-	// コネクションを増やす
-	activeConnectionsMutex.Lock()
-	activeConnections++
-	connections := activeConnections
-	activeConnectionsMutex.Unlock()
-
-	slog.Info("Connection opened", "total_connections", connections)
-
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	data, _ := json.Marshal(map[string]interface{}{
-		"action":             "connect",
-		"active_connections": connections,
-		"message":            "Connection opened",
-	})
-	w.Write(data)
+	store := receiver.NewStore(receiver.StoreOptions{MaxSpans: maxSpans, Retention: retention})
+	rcv := receiver.New(store)
+	go func() {
+		if err := rcv.ServeGRPC(ctx, grpcAddr); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	go func() {
+		slog.InfoContext(ctx, "Starting OTLP HTTP receiver", "addr", httpAddr)
+		log.Fatal(http.ListenAndServe(httpAddr, rcv.Routes()))
+	}()
+	return store, net.JoinHostPort("localhost", port)
 }
 
-func simulateDisconnect(w http.ResponseWriter, r *http.Request) {
-	_, span := tracer.Start(r.Context(), "simulateDisconnect")
-	defer span.End()
-
-	// コネクションを減らす
-	activeConnectionsMutex.Lock()
-	if activeConnections > 0 {
-		activeConnections--
+// newSpooler は SPOOL_DIR が指定されている場合にエクスポートのスプールを作成する。
+// SPOOL_MAX_BYTES はシグナルごとの保存サイズの上限、SPOOL_RETRY_INTERVAL は再送を試みる間隔
+func newSpooler() (*spool.Spooler, error) {
+	dir := os.Getenv("SPOOL_DIR")
+	if dir == "" {
+		return nil, nil
 	}
-	connections := activeConnections
-	activeConnectionsMutex.Unlock()
-
-	slog.Info("Connection closed", "total_connections", connections)
-
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	data, _ := json.Marshal(map[string]interface{}{
-		"action":             "disconnect",
-		"active_connections": connections,
-		"message":            "Connection closed",
-	})
-	w.Write(data)
-}
-
-func getHeapMetrics(w http.ResponseWriter, r *http.Request) {
-	_, span := tracer.Start(r.Context(), "getHeapMetrics")
-	defer span.End()
-
-	// 現在のヒープメモリ使用量を返す
-	heapUsageMutex.Lock()
-	heapUsage := currentHeapUsage
-	heapUsageMutex.Unlock()
-
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	data, _ := json.Marshal(map[string]interface{}{
-		"heap_bytes": heapUsage,
-		"heap_mb":    float64(heapUsage) / (1024 * 1024),
-		"unit":       "bytes",
-		"message":    "Heap memory usage tracked by Observable Gauge",
-	})
-	w.Write(data)
-}
-
-func allocateMemory(w http.ResponseWriter, r *http.Request) {
-	_, span := tracer.Start(r.Context(), "allocateMemory")
-	defer span.End()
-
-	// メモリを割り当てる（10MB〜50MBのランダムな量）
-	allocation := int64(10*1024*1024) + int64(rand.Intn(40*1024*1024))
-
-	heapUsageMutex.Lock()
-	currentHeapUsage += allocation
-	if currentHeapUsage > 500*1024*1024 {
-		currentHeapUsage = 500 * 1024 * 1024
+	opts := spool.Options{Dir: dir}
+	if v := os.Getenv("SPOOL_MAX_BYTES"); v != "" {
+		maxBytes, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SPOOL_MAX_BYTES: %w", err)
+		}
+		opts.MaxBytes = maxBytes
 	}
-	heapUsage := currentHeapUsage
-	heapUsageMutex.Unlock()
-
-	slog.Info("Memory allocated",
-		"allocated_mb", float64(allocation)/(1024*1024),
-		"total_heap_mb", float64(heapUsage)/(1024*1024))
-
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	data, _ := json.Marshal(map[string]interface{}{
-		"action":        "allocate",
-		"allocated_mb":  float64(allocation) / (1024 * 1024),
-		"total_heap_mb": float64(heapUsage) / (1024 * 1024),
-		"message":       "Memory allocated",
-	})
-	w.Write(data)
-}
-
-func freeMemory(w http.ResponseWriter, r *http.Request) {
-	_, span := tracer.Start(r.Context(), "freeMemory")
-	defer span.End()
-
-	// メモリを解放する（10MB〜50MBのランダムな量）
-	deallocation := int64(10*1024*1024) + int64(rand.Intn(40*1024*1024))
-
-	heapUsageMutex.Lock()
-	currentHeapUsage -= deallocation
-	if currentHeapUsage < 10*1024*1024 {
-		currentHeapUsage = 10 * 1024 * 1024
+	if v := os.Getenv("SPOOL_RETRY_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SPOOL_RETRY_INTERVAL: %w", err)
+		}
+		opts.RetryInterval = interval
 	}
-	heapUsage := currentHeapUsage
-	heapUsageMutex.Unlock()
-
-	slog.Info("Memory freed",
-		"freed_mb", float64(deallocation)/(1024*1024),
-		"total_heap_mb", float64(heapUsage)/(1024*1024))
-
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	data, _ := json.Marshal(map[string]interface{}{
-		"action":        "free",
-		"freed_mb":      float64(deallocation) / (1024 * 1024),
-		"total_heap_mb": float64(heapUsage) / (1024 * 1024),
-		"message":       "Memory freed",
-	})
-	w.Write(data)
+	return spool.New(opts)
 }
 
 func main() {
 	// Initialize OpenTelemetry
 	ctx := context.Background()
 
-	exp, err := newOTelTUIExporter(ctx)
+	// 外部の otel-tui を使わない場合は、組み込みの OTLP レシーバーを起動する
+	telemetryStore, receiverEndpoint := startReceiver(ctx)
+	// OTLP_ENDPOINT が未指定の場合は、アプリ自身のテレメトリも組み込みのレシーバーに送信する
+	otlpEndpoint := os.Getenv("OTLP_ENDPOINT")
+	if otlpEndpoint == "" {
+		otlpEndpoint = receiverEndpoint
+	}
+
+	// SPOOL_DIR を指定すると、送信に失敗したペイロードをディスクに保存し、接続が回復したら再送する
+	spooler, err := newSpooler()
+	if err != nil {
+		log.Fatalf("failed to create spool: %v", err)
+	}
+	var dialOpts []grpc.DialOption
+	if spooler != nil {
+		dialOpts = append(dialOpts, spooler.DialOption())
+		go spooler.Run(ctx)
+	}
+
+	exp, err := newOTelTUIExporter(ctx, otlpEndpoint, dialOpts...)
 	if err != nil {
 		log.Fatalf("failed to create exporter: %v", err)
 	}
 
-	metricExp, err := newOTelMetricExporter(ctx)
+	metricExp, err := newOTelMetricExporter(ctx, otlpEndpoint, dialOpts...)
 	if err != nil {
 		log.Fatalf("failed to create metric exporter: %v", err)
 	}
 
-	logExp, err := newOTelLogExporter(ctx)
+	logExp, err := newOTelLogExporter(ctx, otlpEndpoint, dialOpts...)
 	if err != nil {
 		log.Fatalf("failed to create log exporter: %v", err)
 	}
@@ -570,226 +328,290 @@ func main() {
 		log.Fatalf("failed to create resource: %v", err)
 	}
 
-	tp := newTracerProvider(exp, res)
+	// パイプライン自体の計測。SDK のエラーは OTEL_ERROR_LOG_INTERVAL（既定 1m）に 1 回に抑えて slog に出力する
+	monitorOpts := pipeline.Options{}
+	if v := os.Getenv("OTEL_ERROR_LOG_INTERVAL"); v != "" {
+		if monitorOpts.ErrorInterval, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("failed to parse OTEL_ERROR_LOG_INTERVAL: %v", err)
+		}
+	}
+	monitor := pipeline.New(monitorOpts)
+	// バッチ処理と送信間隔の設定（OTEL_BSP_* / OTEL_BLRP_* / OTEL_METRIC_EXPORT_*）
+	pipelineCfg, err := pipeline.ConfigFromEnv(os.Getenv)
+	if err != nil {
+		log.Fatalf("failed to load pipeline config: %v", err)
+	}
+	slog.InfoContext(ctx, "Telemetry pipeline config", "spans", pipelineCfg.Spans, "logs", pipelineCfg.Logs, "metrics", pipelineCfg.Metrics)
+	otel.SetErrorHandler(monitor)
+
+	files, err := newFileSink(ctx, pipelineCfg)
+	if err != nil {
+		log.Fatalf("failed to create file exporter: %v", err)
+	}
+	// defer は逆順に実行されるため、各プロバイダーのシャットダウンの後にファイルを閉じる
+	defer func() {
+		if err := files.Close(); err != nil {
+			slog.ErrorContext(ctx, "Failed to close export files", "error", err)
+		}
+	}()
+
+	// TELEMETRY_CONFIG の設定ファイル（サンプリング率・ログレベル・ビュー）は、変更や SIGHUP で再起動せずに反映する。
+	// サンプラーとビューは再読み込みで差し替えられるものをプロバイダーに渡す
+	telemetryCfgPath := os.Getenv("TELEMETRY_CONFIG")
+	telemetryCfg, err := telemetryconfig.Load(telemetryCfgPath)
+	if err != nil {
+		log.Fatalf("failed to load telemetry config: %v", err)
+	}
+	sampler := telemetryconfig.NewSampler(telemetryCfg.Sampling)
+	// 組み込みのビュー（server.Views）も設定のビューと合わせて 1 つのビューとして登録する
+	views := telemetryconfig.NewViews(telemetryCfg.Views, server.Views()...)
+
+	tp := newTracerProvider(monitor, pipelineCfg.Spans, exp, res, append(files.tracer, sdktrace.WithSampler(sampler))...)
 
 	defer func() { _ = tp.Shutdown(ctx) }()
 
 	otel.SetTracerProvider(tp)
 	// 伝搬を設定。nginx や他サービスとのトレースIDの受け渡しに利用できる
 	otel.SetTextMapPropagator(propagation.TraceContext{})
-	mp := newMeterProvider(metricExp, res)
+	mp := newMeterProvider(monitor, pipelineCfg.Metrics, metricExp, res, append(files.meter, sdkmetric.WithView(views.View()))...)
 	defer func() {
-		log.Printf("Shutting down meter provider...")
+		slog.InfoContext(ctx, "Shutting down meter provider...")
 		if err := mp.Shutdown(ctx); err != nil {
 			log.Fatalf("failed to shutdown meter provider: %v", err)
 		}
-		log.Printf("Meter provider shutdown complete")
+		slog.InfoContext(ctx, "Meter provider shutdown complete")
 	}()
 	otel.SetMeterProvider(mp)
+	if err := monitor.RegisterMetrics(mp.Meter(pipeline.ScopeName)); err != nil {
+		log.Fatalf("failed to register pipeline metrics: %v", err)
+	}
+	if spooler != nil {
+		if err := spooler.RegisterMetrics(mp.Meter(spool.ScopeName)); err != nil {
+			log.Fatalf("failed to register spool metrics: %v", err)
+		}
+	}
 
 	// ログプロバイダーを初期化
-	lp := newLoggerProvider(logExp, res)
+	lp := newLoggerProvider(monitor, pipelineCfg.Logs, logExp, res, files.logger...)
 	defer func() {
-		log.Printf("Shutting down logger provider...")
+		slog.InfoContext(ctx, "Shutting down logger provider...")
 		if err := lp.Shutdown(ctx); err != nil {
 			log.Fatalf("failed to shutdown logger provider: %v", err)
 		}
-		log.Printf("Logger provider shutdown complete")
+		slog.InfoContext(ctx, "Logger provider shutdown complete")
 	}()
+	global.SetLoggerProvider(lp)
 
-	// slogとOpenTelemetryのブリッジを設定
-	logger := otelslog.NewLogger("go-app", otelslog.WithLoggerProvider(lp))
-	slog.SetDefault(logger)
-
-	tracer = tp.Tracer("go-app")
-
-	// メトリクスカウンターを作成
-	meter = otel.Meter("go-app")
-	requestCounter, err = meter.Int64Counter(
-		"api.counter",
-		metric.WithDescription("Number of API calls"),
-		metric.WithUnit("{call}"),
-	)
+	// slogとOpenTelemetryのブリッジを設定。docker compose logs でも確認できるようにコンソールにも出力する
+	// LOG_LEVEL は実行時に変更できるレベル、LOG_OTLP_LEVEL / LOG_CONSOLE_LEVEL は出力先ごとの下限
+	logLevel, err := logging.ParseLevel(os.Getenv("LOG_LEVEL"), slog.LevelInfo)
 	if err != nil {
-		log.Fatalf("failed to create request counter: %v", err)
+		log.Fatalf("failed to parse LOG_LEVEL: %v", err)
 	}
-	log.Printf("Request counter created successfully")
-
-	itemsCounter, err = meter.Int64UpDownCounter(
-		"items.counter",
-		metric.WithDescription("Number of items."),
-		metric.WithUnit("{item}"),
-	)
+	componentLevels, err := logging.ParseComponentLevels(os.Getenv("LOG_COMPONENT_LEVELS"))
 	if err != nil {
-		log.Fatalf("failed to create items counter: %v", err)
+		log.Fatalf("failed to parse LOG_COMPONENT_LEVELS: %v", err)
 	}
-
-	speedGauge, err = meter.Int64Gauge(
-		"cpu.fan.speed",
-		metric.WithDescription("CPU Fan Speed"),
-		metric.WithUnit("{rpm}"),
-	)
+	otlpLevel, err := logging.ParseLevel(os.Getenv("LOG_OTLP_LEVEL"), slog.LevelDebug)
 	if err != nil {
-		log.Fatalf("failed to create speed gauge: %v", err)
+		log.Fatalf("failed to parse LOG_OTLP_LEVEL: %v", err)
 	}
-
-	getCPUFanSpeed := func() int64 {
-		// デモンストレーション目的でランダムなファン速度を生成します
-		// 実際のアプリケーションでは、これを実際のファン速度を取得するように置き換えてください
-		return int64(1500 + rand.Intn(1000))
+	consoleLevel, err := logging.ParseLevel(os.Getenv("LOG_CONSOLE_LEVEL"), slog.LevelDebug)
+	if err != nil {
+		log.Fatalf("failed to parse LOG_CONSOLE_LEVEL: %v", err)
+	}
+	levels := logging.NewLevels(logLevel)
+	logger, err := logging.New(logging.Options{
+		Name:           server.ScopeName,
+		LoggerProvider: lp,
+		OTLPLevel:      otlpLevel,
+		Console:        os.Stdout,
+		ConsoleFormat:  os.Getenv("LOG_FORMAT"),
+		ConsoleLevel:   consoleLevel,
+		Levels:         levels,
+	})
+	if err != nil {
+		log.Fatalf("failed to create logger: %v", err)
+	}
+	slog.SetDefault(logger)
+	for component, level := range componentLevels {
+		levels.SetComponentLevel(ctx, component, level, "env")
 	}
 
-	fanSpeedSubsciption = make(chan int64, 1)
+	// SIGUSR1 でログレベルを Debug と切り替える前のレベルの間で切り替える
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
 	go func() {
-		defer close(fanSpeedSubsciption)
-
-		for idx := 0; idx < 5; idx++ {
-			time.Sleep(time.Duration(rand.Intn(3)) * time.Second)
-			fanSpeed := getCPUFanSpeed()
-			fanSpeedSubsciption <- fanSpeed
+		for range usr1 {
+			levels.Toggle(ctx, "signal")
 		}
 	}()
 
-	histogram, err = meter.Float64Histogram(
-		"task.duration",
-		metric.WithDescription("The duration of task execution."),
-		metric.WithUnit("s"),
-	)
-	if err != nil {
-		panic(err)
-	}
-
-	// Float64ObservableCounterを作成
-	memoryObservable, err = meter.Float64ObservableCounter(
-		"memory.usage",
-		metric.WithDescription("Current memory usage in bytes"),
-		metric.WithUnit("By"),
-		metric.WithFloat64Callback(func(ctx context.Context, o metric.Float64Observer) error {
-			// デモンストレーション目的でランダムな値を生成
-			// 実際のアプリケーションでは、実際のメモリ使用量を取得するように置き換えてください
-			// 100MB〜500MBの間でランダムに増加する値をシミュレート
-			currentMemoryUsage = float64(100*1024*1024) + float64(rand.Intn(400*1024*1024))
-			o.Observe(currentMemoryUsage, metric.WithAttributes(
-				attribute.String("memory.type", "heap"),
-			))
-			log.Printf("Observable Counter reported memory usage: %.2f MB", currentMemoryUsage/(1024*1024))
-			return nil
-		}),
-	)
-	if err != nil {
-		log.Fatalf("failed to create memory observable counter: %v", err)
-	}
-	log.Printf("Memory observable counter created successfully")
-
-	// Int64ObservableUpDownCounterを作成
-	connectionObservable, err = meter.Int64ObservableUpDownCounter(
-		"active.connections",
-		metric.WithDescription("Number of active connections"),
-		metric.WithUnit("{connection}"),
-		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
-			activeConnectionsMutex.Lock()
-			connections := activeConnections
-			activeConnectionsMutex.Unlock()
-
-			o.Observe(connections, metric.WithAttributes(
-				attribute.String("connection.type", "http"),
-			))
-			log.Printf("Observable UpDownCounter reported active connections: %d", connections)
-			return nil
-		}),
-	)
-	if err != nil {
-		log.Fatalf("failed to create connection observable updown counter: %v", err)
-	}
-	log.Printf("Connection observable updown counter created successfully")
-
-	// バックグラウンドでコネクション数をシミュレート
-	go func() {
-		for {
-			time.Sleep(time.Duration(2+rand.Intn(3)) * time.Second)
-			activeConnectionsMutex.Lock()
-			// -5〜+10の間でランダムに変動
-			change := int64(rand.Intn(16) - 5)
-			activeConnections += change
-			if activeConnections < 0 {
-				activeConnections = 0
+	var reloader *telemetryconfig.Reloader
+	if telemetryCfgPath != "" {
+		reloadOpts := telemetryconfig.Options{
+			Path:    telemetryCfgPath,
+			Initial: telemetryCfg,
+			Sampler: sampler,
+			Views:   views,
+			Levels:  levels,
+		}
+		if v := os.Getenv("TELEMETRY_CONFIG_WATCH_INTERVAL"); v != "" {
+			if reloadOpts.WatchInterval, err = time.ParseDuration(v); err != nil {
+				log.Fatalf("failed to parse TELEMETRY_CONFIG_WATCH_INTERVAL: %v", err)
 			}
-			if activeConnections > 100 {
-				activeConnections = 100
+		}
+		reloader = telemetryconfig.NewReloader(ctx, reloadOpts)
+		go reloader.Watch(ctx)
+
+		// SIGHUP で設定ファイルを再読み込みする
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				_ = reloader.Reload(ctx, telemetryconfig.TriggerSignal)
 			}
-			activeConnectionsMutex.Unlock()
-			log.Printf("Simulated connection change: %+d, total: %d", change, activeConnections)
+		}()
+	}
+
+	headersEnabled, _ := strconv.ParseBool(os.Getenv("FAULT_HEADERS_ENABLED"))
+
+	// アクセスログ。ACCESS_LOG_SAMPLE_RATE は 2xx / 3xx を記録する割合、
+	// ACCESS_LOG_EXCLUDE_PATHS はカンマ区切りの除外パス（未指定時は /healthz とプローブ）
+	accessLogSampleRate := 1.0
+	if v := os.Getenv("ACCESS_LOG_SAMPLE_RATE"); v != "" {
+		accessLogSampleRate, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			log.Fatalf("failed to parse ACCESS_LOG_SAMPLE_RATE: %v", err)
 		}
-	}()
+		if accessLogSampleRate < 0 || accessLogSampleRate > 1 {
+			log.Fatalf("ACCESS_LOG_SAMPLE_RATE must be between 0 and 1: %v", accessLogSampleRate)
+		}
+	}
+	accessLogExcludePaths := append([]string{"/healthz"}, health.Paths()...)
+	if v, ok := os.LookupEnv("ACCESS_LOG_EXCLUDE_PATHS"); ok {
+		accessLogExcludePaths = splitList(v)
+	}
 
-	// Int64ObservableGaugeを作成
-	heapObservable, err = meter.Int64ObservableGauge(
-		"memory.heap",
-		metric.WithDescription("Heap memory usage in bytes"),
-		metric.WithUnit("By"),
-		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
-			heapUsageMutex.Lock()
-			heapUsage := currentHeapUsage
-			heapUsageMutex.Unlock()
-
-			o.Observe(heapUsage, metric.WithAttributes(
-				attribute.String("memory.state", "used"),
-			))
-			log.Printf("Observable Gauge reported heap usage: %.2f MB", float64(heapUsage)/(1024*1024))
-			return nil
-		}),
-	)
+	// トレース ID を返す設定。TRACE_RESPONSE_HEADERS はカンマ区切りのヘッダー（未指定時は traceresponse,X-Trace-Id、
+	// 空文字でヘッダーを付与しない）、TRACE_RESPONSE_EXCLUDE_PATHS はトレース ID を返さないパス、
+	// TRACE_VIEWER_URL はエラーボディに含めるトレースビューアーの URL
+	traceResponse := middleware.TraceResponseOptions{
+		ExcludePaths: splitList(os.Getenv("TRACE_RESPONSE_EXCLUDE_PATHS")),
+		ViewerURL:    os.Getenv("TRACE_VIEWER_URL"),
+	}
+	if v, ok := os.LookupEnv("TRACE_RESPONSE_HEADERS"); ok {
+		traceResponse.Headers = append([]string{}, splitList(v)...)
+	}
+
+	// nginx のように X-Forwarded-For を付与するプロキシのアドレス範囲（カンマ区切りの CIDR）
+	trustedProxies, err := middleware.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
 	if err != nil {
-		log.Fatalf("failed to create heap observable gauge: %v", err)
+		log.Fatalf("failed to parse TRUSTED_PROXIES: %v", err)
 	}
-	log.Printf("Heap observable gauge created successfully")
 
-	// ヒープメモリ使用量の初期値を設定（50MB〜200MBの範囲）
-	currentHeapUsage = int64(50*1024*1024) + int64(rand.Intn(150*1024*1024))
+	// プローブ。HEALTH_CHECK_TIMEOUT は 1 回のチェックの制限時間、HEALTH_CACHE_TTL は結果を再利用する期間、
+	// HEALTH_PROBE_TRACING=true でプローブのリクエストもトレースする
+	healthOpts := health.Options{}
+	if v := os.Getenv("HEALTH_CHECK_TIMEOUT"); v != "" {
+		if healthOpts.Timeout, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("failed to parse HEALTH_CHECK_TIMEOUT: %v", err)
+		}
+	}
+	if v := os.Getenv("HEALTH_CACHE_TTL"); v != "" {
+		if healthOpts.CacheTTL, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("failed to parse HEALTH_CACHE_TTL: %v", err)
+		}
+	}
+	var probeTracing bool
+	if v := os.Getenv("HEALTH_PROBE_TRACING"); v != "" {
+		if probeTracing, err = strconv.ParseBool(v); err != nil {
+			log.Fatalf("failed to parse HEALTH_PROBE_TRACING: %v", err)
+		}
+	}
+	probes := health.New(healthOpts)
+	// OTLP の送信先に接続できなくてもスプールで送信を再開できるため、接続状態は詳細として報告するだけにする
+	probes.AddInfo("otlp-exporter", health.DialChecker(otlpEndpoint))
+
+	srv, err := server.NewServer(server.Options{
+		TracerProvider: tp,
+		MeterProvider:  mp,
+		Logger:         logger,
+		LoggerProvider: lp,
+		LogLevels:      levels,
+		TelemetryStore: telemetryStore,
+		Faults:         fault.Config{HeadersEnabled: headersEnabled},
+		TrustedProxies: trustedProxies,
+		AccessLog: middleware.AccessLogOptions{
+			SampleRate:   &accessLogSampleRate,
+			ExcludePaths: accessLogExcludePaths,
+		},
+		TraceResponse: traceResponse,
+		Health:        probes,
+		Pipeline:      monitor,
+		Spool:         spooler,
+		Config:        reloader,
+		ProbeTracing:  probeTracing,
+	})
+	if err != nil {
+		log.Fatalf("failed to create server: %v", err)
+	}
+	slog.InfoContext(ctx, "Server created successfully")
 
-	// バックグラウンドでヒープメモリ使用量をシミュレート
-	go func() {
-		for {
-			time.Sleep(time.Duration(1+rand.Intn(3)) * time.Second)
-			heapUsageMutex.Lock()
-			// メモリ使用量を変動させる（-10MB〜+20MBの範囲）
-			change := int64(rand.Intn(30*1024*1024) - 10*1024*1024)
-			currentHeapUsage += change
-			// 最小値と最大値の制限（10MB〜500MB）
-			if currentHeapUsage < 10*1024*1024 {
-				currentHeapUsage = 10 * 1024 * 1024
-			}
-			if currentHeapUsage > 500*1024*1024 {
-				currentHeapUsage = 500 * 1024 * 1024
+	// バックグラウンドでファン速度・コネクション数・ヒープメモリ使用量をシミュレート
+	if err := srv.StartSimulations(ctx); err != nil {
+		log.Fatalf("failed to start simulations: %v", err)
+	}
+
+	// 管理 API は nginx を経由させず、別ポートで公開する
+	adminAddr := os.Getenv("ADMIN_ADDR")
+	if adminAddr == "" {
+		adminAddr = ":8081"
+	}
+	adminServer := &http.Server{Addr: adminAddr, Handler: srv.AdminRoutes()}
+	appServer := &http.Server{Addr: ":8080", Handler: srv.Routes()}
+	for _, hs := range []*http.Server{adminServer, appServer} {
+		go func() {
+			slog.InfoContext(ctx, "Starting server", "addr", hs.Addr)
+			if err := hs.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal(err)
 			}
-			heapUsageMutex.Unlock()
-			log.Printf("Simulated heap memory change: %+.2f MB, total: %.2f MB",
-				float64(change)/(1024*1024), float64(currentHeapUsage)/(1024*1024))
+		}()
+	}
+	probes.MarkStarted()
+
+	// SIGTERM / SIGINT を受けたら readiness を失敗させ、SHUTDOWN_DELAY（既定 5s）の間に nginx や
+	// ロードバランサーが振り分けを止めるのを待ってから、処理中のリクエストの完了を待って停止する
+	shutdownDelay := 5 * time.Second
+	if v := os.Getenv("SHUTDOWN_DELAY"); v != "" {
+		if shutdownDelay, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("failed to parse SHUTDOWN_DELAY: %v", err)
 		}
-	}()
+	}
+	stopCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-stopCtx.Done()
+	slog.InfoContext(ctx, "Shutting down server", "delay", shutdownDelay)
+	probes.Shutdown()
+	time.Sleep(shutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	for _, hs := range []*http.Server{appServer, adminServer} {
+		if err := hs.Shutdown(shutdownCtx); err != nil {
+			slog.ErrorContext(ctx, "Failed to shut down server", "addr", hs.Addr, "error", err)
+		}
+	}
+	slog.InfoContext(ctx, "Server shutdown complete")
+}
 
-	// Create chi router
-	r := chi.NewRouter()
-
-	r.Use(otelchi.Middleware("go-app"))
-
-	// Define routes
-	r.Get("/healthz", getHealtz)
-	r.Get("/", getRoot)
-	r.Get("/hello", getHello)
-	r.Get("/users/{id}", getUserByID)
-	r.Get("/error", getError)
-	r.Post("/items/add", addItem)
-	r.Post("/items/remove", removeItem)
-	r.Get("/cpu/fanspeed", getCPUFanSpeedHandler)
-	r.Get("/external-api", callExternalAPI)
-	r.Get("/metrics/memory", getMemoryMetrics)
-	r.Get("/metrics/connections", getConnectionMetrics)
-	r.Post("/connection/open", simulateConnect)
-	r.Post("/connection/close", simulateDisconnect)
-	r.Get("/metrics/heap", getHeapMetrics)
-	r.Post("/memory/allocate", allocateMemory)
-	r.Post("/memory/free", freeMemory)
-
-	log.Fatal(http.ListenAndServe(":8080", r))
+// splitList はカンマ区切りの値を空白を除いて分割する。空の要素は無視する
+func splitList(v string) []string {
+	var values []string
+	for _, value := range strings.Split(v, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
 }